@@ -47,6 +47,7 @@ func (c *Controller) handleConnection(conn *net.TCPConn) {
 	if err != nil {
 		return
 	}
+	h.Xid = stream.NextXid()
 	stream.Outbound <- h
 
 	for {
@@ -66,7 +67,9 @@ func (c *Controller) handleConnection(conn *net.TCPConn) {
 					// new Switch and notifiy listening
 					// applications.
 					stream.Version = m.Version
-					stream.Outbound <- ofp10.NewFeaturesRequest()
+					req := ofp10.NewFeaturesRequest()
+					req.Xid = stream.NextXid()
+					stream.Outbound <- req
 				} else {
 					// Connection should be severed if controller
 					// doesn't support switch version.
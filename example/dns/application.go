@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/jonstout/ogo"
+	"github.com/jonstout/ogo/protocol/dns"
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/ipv4"
+	"github.com/jonstout/ogo/protocol/ofp10"
+	"github.com/jonstout/ogo/protocol/packet"
+	"github.com/jonstout/ogo/protocol/udp"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// A thread safe table of local names.
+type Zone struct {
+	names map[string]net.IP
+	sync.RWMutex
+}
+
+func NewZone() *Zone {
+	z := new(Zone)
+	z.names = make(map[string]net.IP)
+	return z
+}
+
+// Answers queries for the A record of name with ip.
+func (z *Zone) SetHost(name string, ip net.IP) {
+	z.Lock()
+	defer z.Unlock()
+	z.names[key(name)] = ip
+}
+
+// Returns the address of name.
+func (z *Zone) Host(name string) (ip net.IP, ok bool) {
+	z.RLock()
+	defer z.RUnlock()
+	ip, ok = z.names[key(name)]
+	return
+}
+
+func key(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+var zone *Zone
+
+func NewResponderInstance() interface{} {
+	return new(ResponderInstance)
+}
+
+// Answers DNS queries for names in zone from the controller. Every
+// other packet sent to the controller is flooded.
+type ResponderInstance struct{}
+
+// Sends all DNS queries to the controller.
+func (r *ResponderInstance) ConnectionUp(dpid net.HardwareAddr) {
+	f := ofp10.NewFlowMod()
+	f.Priority = 2000
+	f.Match.DLType = eth.IPv4_MSG
+	f.Match.NWProto = udp.Type_UDP
+	f.Match.TPDst = dns.Port
+	f.Match.Wildcards &^= ofp10.FW_DL_TYPE | ofp10.FW_NW_PROTO | ofp10.FW_TP_DST
+	a := ofp10.NewActionOutput(ofp10.P_CONTROLLER)
+	a.MaxLen = 0xffff
+	f.AddAction(a)
+
+	if sw, ok := ogo.Switch(dpid); ok {
+		sw.Send(f)
+	}
+}
+
+func (r *ResponderInstance) PacketIn(dpid net.HardwareAddr, pkt *ofp10.PacketIn) {
+	sw, ok := ogo.Switch(dpid)
+	if !ok {
+		return
+	}
+	p := ofp10.NewPacketOut()
+	p.InPort = pkt.InPort
+
+	frame := pkt.Data
+	if reply := answer(packet.FromEthernet(&frame)); reply != nil {
+		data, err := packet.Serialize(reply, packet.SerializeOptions{FixLengths: true, ComputeChecksums: true})
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		p.AddAction(ofp10.NewActionOutput(ofp10.P_IN_PORT))
+		p.Data = util.NewBuffer(data)
+	} else {
+		p.AddAction(ofp10.NewActionOutput(ofp10.P_ALL))
+		p.Data = &frame
+	}
+	sw.Send(p)
+}
+
+// Returns the reply to a query for an A record in zone, or nil.
+func answer(p *packet.Packet) *eth.Ethernet {
+	var e *eth.Ethernet
+	var ip *ipv4.IPv4
+	var u *udp.UDP
+	var q *dns.DNS
+	if !p.Layer(&e) || !p.Layer(&ip) || !p.Layer(&u) || !p.Layer(&q) {
+		return nil
+	}
+	if q.IsResponse() || q.Opcode() != dns.Opcode_Query || len(q.Questions) != 1 {
+		return nil
+	}
+	question := q.Questions[0]
+	if question.Type != dns.Type_A || question.Class != dns.Class_IN {
+		return nil
+	}
+	addr, ok := zone.Host(question.Name)
+	if !ok {
+		return nil
+	}
+
+	d := dns.NewResponse(q)
+	d.Flags |= dns.FLAG_AA
+	d.AddAnswer(dns.NewResource(question.Name, 60, &dns.A{IP: addr}))
+
+	ru := udp.New()
+	ru.PortSrc = u.PortDst
+	ru.PortDst = u.PortSrc
+	ru.Data = d
+
+	rip := ipv4.New()
+	rip.Version = 4
+	rip.TTL = 64
+	rip.Protocol = udp.Type_UDP
+	rip.NWSrc = ip.NWDst
+	rip.NWDst = ip.NWSrc
+	rip.Data = ru
+
+	re := eth.New()
+	re.HWSrc = e.HWDst
+	re.HWDst = e.HWSrc
+	re.VLANs = e.VLANs
+	re.Data = rip
+	return re
+}
+
+func main() {
+	fmt.Println("Ogo 2013")
+	runtime.GOMAXPROCS(runtime.NumCPU())
+	ctrl := ogo.NewController()
+
+	zone = NewZone()
+	zone.SetHost("gateway.lan", net.ParseIP("10.0.0.1"))
+	zone.SetHost("controller.lan", net.ParseIP("10.0.0.2"))
+
+	ctrl.RegisterApplication(NewResponderInstance)
+	ctrl.Listen(":6633")
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/jonstout/ogo"
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/igmp"
+	"github.com/jonstout/ogo/protocol/ipv4"
+	"github.com/jonstout/ogo/protocol/ofp10"
+	"github.com/jonstout/ogo/protocol/packet"
+)
+
+// A thread safe record of the switch ports with members of each
+// multicast group.
+type Groups struct {
+	// Group, then dpid, then port.
+	members map[string]map[string]map[uint16]bool
+	sync.RWMutex
+}
+
+func NewGroups() *Groups {
+	g := new(Groups)
+	g.members = make(map[string]map[string]map[uint16]bool)
+	return g
+}
+
+// Records a member of group on port of switch dpid. Returns true
+// if the port was not already a member.
+func (g *Groups) Join(group net.IP, dpid net.HardwareAddr, port uint16) bool {
+	g.Lock()
+	defer g.Unlock()
+	switches, ok := g.members[group.String()]
+	if !ok {
+		switches = make(map[string]map[uint16]bool)
+		g.members[group.String()] = switches
+	}
+	ports, ok := switches[dpid.String()]
+	if !ok {
+		ports = make(map[uint16]bool)
+		switches[dpid.String()] = ports
+	}
+	if ports[port] {
+		return false
+	}
+	ports[port] = true
+	return true
+}
+
+// Removes port of switch dpid from group. Returns true if the port
+// was a member.
+func (g *Groups) Leave(group net.IP, dpid net.HardwareAddr, port uint16) bool {
+	g.Lock()
+	defer g.Unlock()
+	ports := g.members[group.String()][dpid.String()]
+	if !ports[port] {
+		return false
+	}
+	delete(ports, port)
+	if len(ports) == 0 {
+		delete(g.members[group.String()], dpid.String())
+	}
+	if len(g.members[group.String()]) == 0 {
+		delete(g.members, group.String())
+	}
+	return true
+}
+
+// Returns the member ports of group on each switch, keyed by dpid.
+func (g *Groups) Members(group net.IP) map[string][]uint16 {
+	g.RLock()
+	defer g.RUnlock()
+	m := make(map[string][]uint16)
+	for dpid, ports := range g.members[group.String()] {
+		for p := range ports {
+			m[dpid] = append(m[dpid], p)
+		}
+	}
+	return m
+}
+
+var groups *Groups
+
+// Returns the output ports of group on each switch. Members are
+// joined by a spanning tree over the discovered links, pruned of
+// branches without members, so traffic sent to the group from any
+// switch reaches every member once.
+func tree(group net.IP) map[string][]uint16 {
+	members := groups.Members(group)
+
+	// Links are recorded by the switch that received the discovery
+	// packet, so only links seen from both ends are used.
+	adj := make(map[string]map[string]uint16)
+	for _, sw := range ogo.Switches() {
+		ports := make(map[string]uint16)
+		for _, l := range sw.Links() {
+			ports[l.DPID.String()] = l.Port
+		}
+		adj[sw.DPID().String()] = ports
+	}
+	neighbors := func(dpid string) []string {
+		n := make([]string, 0)
+		for peer := range adj[dpid] {
+			if _, ok := adj[peer][dpid]; ok {
+				n = append(n, peer)
+			}
+		}
+		sort.Strings(n)
+		return n
+	}
+
+	roots := make([]string, 0)
+	for dpid := range members {
+		roots = append(roots, dpid)
+	}
+	sort.Strings(roots)
+
+	out := make(map[string][]uint16)
+	parent := make(map[string]string)
+	for _, root := range roots {
+		if _, ok := parent[root]; ok {
+			continue
+		}
+		// Breadth first from the lowest member dpid of each
+		// connected component.
+		parent[root] = ""
+		order := []string{root}
+		for i := 0; i < len(order); i++ {
+			for _, peer := range neighbors(order[i]) {
+				if _, ok := parent[peer]; !ok {
+					parent[peer] = order[i]
+					order = append(order, peer)
+				}
+			}
+		}
+
+		// Members below each switch, leaves first.
+		below := make(map[string]int)
+		for i := len(order) - 1; i >= 0; i-- {
+			dpid := order[i]
+			below[dpid] += len(members[dpid])
+			if p := parent[dpid]; p != "" {
+				below[p] += below[dpid]
+			}
+		}
+
+		total := below[root]
+		for _, dpid := range order {
+			ports := append([]uint16{}, members[dpid]...)
+			if p := parent[dpid]; p != "" && total > below[dpid] {
+				ports = append(ports, adj[dpid][p])
+			}
+			for _, peer := range neighbors(dpid) {
+				if parent[peer] == dpid && below[peer] > 0 {
+					ports = append(ports, adj[dpid][peer])
+				}
+			}
+			out[dpid] = ports
+		}
+	}
+	return out
+}
+
+// Installs the flows forwarding group along its tree on every
+// switch, removing the flow from switches that are off the tree.
+func install(group net.IP) {
+	ports := tree(group)
+	for _, sw := range ogo.Switches() {
+		f := ofp10.NewFlowMod()
+		f.Priority = 3000
+		f.Match.DLType = eth.IPv4_MSG
+		f.Match.NWDst = group.To4()
+		f.Match.Wildcards &^= ofp10.FW_DL_TYPE | ofp10.FW_NW_DST_MASK
+
+		if out := ports[sw.DPID().String()]; len(out) > 0 {
+			sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+			for _, p := range out {
+				f.AddAction(ofp10.NewActionOutput(p))
+			}
+		} else {
+			f.Command = ofp10.FC_DELETE_STRICT
+		}
+		sw.Send(f)
+	}
+}
+
+func NewSnoopingInstance() interface{} {
+	return new(SnoopingInstance)
+}
+
+// Tracks multicast group membership from IGMP reports and leaves,
+// and forwards each group only toward its members. Queries are
+// flooded. Reports and leaves are consumed by the controller.
+type SnoopingInstance struct{}
+
+// Sends all IGMP messages to the controller.
+func (s *SnoopingInstance) ConnectionUp(dpid net.HardwareAddr) {
+	f := ofp10.NewFlowMod()
+	f.Priority = 4000
+	f.Match.DLType = eth.IPv4_MSG
+	f.Match.NWProto = ipv4.Type_IGMP
+	f.Match.Wildcards &^= ofp10.FW_DL_TYPE | ofp10.FW_NW_PROTO
+	a := ofp10.NewActionOutput(ofp10.P_CONTROLLER)
+	a.MaxLen = 0xffff
+	f.AddAction(a)
+
+	if sw, ok := ogo.Switch(dpid); ok {
+		sw.Send(f)
+	}
+}
+
+func (s *SnoopingInstance) PacketIn(dpid net.HardwareAddr, pkt *ofp10.PacketIn) {
+	frame := pkt.Data
+	var msg *igmp.IGMP
+	if !packet.FromEthernet(&frame).Layer(&msg) {
+		return
+	}
+
+	if msg.Type == igmp.Type_Query {
+		p := ofp10.NewPacketOut()
+		p.InPort = pkt.InPort
+		p.AddAction(ofp10.NewActionOutput(ofp10.P_ALL))
+		p.Data = &frame
+		if sw, ok := ogo.Switch(dpid); ok {
+			sw.Send(p)
+		}
+		return
+	}
+
+	for _, group := range msg.Joins() {
+		if groups.Join(group, dpid, pkt.InPort) {
+			install(group)
+		}
+	}
+	for _, group := range msg.Leaves() {
+		if groups.Leave(group, dpid, pkt.InPort) {
+			install(group)
+		}
+	}
+}
+
+func main() {
+	fmt.Println("Ogo 2013")
+	runtime.GOMAXPROCS(runtime.NumCPU())
+	ctrl := ogo.NewController()
+	groups = NewGroups()
+	ctrl.RegisterApplication(NewSnoopingInstance)
+	ctrl.Listen(":6633")
+}
@@ -7,6 +7,8 @@ import (
 	"sync"	
 	
 	"github.com/jonstout/ogo"
+	"github.com/jonstout/ogo/protocol/icmpv6"
+	"github.com/jonstout/ogo/protocol/ipv6"
 	"github.com/jonstout/ogo/protocol/ofp10"
 )
 
@@ -19,12 +21,15 @@ type Host struct {
 // A thread safe map to store our hosts.
 type HostMap struct {
 	hosts map[string]Host
+	// IPv6 addresses learned from neighbor discovery.
+	ips map[string]net.HardwareAddr
 	sync.RWMutex
 }
 
 func NewHostMap() *HostMap {
 	h := new(HostMap)
 	h.hosts = make(map[string]Host)
+	h.ips = make(map[string]net.HardwareAddr)
 	return h
 }
 
@@ -43,6 +48,21 @@ func (m *HostMap) SetHost(mac net.HardwareAddr, port uint16) {
 	m.hosts[mac.String()] = Host{mac, port}
 }
 
+// Returns the mac address bound to the IPv6 address ip.
+func (m *HostMap) IPv6Host(ip net.IP) (mac net.HardwareAddr, ok bool) {
+	m.RLock()
+	defer m.RUnlock()
+	mac, ok = m.ips[ip.String()]
+	return
+}
+
+// Records that the IPv6 address ip belongs to mac.
+func (m *HostMap) SetIPv6Host(ip net.IP, mac net.HardwareAddr) {
+	m.Lock()
+	defer m.Unlock()
+	m.ips[ip.String()] = mac
+}
+
 var hostMap HostMap
 
 // Returns a new instance that implements one of the many
@@ -66,6 +86,15 @@ func (b *DemoInstance) PacketIn(dpid net.HardwareAddr, pkt *ofp10.PacketIn) {
 	}
 
 	b.SetHost(eth.HWSrc, pkt.InPort)
+	// Learn IPv6 addresses from neighbor solicitations and
+	// advertisements.
+	if ip, ok := eth.Data.(*ipv6.IPv6); ok {
+		if nd, ok := ip.Data.(*icmpv6.ICMPv6); ok {
+			if addr, mac, ok := icmpv6.NeighborBinding(nd, ip.NWSrc); ok {
+				b.SetIPv6Host(addr, mac)
+			}
+		}
+	}
 	if host, ok := b.Host(eth.HWDst); ok {
 		f1 := ofp10.NewFlowMod()
 		f1.Match.DLSrc = eth.HWSrc
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"runtime"
+	"sync"
+
+	"github.com/jonstout/ogo"
+	"github.com/jonstout/ogo/protocol/ipv4"
+	"github.com/jonstout/ogo/protocol/ofp10"
+)
+
+// Maps traffic to queue ids. Host policies take precedence over
+// DSCP policies.
+type Policy struct {
+	hosts map[string]uint32
+	dscp  map[uint8]uint32
+	sync.RWMutex
+}
+
+func NewPolicy() *Policy {
+	p := new(Policy)
+	p.hosts = make(map[string]uint32)
+	p.dscp = make(map[uint8]uint32)
+	return p
+}
+
+// Sends all traffic from mac to queue.
+func (p *Policy) SetHost(mac net.HardwareAddr, queue uint32) {
+	p.Lock()
+	defer p.Unlock()
+	p.hosts[mac.String()] = queue
+}
+
+// Sends all IPv4 traffic marked with dscp to queue.
+func (p *Policy) SetDSCP(dscp uint8, queue uint32) {
+	p.Lock()
+	defer p.Unlock()
+	p.dscp[dscp] = queue
+}
+
+// Returns the queue that traffic from mac with a DSCP value
+// of dscp belongs in.
+func (p *Policy) Queue(mac net.HardwareAddr, dscp uint8) (queue uint32, ok bool) {
+	p.RLock()
+	defer p.RUnlock()
+	if queue, ok = p.hosts[mac.String()]; ok {
+		return
+	}
+	queue, ok = p.dscp[dscp]
+	return
+}
+
+var policy *Policy
+
+func NewQoSInstance() interface{} {
+	q := new(QoSInstance)
+	q.hosts = make(map[string]uint16)
+	q.queues = make(map[uint16]map[uint32]bool)
+	return q
+}
+
+// Acts as a learning switch that places flows into queues
+// according to policy.
+type QoSInstance struct {
+	hosts  map[string]uint16
+	queues map[uint16]map[uint32]bool
+	sync.RWMutex
+}
+
+// Discovers the queues configured on each port of the switch.
+func (q *QoSInstance) ConnectionUp(dpid net.HardwareAddr) {
+	go func() {
+		sw, ok := ogo.Switch(dpid)
+		if !ok {
+			return
+		}
+		for _, p := range sw.Ports() {
+			if p.PortNo >= ofp10.P_MAX {
+				continue
+			}
+			rep, err := sw.QueueConfig(p.PortNo)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			q.Lock()
+			q.queues[p.PortNo] = make(map[uint32]bool)
+			for _, queue := range rep.Queues {
+				q.queues[p.PortNo][queue.QueueId] = true
+			}
+			q.Unlock()
+		}
+	}()
+}
+
+// Returns true if queue has been configured on port.
+func (q *QoSInstance) hasQueue(port uint16, queue uint32) bool {
+	q.RLock()
+	defer q.RUnlock()
+	return q.queues[port][queue]
+}
+
+func (q *QoSInstance) PacketIn(dpid net.HardwareAddr, pkt *ofp10.PacketIn) {
+	eth := pkt.Data
+	// Ignore link discovery packet types.
+	if eth.Ethertype == 0xa0f1 || eth.Ethertype == 0x88cc {
+		return
+	}
+
+	q.Lock()
+	q.hosts[eth.HWSrc.String()] = pkt.InPort
+	port, ok := q.hosts[eth.HWDst.String()]
+	q.Unlock()
+
+	sw, found := ogo.Switch(dpid)
+	if !found {
+		return
+	}
+	if !ok {
+		p := ofp10.NewPacketOut()
+		p.InPort = pkt.InPort
+		p.AddAction(ofp10.NewActionOutput(ofp10.P_ALL))
+		p.Data = &eth
+		sw.Send(p)
+		return
+	}
+
+	f := ofp10.NewFlowMod()
+	f.Match.DLSrc = eth.HWSrc
+	f.Match.DLDst = eth.HWDst
+	f.Match.Wildcards &^= ofp10.FW_DL_SRC | ofp10.FW_DL_DST
+	f.IdleTimeout = 3
+
+	var dscp uint8
+	if ip, isIP := eth.Data.(*ipv4.IPv4); isIP {
+		dscp = ip.DSCP
+		f.Match.DLType = 0x0800
+		f.Match.NWTos = dscp << 2
+		f.Match.Wildcards &^= ofp10.FW_DL_TYPE | ofp10.FW_NW_TOS
+	}
+
+	if queue, ok := policy.Queue(eth.HWSrc, dscp); ok && q.hasQueue(port, queue) {
+		f.AddAction(ofp10.NewActionEnqueue(port, queue))
+	} else {
+		f.AddAction(ofp10.NewActionOutput(port))
+	}
+	sw.Send(f)
+}
+
+func main() {
+	fmt.Println("Ogo 2013")
+	runtime.GOMAXPROCS(runtime.NumCPU())
+	ctrl := ogo.NewController()
+
+	policy = NewPolicy()
+	// Expedited forwarding to queue 1, best effort to queue 0.
+	policy.SetDSCP(46, 1)
+	policy.SetDSCP(0, 0)
+
+	ctrl.RegisterApplication(NewQoSInstance)
+	ctrl.Listen(":6633")
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/binary"
 	"errors"
 	"net"
+
+	"github.com/jonstout/ogo/protocol/util"
 )
 
 const (
@@ -67,7 +69,7 @@ func (a *ARP) MarshalBinary() (data []byte, err error) {
 
 func (a *ARP) UnmarshalBinary(data []byte) error {
 	if len(data) < 8 {
-		return errors.New("The []byte is too short to unmarshal a full ARP message.")
+		return util.NewTruncatedError("ARP message", 8, len(data))
 	}
 	a.HWType = binary.BigEndian.Uint16(data[:2])
 	a.ProtoType = binary.BigEndian.Uint16(data[2:4])
@@ -77,14 +79,18 @@ func (a *ARP) UnmarshalBinary(data []byte) error {
 
 	n := 8
 	if len(data[n:]) < (int(a.HWLength) * 2 + int(a.ProtoLength) * 2) {
-		return errors.New("The []byte is too short to unmarshal a full ARP message.")
+		return util.NewTruncatedError("ARP message", n+int(a.HWLength)*2+int(a.ProtoLength)*2, len(data))
 	}
-	a.HWSrc = data[n:n+int(a.HWLength)]
+	a.HWSrc = make(net.HardwareAddr, a.HWLength)
+	copy(a.HWSrc, data[n:])
 	n += int(a.HWLength)
-	a.IPSrc = data[n:n+int(a.ProtoLength)]
+	a.IPSrc = make(net.IP, a.ProtoLength)
+	copy(a.IPSrc, data[n:])
 	n += int(a.ProtoLength)
-	a.HWDst = data[n:n+int(a.HWLength)]
+	a.HWDst = make(net.HardwareAddr, a.HWLength)
+	copy(a.HWDst, data[n:])
 	n += int(a.HWLength)
-	a.IPDst = data[n:n+int(a.ProtoLength)]
+	a.IPDst = make(net.IP, a.ProtoLength)
+	copy(a.IPDst, data[n:])
 	return nil
 }
@@ -7,10 +7,25 @@ import (
 	"io"
 	"math/rand"
 	"net"
+
+	"github.com/jonstout/ogo/protocol/udp"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// UDP ports of DHCP servers and clients.
+const (
+	ServerPort = 67
+	ClientPort = 68
 )
 
+func init() {
+	udp.RegisterPort(ServerPort, func() util.Message { return new(DHCP) })
+	udp.RegisterPort(ClientPort, func() util.Message { return new(DHCP) })
+}
+
+// BOOTP operations
 const (
-	DHCP_MSG_BOOT_REQ byte = iota
+	DHCP_MSG_BOOT_REQ byte = iota + 1
 	DHCP_MSG_BOOT_RES
 )
 
@@ -69,11 +84,14 @@ const (
 )
 
 const (
-	DHCP_FLAG_BROADCAST uint16 = 0x80
+	DHCP_FLAG_BROADCAST uint16 = 0x8000
 
 //	FLAG_BROADCAST_MASK uint16 = (1 << FLAG_BROADCAST)
 )
 
+// Returns a DHCP message of type op. Op sets both the BOOTP
+// operation and the DHCP_OPT_MESSAGE_TYPE option, which is left
+// out for DHCP_MSG_UNSPEC.
 func NewDHCP(xid uint32, op DHCPOperation, hwtype byte) (*DHCP, error) {
 	if xid == 0 {
 		xid = rand.Uint32()
@@ -84,8 +102,15 @@ func NewDHCP(xid uint32, op DHCPOperation, hwtype byte) (*DHCP, error) {
 	default:
 		return nil, errors.New("Bad HardwareType")
 	}
+	// The message type goes in DHCP_OPT_MESSAGE_TYPE, while
+	// Operation holds the BOOTP operation.
+	boot := DHCP_MSG_BOOT_RES
+	switch op {
+	case DHCP_MSG_DISCOVER, DHCP_MSG_REQUEST, DHCP_MSG_DECLINE, DHCP_MSG_RELEASE, DHCP_MSG_INFORM:
+		boot = DHCP_MSG_BOOT_REQ
+	}
 	d := &DHCP{
-		Operation:    op,
+		Operation:    DHCPOperation(boot),
 		HardwareType: hwtype,
 		Xid:          xid,
 		ClientIP:     make([]byte, 4),
@@ -94,6 +119,9 @@ func NewDHCP(xid uint32, op DHCPOperation, hwtype byte) (*DHCP, error) {
 		GatewayIP:    make([]byte, 4),
 		ClientHWAddr: make([]byte, 16),
 	}
+	if op != DHCP_MSG_UNSPEC {
+		d.Options = append(d.Options, DHCPMessageTypeOption(op))
+	}
 	return d, nil
 }
 
@@ -101,7 +129,7 @@ func (d *DHCP) Len() (n uint16) {
 	n += uint16(240)
 	optend := false
 	for _, opt := range d.Options {
-		n += opt.Len()
+		n += optionLen(opt)
 		if opt.OptionType() == DHCP_OPT_END {
 			optend = true
 		}
@@ -112,159 +140,202 @@ func (d *DHCP) Len() (n uint16) {
 	return
 }
 
-func (d *DHCP) Read(b []byte) (n int, err error) {
-	buf := new(bytes.Buffer)
-	binary.Write(buf, binary.BigEndian, d.Operation)
+func (d *DHCP) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 240, int(d.Len()))
+	n := 0
+	data[n] = byte(d.Operation)
 	n += 1
-	binary.Write(buf, binary.BigEndian, d.HardwareType)
+	data[n] = d.HardwareType
 	n += 1
-	binary.Write(buf, binary.BigEndian, d.HardwareLen)
+	data[n] = d.HardwareLen
 	n += 1
-	binary.Write(buf, binary.BigEndian, d.HardwareOpts)
+	data[n] = d.HardwareOpts
 	n += 1
-	binary.Write(buf, binary.BigEndian, d.Xid)
+	binary.BigEndian.PutUint32(data[n:], d.Xid)
 	n += 4
-	binary.Write(buf, binary.BigEndian, d.Secs)
+	binary.BigEndian.PutUint16(data[n:], d.Secs)
 	n += 2
-	binary.Write(buf, binary.BigEndian, d.Flags)
+	binary.BigEndian.PutUint16(data[n:], d.Flags)
 	n += 2
-	binary.Write(buf, binary.BigEndian, d.ClientIP)
+	copy(data[n:n+4], d.ClientIP.To4())
 	n += 4
-	binary.Write(buf, binary.BigEndian, d.YourIP)
+	copy(data[n:n+4], d.YourIP.To4())
 	n += 4
-	binary.Write(buf, binary.BigEndian, d.ServerIP)
+	copy(data[n:n+4], d.ServerIP.To4())
 	n += 4
-	binary.Write(buf, binary.BigEndian, d.GatewayIP)
+	copy(data[n:n+4], d.GatewayIP.To4())
 	n += 4
-	clientHWAddr := make([]byte, 16)
-	copy(clientHWAddr[0:], d.ClientHWAddr)
-	binary.Write(buf, binary.BigEndian, clientHWAddr)
+	copy(data[n:n+16], d.ClientHWAddr)
 	n += 16
-	binary.Write(buf, binary.BigEndian, d.ServerName)
+	copy(data[n:], d.ServerName[:])
 	n += 64
-	binary.Write(buf, binary.BigEndian, d.File)
+	copy(data[n:], d.File[:])
 	n += 128
-	binary.Write(buf, binary.BigEndian, dhcpMagic)
+	binary.BigEndian.PutUint32(data[n:], dhcpMagic)
 	n += 4
 
 	optend := false
 	for _, opt := range d.Options {
-		m, err := DHCPWriteOption(buf, opt)
-		n += m
-		if err != nil {
-			return n, err
+		var b []byte
+		if b, err = DHCPMarshalOption(opt); err != nil {
+			return
 		}
+		data = append(data, b...)
 		if opt.OptionType() == DHCP_OPT_END {
 			optend = true
 		}
 	}
 	if !optend {
-		m, err := DHCPWriteOption(buf, DHCPNewOption(DHCP_OPT_END, nil))
-		n += m
-		if err != nil {
-			return n, err
-		}
-	}
-	if n, err = buf.Read(b); n == 0 {
-		return
+		data = append(data, DHCP_OPT_END)
 	}
-	return n, nil
+	return
 }
 
-func (d *DHCP) Write(b []byte) (n int, err error) {
-	if len(b) < 240 {
-		return 0, errors.New("incomplete packet")
-	}
-	buf := bytes.NewBuffer(b)
-
-	if err = binary.Read(buf, binary.BigEndian, &d.Operation); err != nil {
-		return
+func (d *DHCP) UnmarshalBinary(data []byte) error {
+	if len(data) < 240 {
+		return util.NewTruncatedError("DHCP message", 240, len(data))
 	}
+	n := 0
+	d.Operation = DHCPOperation(data[n])
 	n += 1
-	if err = binary.Read(buf, binary.BigEndian, &d.HardwareType); err != nil {
-		return
-	}
+	d.HardwareType = data[n]
 	n += 1
-	if err = binary.Read(buf, binary.BigEndian, &d.HardwareLen); err != nil {
-		return
-	}
+	d.HardwareLen = data[n]
 	n += 1
-	if err = binary.Read(buf, binary.BigEndian, &d.HardwareOpts); err != nil {
-		return
-	}
+	d.HardwareOpts = data[n]
 	n += 1
-	if err = binary.Read(buf, binary.BigEndian, &d.Xid); err != nil {
-		return
-	}
+	d.Xid = binary.BigEndian.Uint32(data[n:])
 	n += 4
-	if err = binary.Read(buf, binary.BigEndian, &d.Secs); err != nil {
-		return
-	}
+	d.Secs = binary.BigEndian.Uint16(data[n:])
 	n += 2
-	if err = binary.Read(buf, binary.BigEndian, &d.Flags); err != nil {
-		return
-	}
+	d.Flags = binary.BigEndian.Uint16(data[n:])
 	n += 2
-	d.ClientIP = make([]byte, 4)
-	if err = binary.Read(buf, binary.BigEndian, &d.ClientIP); err != nil {
-		return
-	}
+	d.ClientIP = make(net.IP, 4)
+	copy(d.ClientIP, data[n:])
 	n += 4
-	d.YourIP = make([]byte, 4)
-	if err = binary.Read(buf, binary.BigEndian, &d.YourIP); err != nil {
-		return
-	}
+	d.YourIP = make(net.IP, 4)
+	copy(d.YourIP, data[n:])
 	n += 4
-	d.ServerIP = make([]byte, 4)
-	if err = binary.Read(buf, binary.BigEndian, &d.ServerIP); err != nil {
-		return
-	}
+	d.ServerIP = make(net.IP, 4)
+	copy(d.ServerIP, data[n:])
 	n += 4
-	d.GatewayIP = make([]byte, 4)
-	if err = binary.Read(buf, binary.BigEndian, &d.GatewayIP); err != nil {
-		return
-	}
+	d.GatewayIP = make(net.IP, 4)
+	copy(d.GatewayIP, data[n:])
 	n += 4
-	clientHWAddr := make([]byte, 16)
-	if err = binary.Read(buf, binary.BigEndian, &clientHWAddr); err != nil {
-		return
+	hwLen := int(d.HardwareLen)
+	if hwLen > 16 {
+		hwLen = 16
 	}
-	d.ClientHWAddr = net.HardwareAddr(clientHWAddr[:d.HardwareLen])
+	d.ClientHWAddr = make(net.HardwareAddr, hwLen)
+	copy(d.ClientHWAddr, data[n:])
 	n += 16
-
-	if err = binary.Read(buf, binary.BigEndian, &d.ServerName); err != nil {
-		return
-	}
+	copy(d.ServerName[:], data[n:])
 	n += 64
-	if err = binary.Read(buf, binary.BigEndian, &d.File); err != nil {
-		return
-	}
+	copy(d.File[:], data[n:])
 	n += 128
 
-	var magic uint32
-	if err = binary.Read(buf, binary.BigEndian, &magic); err != nil {
-		return
+	if binary.BigEndian.Uint32(data[n:]) != dhcpMagic {
+		return util.NewMalformedError("DHCP message", "magic cookie is missing")
 	}
 	n += 4
 
-	if magic != dhcpMagic {
-		return n, errors.New("Bad DHCP header")
+	var err error
+	d.Options, err = DHCPParseOptions(data[n:])
+	return err
+}
+
+// Returns the first option with tag.
+func (d *DHCP) Option(tag byte) DHCPOption {
+	for _, opt := range d.Options {
+		if opt.OptionType() == tag {
+			return opt
+		}
 	}
+	return nil
+}
 
-	optlen := buf.Len()
-	opts := make([]byte, optlen)
-	if err = binary.Read(buf, binary.BigEndian, &opts); err != nil {
+// Appends opt to the options, keeping DHCP_OPT_END last.
+func (d *DHCP) AddOption(opt DHCPOption) {
+	if n := len(d.Options); n > 0 && d.Options[n-1].OptionType() == DHCP_OPT_END {
+		d.Options = append(d.Options[:n-1], opt, d.Options[n-1])
 		return
 	}
-	n += optlen
+	d.Options = append(d.Options, opt)
+}
 
-	if d.Options, err = DHCPParseOptions(opts); err != nil {
-		return
+// Returns the DHCP message type, DHCP_MSG_UNSPEC for plain BOOTP.
+func (d *DHCP) MessageType() DHCPOperation {
+	if b := d.optionBytes(DHCP_OPT_MESSAGE_TYPE, 1); b != nil {
+		return DHCPOperation(b[0])
 	}
+	return DHCP_MSG_UNSPEC
+}
 
+// Returns the lease time in seconds.
+func (d *DHCP) LeaseTime() (secs uint32, ok bool) {
+	if b := d.optionBytes(DHCP_OPT_LEASE_TIME, 4); b != nil {
+		return binary.BigEndian.Uint32(b), true
+	}
 	return
 }
 
+// Returns the address the client asked to be assigned.
+func (d *DHCP) RequestedIP() net.IP {
+	return d.optionIP(DHCP_OPT_REQUEST_IP)
+}
+
+// Returns the address of the server the message is for or from.
+func (d *DHCP) ServerID() net.IP {
+	return d.optionIP(DHCP_OPT_SERVER_ID)
+}
+
+func (d *DHCP) SubnetMask() net.IPMask {
+	if ip := d.optionIP(DHCP_OPT_SUBNET_MASK); ip != nil {
+		return net.IPMask(ip)
+	}
+	return nil
+}
+
+// Returns the routers on the client's subnet, in order of preference.
+func (d *DHCP) Routers() []net.IP {
+	return d.optionIPs(DHCP_OPT_DEFAULT_GATEWAY)
+}
+
+// Returns the DNS servers available to the client.
+func (d *DHCP) DNSServers() []net.IP {
+	return d.optionIPs(DHCP_OPT_DOMAIN_NAME_SERVERS)
+}
+
+// Returns the data of option tag if it is at least min bytes long.
+func (d *DHCP) optionBytes(tag byte, min int) []byte {
+	opt := d.Option(tag)
+	if opt == nil || len(opt.Bytes()) < min {
+		return nil
+	}
+	return opt.Bytes()
+}
+
+func (d *DHCP) optionIP(tag byte) net.IP {
+	if ips := d.optionIPs(tag); len(ips) > 0 {
+		return ips[0]
+	}
+	return nil
+}
+
+func (d *DHCP) optionIPs(tag byte) []net.IP {
+	b := d.optionBytes(tag, 4)
+	if b == nil {
+		return nil
+	}
+	ips := make([]net.IP, 0, len(b)/4)
+	for i := 0; i+4 <= len(b); i += 4 {
+		ip := make(net.IP, 4)
+		copy(ip, b[i:i+4])
+		ips = append(ips, ip)
+	}
+	return ips
+}
+
 // Standard options (RFC1533)
 const (
 	DHCP_OPT_PAD                      byte = iota
@@ -431,6 +502,15 @@ func DHCPMarshalOption(o DHCPOption) (out []byte, err error) {
 	return
 }
 
+// Returns the marshaled length of o.
+func optionLen(o DHCPOption) uint16 {
+	switch o.OptionType() {
+	case DHCP_OPT_PAD, DHCP_OPT_END:
+		return 1
+	}
+	return uint16(2 + len(o.Bytes()))
+}
+
 func (self dhcpoption) Len() uint16      { return uint16(len(self.data) + 2) }
 func (self dhcpoption) Bytes() []byte    { return self.data }
 func (self dhcpoption) OptionType() byte { return self.tag }
@@ -472,6 +552,18 @@ func DHCPIP4Option(tag byte, ips net.IP) (opt DHCPOption, err error) {
 	return
 }
 
+func DHCPMessageTypeOption(t DHCPOperation) DHCPOption {
+	return DHCPNewOption(DHCP_OPT_MESSAGE_TYPE, []byte{byte(t)})
+}
+
+// For options holding seconds, such as DHCP_OPT_LEASE_TIME, DHCP_OPT_T1
+// and DHCP_OPT_T2.
+func DHCPUint32Option(tag byte, v uint32) DHCPOption {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, v)
+	return DHCPNewOption(tag, data)
+}
+
 // NB: I'm not checking tag : min length here!
 func DHCPStringOption(tag byte, s string) (opt DHCPOption, err error) {
 	opt = &dhcpoption{tag: tag, data: bytes.NewBufferString(s).Bytes()}
@@ -489,12 +581,18 @@ func DHCPParseOptions(in []byte) (opts []DHCPOption, err error) {
 		case DHCP_OPT_END:
 			return
 		default:
-			if len(in)-pos >= 1 {
-				_len := in[pos]
-				pos++
-				opts = append(opts, DHCPNewOption(tag, in[pos:pos+int(_len)]))
-				pos += int(_len)
+			if len(in)-pos < 1 {
+				return opts, util.NewTruncatedError("DHCP option", 2, len(in)-pos+1)
+			}
+			if len(in)-pos-1 < int(in[pos]) {
+				return opts, util.NewTruncatedError("DHCP option", int(in[pos])+2, len(in)-pos+1)
 			}
+			_len := int(in[pos])
+			pos++
+			data := make([]byte, _len)
+			copy(data, in[pos:pos+_len])
+			opts = append(opts, DHCPNewOption(tag, data))
+			pos += _len
 		}
 	}
 	return
@@ -506,7 +604,6 @@ func NewDHCPDiscover(xid uint32, hwAddr net.HardwareAddr) (d *DHCP, err error) {
 	}
 	d.HardwareLen = uint8(len(hwAddr))
 	d.ClientHWAddr = hwAddr
-	d.Options = append(d.Options, DHCPNewOption(53, []byte{byte(DHCP_MSG_DISCOVER)}))
 	d.Options = append(d.Options, DHCPNewOption(DHCP_OPT_CLIENT_ID, hwAddr))
 	return
 }
@@ -517,7 +614,6 @@ func NewDHCPOffer(xid uint32, hwAddr net.HardwareAddr) (d *DHCP, err error) {
 	}
 	d.HardwareLen = uint8(len(hwAddr))
 	d.ClientHWAddr = hwAddr
-	d.Options = append(d.Options, DHCPNewOption(53, []byte{byte(DHCP_MSG_OFFER)}))
 	return
 }
 
@@ -527,7 +623,6 @@ func NewDHCPRequest(xid uint32, hwAddr net.HardwareAddr) (d *DHCP, err error) {
 	}
 	d.HardwareLen = uint8(len(hwAddr))
 	d.ClientHWAddr = hwAddr
-	d.Options = append(d.Options, DHCPNewOption(53, []byte{byte(DHCP_MSG_REQUEST)}))
 	return
 }
 
@@ -537,7 +632,6 @@ func NewDHCPAck(xid uint32, hwAddr net.HardwareAddr) (d *DHCP, err error) {
 	}
 	d.HardwareLen = uint8(len(hwAddr))
 	d.ClientHWAddr = hwAddr
-	d.Options = append(d.Options, DHCPNewOption(53, []byte{byte(DHCP_MSG_ACK)}))
 	return
 }
 
@@ -547,6 +641,5 @@ func NewDHCPNak(xid uint32, hwAddr net.HardwareAddr) (d *DHCP, err error) {
 	}
 	d.HardwareLen = uint8(len(hwAddr))
 	d.ClientHWAddr = hwAddr
-	d.Options = append(d.Options, DHCPNewOption(53, []byte{byte(DHCP_MSG_NAK)}))
 	return
 }
@@ -0,0 +1,127 @@
+package dhcp
+
+import (
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/udp"
+)
+
+func TestDHCPMarshalBinary(t *testing.T) {
+	b := "   02 01 06 00 00 00 30 39 00 00 80 00 " + // Op, HW, Xid, Secs, Flags
+		"00 00 00 00 0a 00 00 05 0a 00 00 01 00 00 00 00 " + // ClientIP, YourIP, ServerIP, GatewayIP
+		"00 11 22 33 44 55 " + // ClientHWAddr
+		strings.Repeat("00 ", 10+64+128) +
+		"63 82 53 63 " + // Magic
+		"35 01 02 " + // Message type
+		"33 04 00 00 0e 10 " + // Lease time
+		"01 04 ff ff ff 00 " + // Subnet mask
+		"03 04 0a 00 00 01 " + // Router
+		"06 08 08 08 08 08 08 08 04 04 " + // DNS
+		"ff " // End
+	b = strings.Replace(b, " ", "", -1)
+
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	d, _ := NewDHCPOffer(12345, mac)
+	d.Flags = DHCP_FLAG_BROADCAST
+	d.YourIP = net.ParseIP("10.0.0.5")
+	d.ServerIP = net.ParseIP("10.0.0.1")
+	d.AddOption(DHCPUint32Option(DHCP_OPT_LEASE_TIME, 3600))
+	opt, _ := DHCPIP4Option(DHCP_OPT_SUBNET_MASK, net.IP(net.CIDRMask(24, 32)))
+	d.AddOption(opt)
+	opt, _ = DHCPIP4Option(DHCP_OPT_DEFAULT_GATEWAY, net.ParseIP("10.0.0.1"))
+	d.AddOption(opt)
+	opt, _ = DHCPIP4sOption(DHCP_OPT_DOMAIN_NAME_SERVERS, []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("8.8.4.4")})
+	d.AddOption(opt)
+
+	data, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := hex.EncodeToString(data)
+	if (len(b) != len(s)) || (b != s) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", s)
+		t.Errorf("Received length of %d, expected %d", len(s), len(b))
+	}
+	if int(d.Len()) != len(data) {
+		t.Errorf("Got length of %d, expected %d.", d.Len(), len(data))
+	}
+}
+
+func TestDHCPUnmarshalBinary(t *testing.T) {
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	req, _ := NewDHCPRequest(7, mac)
+	opt, _ := DHCPIP4Option(DHCP_OPT_REQUEST_IP, net.ParseIP("10.0.0.5"))
+	req.AddOption(opt)
+	opt, _ = DHCPIP4Option(DHCP_OPT_SERVER_ID, net.ParseIP("10.0.0.1"))
+	req.AddOption(opt)
+	data, _ := req.MarshalBinary()
+
+	d := new(DHCP)
+	if err := d.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if d.Operation != DHCPOperation(DHCP_MSG_BOOT_REQ) || d.MessageType() != DHCP_MSG_REQUEST {
+		t.Errorf("Got operation %d and type %d, expected a request.", d.Operation, d.MessageType())
+	}
+	if d.ClientHWAddr.String() != mac.String() || d.Xid != 7 {
+		t.Errorf("Got client %v and xid %d.", d.ClientHWAddr, d.Xid)
+	}
+	if !d.RequestedIP().Equal(net.ParseIP("10.0.0.5")) || !d.ServerID().Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("Got requested ip %v from server %v.", d.RequestedIP(), d.ServerID())
+	}
+	if _, ok := d.LeaseTime(); ok {
+		t.Error("Found a lease time in a request without one.")
+	}
+
+	// A truncated option must not panic.
+	if err := d.UnmarshalBinary(append(data[:240], 0x33, 0x04, 0x00)); err == nil {
+		t.Error("Expected an error unmarshaling a truncated option.")
+	}
+}
+
+func TestUDPDispatch(t *testing.T) {
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	req, _ := NewDHCPDiscover(9, mac)
+	b, _ := req.MarshalBinary()
+
+	u := udp.New()
+	u.PortSrc = ClientPort
+	u.PortDst = ServerPort
+	u.Length = uint16(8 + len(b))
+	u.Data = nil
+	data, _ := u.MarshalBinary()
+	data = append(data, b...)
+
+	u = udp.New()
+	if err := u.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if d, ok := u.Data.(*DHCP); !ok || d.MessageType() != DHCP_MSG_DISCOVER {
+		t.Errorf("Got payload %v, expected a DHCP discover.", u.Data)
+	}
+}
+
+func TestNewDHCPMessageType(t *testing.T) {
+	d, err := NewDHCP(3, DHCP_MSG_INFORM, DHCP_HW_ETHERNET)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Operation != DHCPOperation(DHCP_MSG_BOOT_REQ) {
+		t.Errorf("Got operation %d, expected %d.", d.Operation, DHCP_MSG_BOOT_REQ)
+	}
+	if d.MessageType() != DHCP_MSG_INFORM {
+		t.Errorf("Got message type %d, expected %d.", d.MessageType(), DHCP_MSG_INFORM)
+	}
+	if len(d.Options) != 1 {
+		t.Errorf("Got %d options, expected 1.", len(d.Options))
+	}
+
+	d, _ = NewDHCP(3, DHCP_MSG_UNSPEC, DHCP_HW_ETHERNET)
+	if len(d.Options) != 0 {
+		t.Errorf("Got %d options, expected none.", len(d.Options))
+	}
+}
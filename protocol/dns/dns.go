@@ -0,0 +1,419 @@
+// Package dns implements the DNS message format of RFC 1035,
+// including name compression, and the A, AAAA, CNAME, PTR, TXT
+// and SRV records.
+//
+// Importing dns registers it as the payload of UDP port 53.
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"github.com/jonstout/ogo/protocol/udp"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// UDP port of DNS.
+const Port = 53
+
+func init() {
+	udp.RegisterPort(Port, func() util.Message { return New() })
+}
+
+// Record types.
+const (
+	Type_A     = 1
+	Type_NS    = 2
+	Type_CNAME = 5
+	Type_SOA   = 6
+	Type_PTR   = 12
+	Type_MX    = 15
+	Type_TXT   = 16
+	Type_AAAA  = 28
+	Type_SRV   = 33
+	Type_OPT   = 41
+	Type_ANY   = 255
+)
+
+// Record classes.
+const (
+	Class_IN  = 1
+	Class_ANY = 255
+)
+
+// Header flags.
+const (
+	FLAG_QR = 1 << 15 // Response
+	FLAG_AA = 1 << 10 // Authoritative answer
+	FLAG_TC = 1 << 9  // Truncated
+	FLAG_RD = 1 << 8  // Recursion desired
+	FLAG_RA = 1 << 7  // Recursion available
+
+	OPCODE_SHIFT = 11
+	OPCODE_MASK  = 0xf << OPCODE_SHIFT
+	RCODE_MASK   = 0xf
+)
+
+// Opcodes.
+const (
+	Opcode_Query  = 0
+	Opcode_Status = 2
+	Opcode_Notify = 4
+	Opcode_Update = 5
+)
+
+// Response codes.
+const (
+	Rcode_NoError  = 0
+	Rcode_FormErr  = 1
+	Rcode_ServFail = 2
+	Rcode_NXDomain = 3
+	Rcode_NotImp   = 4
+	Rcode_Refused  = 5
+)
+
+type DNS struct {
+	Id          uint16
+	Flags       uint16
+	Questions   []Question
+	Answers     []Resource
+	Authorities []Resource
+	Additionals []Resource
+}
+
+func New() *DNS {
+	d := new(DNS)
+	d.Questions = make([]Question, 0)
+	d.Answers = make([]Resource, 0)
+	d.Authorities = make([]Resource, 0)
+	d.Additionals = make([]Resource, 0)
+	return d
+}
+
+// Returns a recursive query for the records of type t at name.
+func NewQuery(id uint16, name string, t uint16) *DNS {
+	d := New()
+	d.Id = id
+	d.Flags = FLAG_RD
+	d.Questions = append(d.Questions, Question{name, t, Class_IN})
+	return d
+}
+
+// Returns an empty response to the query q, echoing its id,
+// opcode, questions and recursion desired flag.
+func NewResponse(q *DNS) *DNS {
+	d := New()
+	d.Id = q.Id
+	d.Flags = FLAG_QR | q.Flags&(OPCODE_MASK|FLAG_RD)
+	d.Questions = append(d.Questions, q.Questions...)
+	return d
+}
+
+// Returns true if d is a response.
+func (d *DNS) IsResponse() bool {
+	return d.Flags&FLAG_QR != 0
+}
+
+func (d *DNS) Opcode() uint8 {
+	return uint8(d.Flags & OPCODE_MASK >> OPCODE_SHIFT)
+}
+
+func (d *DNS) Rcode() uint8 {
+	return uint8(d.Flags & RCODE_MASK)
+}
+
+func (d *DNS) SetRcode(rcode uint8) {
+	d.Flags = d.Flags&^RCODE_MASK | uint16(rcode)&RCODE_MASK
+}
+
+// Adds a record to the answer section.
+func (d *DNS) AddAnswer(r Resource) {
+	d.Answers = append(d.Answers, r)
+}
+
+// Returns the length of d once compressed.
+func (d *DNS) Len() (n uint16) {
+	data, err := d.MarshalBinary()
+	if err != nil {
+		return 12
+	}
+	return uint16(len(data))
+}
+
+func (d *DNS) MarshalBinary() (data []byte, err error) {
+	p := newPacker()
+	p.buf = make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(p.buf[0:2], d.Id)
+	binary.BigEndian.PutUint16(p.buf[2:4], d.Flags)
+	binary.BigEndian.PutUint16(p.buf[4:6], uint16(len(d.Questions)))
+	binary.BigEndian.PutUint16(p.buf[6:8], uint16(len(d.Answers)))
+	binary.BigEndian.PutUint16(p.buf[8:10], uint16(len(d.Authorities)))
+	binary.BigEndian.PutUint16(p.buf[10:12], uint16(len(d.Additionals)))
+
+	for _, q := range d.Questions {
+		if err = q.pack(p); err != nil {
+			return
+		}
+	}
+	for _, s := range [][]Resource{d.Answers, d.Authorities, d.Additionals} {
+		for _, r := range s {
+			if err = r.pack(p); err != nil {
+				return
+			}
+		}
+	}
+	return p.buf, nil
+}
+
+func (d *DNS) UnmarshalBinary(data []byte) error {
+	if len(data) < 12 {
+		return util.NewTruncatedError("DNS message", 12, len(data))
+	}
+	d.Id = binary.BigEndian.Uint16(data[0:2])
+	d.Flags = binary.BigEndian.Uint16(data[2:4])
+	qd := int(binary.BigEndian.Uint16(data[4:6]))
+	an := int(binary.BigEndian.Uint16(data[6:8]))
+	ns := int(binary.BigEndian.Uint16(data[8:10]))
+	ar := int(binary.BigEndian.Uint16(data[10:12]))
+
+	var err error
+	n := 12
+	d.Questions = make([]Question, qd)
+	for i := range d.Questions {
+		if n, err = d.Questions[i].unpack(data, n); err != nil {
+			return err
+		}
+	}
+	d.Answers, n, err = unpackResources(data, n, an)
+	if err != nil {
+		return err
+	}
+	d.Authorities, n, err = unpackResources(data, n, ns)
+	if err != nil {
+		return err
+	}
+	d.Additionals, _, err = unpackResources(data, n, ar)
+	return err
+}
+
+type Question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+func (q *Question) pack(p *packer) error {
+	if err := p.name(q.Name, true); err != nil {
+		return err
+	}
+	p.uint16(q.Type)
+	p.uint16(q.Class)
+	return nil
+}
+
+func (q *Question) unpack(msg []byte, off int) (n int, err error) {
+	if q.Name, n, err = unpackName(msg, off); err != nil {
+		return
+	}
+	if len(msg) < n+4 {
+		return n, util.NewTruncatedError("DNS question", n+4, len(msg))
+	}
+	q.Type = binary.BigEndian.Uint16(msg[n:])
+	q.Class = binary.BigEndian.Uint16(msg[n+2:])
+	return n + 4, nil
+}
+
+// A resource record. Type is taken from Data.
+type Resource struct {
+	Name  string
+	Class uint16
+	TTL   uint32
+	Data  RData
+}
+
+// Returns an Internet class record for name.
+func NewResource(name string, ttl uint32, data RData) Resource {
+	return Resource{name, Class_IN, ttl, data}
+}
+
+func (r *Resource) Type() uint16 {
+	return r.Data.Type()
+}
+
+func (r *Resource) pack(p *packer) error {
+	if r.Data == nil {
+		return errors.New("DNS resource record has no data.")
+	}
+	if err := p.name(r.Name, true); err != nil {
+		return err
+	}
+	p.uint16(r.Data.Type())
+	p.uint16(r.Class)
+	p.uint16(uint16(r.TTL >> 16))
+	p.uint16(uint16(r.TTL))
+
+	// RDLENGTH is filled in once the data is packed.
+	l := len(p.buf)
+	p.uint16(0)
+	if err := r.Data.pack(p); err != nil {
+		return err
+	}
+	if len(p.buf)-l-2 > 0xffff {
+		return errors.New("DNS resource record data is too long.")
+	}
+	binary.BigEndian.PutUint16(p.buf[l:], uint16(len(p.buf)-l-2))
+	return nil
+}
+
+func unpackResources(msg []byte, off, count int) (rs []Resource, n int, err error) {
+	n = off
+	rs = make([]Resource, count)
+	for i := range rs {
+		if n, err = rs[i].unpack(msg, n); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (r *Resource) unpack(msg []byte, off int) (n int, err error) {
+	if r.Name, n, err = unpackName(msg, off); err != nil {
+		return
+	}
+	if len(msg) < n+10 {
+		return n, util.NewTruncatedError("DNS resource record", n+10, len(msg))
+	}
+	t := binary.BigEndian.Uint16(msg[n:])
+	r.Class = binary.BigEndian.Uint16(msg[n+2:])
+	r.TTL = binary.BigEndian.Uint32(msg[n+4:])
+	length := int(binary.BigEndian.Uint16(msg[n+8:]))
+	n += 10
+	if len(msg) < n+length {
+		return n, util.NewTruncatedError("DNS resource record", n+length, len(msg))
+	}
+
+	switch t {
+	case Type_A:
+		r.Data = new(A)
+	case Type_AAAA:
+		r.Data = new(AAAA)
+	case Type_CNAME:
+		r.Data = new(CNAME)
+	case Type_PTR:
+		r.Data = new(PTR)
+	case Type_TXT:
+		r.Data = new(TXT)
+	case Type_SRV:
+		r.Data = new(SRV)
+	default:
+		r.Data = &Unknown{RRType: t}
+	}
+	// Names in the data may point anywhere in the message, so the
+	// data is unpacked in place.
+	if err = r.Data.unpack(msg, n, n+length); err != nil {
+		return
+	}
+	return n + length, nil
+}
+
+// Writes a message, remembering where each name was written so
+// later names can point to it.
+type packer struct {
+	buf   []byte
+	names map[string]int
+}
+
+func newPacker() *packer {
+	p := new(packer)
+	p.names = make(map[string]int)
+	return p
+}
+
+func (p *packer) uint16(v uint16) {
+	p.buf = append(p.buf, byte(v>>8), byte(v))
+}
+
+// Writes name as a sequence of labels. When compress is set, the
+// longest suffix of name already in the message is replaced by a
+// pointer to it.
+func (p *packer) name(name string, compress bool) error {
+	name = strings.TrimSuffix(name, ".")
+	if len(name) > 253 {
+		return errors.New("DNS name is too long: " + name)
+	}
+	for name != "" {
+		key := strings.ToLower(name)
+		if off, ok := p.names[key]; ok && compress {
+			p.uint16(0xc000 | uint16(off))
+			return nil
+		}
+		// Pointers only reach the first 16KB of a message.
+		if len(p.buf) < 0x4000 {
+			p.names[key] = len(p.buf)
+		}
+
+		label := name
+		rest := ""
+		if i := strings.IndexByte(name, '.'); i >= 0 {
+			label, rest = name[:i], name[i+1:]
+		}
+		if len(label) == 0 || len(label) > 63 {
+			return errors.New("DNS name has an invalid label: " + name)
+		}
+		p.buf = append(p.buf, byte(len(label)))
+		p.buf = append(p.buf, label...)
+		name = rest
+	}
+	p.buf = append(p.buf, 0)
+	return nil
+}
+
+// Reads the possibly compressed name at off in msg. Returns the name
+// without a trailing dot and the offset following it.
+func unpackName(msg []byte, off int) (name string, n int, err error) {
+	labels := make([]string, 0)
+	length := 0
+	n = -1
+	// Every pointer has to go backwards, which rules out loops.
+	limit := off + 1
+	for {
+		if off >= len(msg) {
+			return "", 0, util.NewTruncatedError("DNS name", off+1, len(msg))
+		}
+		c := int(msg[off])
+		switch c & 0xc0 {
+		case 0x00:
+			if c == 0 {
+				if n < 0 {
+					n = off + 1
+				}
+				return strings.Join(labels, "."), n, nil
+			}
+			if off+1+c > len(msg) {
+				return "", 0, util.NewTruncatedError("DNS name", off+1+c, len(msg))
+			}
+			length += c + 1
+			if length > 254 {
+				return "", 0, util.NewMalformedError("DNS name", "it is longer than 255 bytes")
+			}
+			labels = append(labels, string(msg[off+1:off+1+c]))
+			off += 1 + c
+		case 0xc0:
+			if off+2 > len(msg) {
+				return "", 0, util.NewTruncatedError("DNS name", off+2, len(msg))
+			}
+			if n < 0 {
+				n = off + 2
+			}
+			ptr := int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			if ptr >= limit-1 {
+				return "", 0, util.NewMalformedError("DNS name", "compression pointer does not point backwards")
+			}
+			limit = ptr + 1
+			off = ptr
+		default:
+			return "", 0, util.NewMalformedError("DNS name", "unsupported label type")
+		}
+	}
+}
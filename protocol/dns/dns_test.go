@@ -0,0 +1,142 @@
+package dns
+
+import (
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/udp"
+)
+
+var response = "   12 34 81 80 00 01 00 02 00 00 00 00 " + // Header
+	"03 77 77 77 07 65 78 61 6d 70 6c 65 03 63 6f 6d 00 " + // www.example.com
+	"00 01 00 01 " + // Type A, Class IN
+	"c0 0c 00 05 00 01 00 00 01 2c 00 02 " + // CNAME
+	"c0 10 " + // example.com
+	"c0 10 00 01 00 01 00 00 01 2c 00 04 " + // A
+	"5d b8 d8 22 " // 93.184.216.34
+
+func TestDNSMarshalBinary(t *testing.T) {
+	b := strings.Replace(response, " ", "", -1)
+
+	q := NewQuery(0x1234, "www.example.com", Type_A)
+	d := NewResponse(q)
+	d.Flags |= FLAG_RA
+	d.AddAnswer(NewResource("www.example.com.", 300, &CNAME{"example.com"}))
+	d.AddAnswer(NewResource("example.com", 300, &A{net.ParseIP("93.184.216.34")}))
+
+	data, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := hex.EncodeToString(data)
+	if (len(b) != len(s)) || (b != s) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", s)
+		t.Errorf("Received length of %d, expected %d", len(s), len(b))
+	}
+	if int(d.Len()) != len(data) {
+		t.Errorf("Got length of %d, expected %d.", d.Len(), len(data))
+	}
+}
+
+func TestDNSUnmarshalBinary(t *testing.T) {
+	b := strings.Replace(response, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	d := New()
+	if err := d.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !d.IsResponse() || d.Rcode() != Rcode_NoError || d.Id != 0x1234 {
+		t.Errorf("Got header %x %x, expected a response to 1234.", d.Id, d.Flags)
+	}
+	if len(d.Questions) != 1 || d.Questions[0].Name != "www.example.com" {
+		t.Errorf("Got questions %v, expected www.example.com.", d.Questions)
+	}
+	if len(d.Answers) != 2 {
+		t.Fatalf("Got %d answers, expected 2.", len(d.Answers))
+	}
+	if c, ok := d.Answers[0].Data.(*CNAME); !ok || c.Target != "example.com" {
+		t.Errorf("Got answer %v, expected a CNAME to example.com.", d.Answers[0].Data)
+	}
+	a, ok := d.Answers[1].Data.(*A)
+	if !ok || d.Answers[1].Name != "example.com" || !a.IP.Equal(net.ParseIP("93.184.216.34")) {
+		t.Errorf("Got answer %v, expected an A record.", d.Answers[1])
+	}
+	if d.Answers[1].TTL != 300 {
+		t.Errorf("Got TTL %d, expected 300.", d.Answers[1].TTL)
+	}
+}
+
+func TestDNSRecords(t *testing.T) {
+	d := NewQuery(1, "_sip._udp.example.com", Type_SRV)
+	d.Flags |= FLAG_QR
+	d.AddAnswer(NewResource("_sip._udp.example.com", 60, &SRV{10, 5, 5060, "sip.example.com"}))
+	d.AddAnswer(NewResource("example.com", 60, &TXT{[]string{"v=spf1", "-all"}}))
+	d.AddAnswer(NewResource("example.com", 60, &AAAA{net.ParseIP("2001:db8::1")}))
+	d.AddAnswer(NewResource("1.0.0.10.in-addr.arpa", 60, &PTR{"host.example.com"}))
+	d.AddAnswer(NewResource("example.com", 60, &Unknown{Type_MX, []byte{0, 10, 0}}))
+
+	data, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	if err = r.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Answers) != 5 {
+		t.Fatalf("Got %d answers, expected 5.", len(r.Answers))
+	}
+	if s := r.Answers[0].Data.(*SRV); *s != (SRV{10, 5, 5060, "sip.example.com"}) {
+		t.Errorf("Got SRV %v.", s)
+	}
+	if txt := r.Answers[1].Data.(*TXT); len(txt.Text) != 2 || txt.Text[1] != "-all" {
+		t.Errorf("Got TXT %v.", txt.Text)
+	}
+	if a := r.Answers[2].Data.(*AAAA); !a.IP.Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("Got AAAA %v.", a.IP)
+	}
+	if p := r.Answers[3].Data.(*PTR); p.Target != "host.example.com" {
+		t.Errorf("Got PTR %v.", p.Target)
+	}
+	if u := r.Answers[4].Data.(*Unknown); r.Answers[4].Type() != Type_MX || len(u.Data) != 3 {
+		t.Errorf("Got record %v.", r.Answers[4])
+	}
+}
+
+func TestDNSPointerLoop(t *testing.T) {
+	b := "   00 01 00 00 00 01 00 00 00 00 00 00 " + // Header
+		"c0 0c 00 01 00 01 " // Name pointing to itself
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	if err := New().UnmarshalBinary(data); err == nil {
+		t.Error("Expected an error unmarshaling a looping name.")
+	}
+}
+
+func TestUDPDispatch(t *testing.T) {
+	q, _ := NewQuery(7, "example.com", Type_AAAA).MarshalBinary()
+	u := udp.New()
+	u.PortSrc = 50000
+	u.PortDst = Port
+	u.Length = uint16(8 + len(q))
+	u.Data = nil
+	data, _ := u.MarshalBinary()
+	data = append(data, q...)
+
+	u = udp.New()
+	if err := u.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	d, ok := u.Data.(*DNS)
+	if !ok {
+		t.Fatalf("Got payload %T, expected *dns.DNS.", u.Data)
+	}
+	if d.Id != 7 || d.Questions[0].Type != Type_AAAA {
+		t.Errorf("Got query %v, expected an AAAA query.", d)
+	}
+}
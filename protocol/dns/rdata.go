@@ -0,0 +1,206 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// The type specific data of a resource record. Records of a type
+// this package does not know decode as Unknown.
+type RData interface {
+	Type() uint16
+	// Appends the data to the message being packed.
+	pack(p *packer) error
+	// Decodes msg[off:end]. Names may point elsewhere in msg.
+	unpack(msg []byte, off, end int) error
+}
+
+// An IPv4 address.
+type A struct {
+	IP net.IP
+}
+
+func (a *A) Type() uint16 {
+	return Type_A
+}
+
+func (a *A) pack(p *packer) error {
+	ip := a.IP.To4()
+	if ip == nil {
+		return errors.New("DNS A record holds no IPv4 address.")
+	}
+	p.buf = append(p.buf, ip...)
+	return nil
+}
+
+func (a *A) unpack(msg []byte, off, end int) error {
+	if end-off != 4 {
+		return util.NewMalformedError("DNS A record", "data length is not 4")
+	}
+	a.IP = make(net.IP, 4)
+	copy(a.IP, msg[off:end])
+	return nil
+}
+
+// An IPv6 address.
+type AAAA struct {
+	IP net.IP
+}
+
+func (a *AAAA) Type() uint16 {
+	return Type_AAAA
+}
+
+func (a *AAAA) pack(p *packer) error {
+	ip := a.IP.To16()
+	if ip == nil || a.IP.To4() != nil {
+		return errors.New("DNS AAAA record holds no IPv6 address.")
+	}
+	p.buf = append(p.buf, ip...)
+	return nil
+}
+
+func (a *AAAA) unpack(msg []byte, off, end int) error {
+	if end-off != 16 {
+		return util.NewMalformedError("DNS AAAA record", "data length is not 16")
+	}
+	a.IP = make(net.IP, 16)
+	copy(a.IP, msg[off:end])
+	return nil
+}
+
+// The canonical name of an alias.
+type CNAME struct {
+	Target string
+}
+
+func (c *CNAME) Type() uint16 {
+	return Type_CNAME
+}
+
+func (c *CNAME) pack(p *packer) error {
+	return p.name(c.Target, true)
+}
+
+func (c *CNAME) unpack(msg []byte, off, end int) (err error) {
+	c.Target, err = unpackNameIn(msg, off, end)
+	return
+}
+
+// The name a reverse lookup resolves to.
+type PTR struct {
+	Target string
+}
+
+func (r *PTR) Type() uint16 {
+	return Type_PTR
+}
+
+func (r *PTR) pack(p *packer) error {
+	return p.name(r.Target, true)
+}
+
+func (r *PTR) unpack(msg []byte, off, end int) (err error) {
+	r.Target, err = unpackNameIn(msg, off, end)
+	return
+}
+
+// One or more character strings of at most 255 bytes each.
+type TXT struct {
+	Text []string
+}
+
+func (t *TXT) Type() uint16 {
+	return Type_TXT
+}
+
+func (t *TXT) pack(p *packer) error {
+	for _, s := range t.Text {
+		if len(s) > 255 {
+			return errors.New("DNS TXT string is longer than 255 bytes.")
+		}
+		p.buf = append(p.buf, byte(len(s)))
+		p.buf = append(p.buf, s...)
+	}
+	return nil
+}
+
+func (t *TXT) unpack(msg []byte, off, end int) error {
+	t.Text = make([]string, 0)
+	for off < end {
+		l := int(msg[off])
+		if off+1+l > end {
+			return util.NewTruncatedError("DNS TXT record", off+1+l, end)
+		}
+		t.Text = append(t.Text, string(msg[off+1:off+1+l]))
+		off += 1 + l
+	}
+	return nil
+}
+
+// The location of a service, defined in RFC 2782.
+type SRV struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string
+}
+
+func (s *SRV) Type() uint16 {
+	return Type_SRV
+}
+
+// RFC 2782 forbids compressing the target.
+func (s *SRV) pack(p *packer) error {
+	p.uint16(s.Priority)
+	p.uint16(s.Weight)
+	p.uint16(s.Port)
+	return p.name(s.Target, false)
+}
+
+func (s *SRV) unpack(msg []byte, off, end int) (err error) {
+	if end-off < 7 {
+		return util.NewTruncatedError("DNS SRV record", 7, end-off)
+	}
+	s.Priority = binary.BigEndian.Uint16(msg[off:])
+	s.Weight = binary.BigEndian.Uint16(msg[off+2:])
+	s.Port = binary.BigEndian.Uint16(msg[off+4:])
+	s.Target, err = unpackNameIn(msg, off+6, end)
+	return
+}
+
+// The raw data of a record of any other type.
+type Unknown struct {
+	RRType uint16
+	Data   []byte
+}
+
+func (u *Unknown) Type() uint16 {
+	return u.RRType
+}
+
+func (u *Unknown) pack(p *packer) error {
+	p.buf = append(p.buf, u.Data...)
+	return nil
+}
+
+func (u *Unknown) unpack(msg []byte, off, end int) error {
+	u.Data = make([]byte, end-off)
+	copy(u.Data, msg[off:end])
+	return nil
+}
+
+// Reads a name that has to end by end.
+func unpackNameIn(msg []byte, off, end int) (string, error) {
+	name, n, err := unpackName(msg, off)
+	if err != nil {
+		return "", err
+	}
+	if n > end {
+		return "", util.NewMalformedError("DNS name", "it runs past the end of its record")
+	}
+	return name, nil
+}
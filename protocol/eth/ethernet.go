@@ -2,11 +2,14 @@ package eth
 
 import (
 	"encoding/binary"
-	"errors"
 	"net"
+	"sync"
 
 	"github.com/jonstout/ogo/protocol/arp"
 	"github.com/jonstout/ogo/protocol/ipv4"
+	"github.com/jonstout/ogo/protocol/ipv6"
+	"github.com/jonstout/ogo/protocol/lldp"
+	"github.com/jonstout/ogo/protocol/mpls"
 	"github.com/jonstout/ogo/protocol/util"
 )
 
@@ -18,17 +21,59 @@ const (
 	WOL_MSG  = 0x0842
 	RARP_MSG = 0x8035
 	VLAN_MSG = 0x8100
+	// 802.1ad service VLAN tag, the outer tag of QinQ frames.
+	QINQ_MSG = 0x88a8
+	// Outer tag used by QinQ equipment that predates 802.1ad.
+	QINQ_LEGACY_MSG = 0x9100
 
 	IPv6_MSG     = 0x86DD
+	MPLS_MSG     = 0x8847
+	MPLS_MC_MSG  = 0x8848
 	STP_MSG      = 0x4242
 	STP_BPDU_MSG = 0xAAAA
+	// Slow protocols such as LACP, 802.3 annex 57A.
+	SLOW_MSG = 0x8809
 )
 
+var ethertypes = struct {
+	sync.RWMutex
+	m map[uint16]func() util.Message
+}{m: map[uint16]func() util.Message{
+	IPv4_MSG:    func() util.Message { return ipv4.New() },
+	ARP_MSG:     func() util.Message { return new(arp.ARP) },
+	IPv6_MSG:    func() util.Message { return ipv6.New() },
+	LLDP_MSG:    func() util.Message { return new(lldp.LLDP) },
+	MPLS_MSG:    func() util.Message { return mpls.New() },
+	MPLS_MC_MSG: func() util.Message { return mpls.New() },
+}}
+
+// Registers fn as the constructor of the payload of frames with
+// the given ethertype, replacing any earlier registration.
+// Payloads with no registration decode as a util.Buffer.
+func RegisterEthertype(ethertype uint16, fn func() util.Message) {
+	ethertypes.Lock()
+	defer ethertypes.Unlock()
+	ethertypes.m[ethertype] = fn
+}
+
+func newPayload(ethertype uint16) util.Message {
+	ethertypes.RLock()
+	defer ethertypes.RUnlock()
+	if fn, ok := ethertypes.m[ethertype]; ok {
+		return fn()
+	}
+	return new(util.Buffer)
+}
+
+// Returns true if ethertype is the TPID of a VLAN tag.
+func IsVLAN(ethertype uint16) bool {
+	return ethertype == VLAN_MSG || ethertype == QINQ_MSG || ethertype == QINQ_LEGACY_MSG
+}
+
 type Ethernet struct {
-	Delimiter uint8
 	HWDst     net.HardwareAddr
 	HWSrc     net.HardwareAddr
-	VLANID    VLAN
+	VLANs     []VLAN // Outermost tag first.
 	Ethertype uint16
 	Data      util.Message
 }
@@ -37,16 +82,37 @@ func New() *Ethernet {
 	eth := new(Ethernet)
 	eth.HWDst = net.HardwareAddr(make([]byte, 6))
 	eth.HWSrc = net.HardwareAddr(make([]byte, 6))
-	eth.VLANID = *NewVLAN()
+	eth.VLANs = make([]VLAN, 0)
 	eth.Ethertype = 0x800
 	eth.Data = nil
 	return eth
 }
 
-func (e *Ethernet) Len() (n uint16) {
-	if e.VLANID.VID != 0 {
-		n += 5
+// Returns the outermost VLAN tag.
+func (e *Ethernet) VLAN() (v VLAN, ok bool) {
+	if len(e.VLANs) == 0 {
+		return
 	}
+	return e.VLANs[0], true
+}
+
+// Adds v as the new outermost VLAN tag.
+func (e *Ethernet) PushVLAN(v VLAN) {
+	e.VLANs = append([]VLAN{v}, e.VLANs...)
+}
+
+// Removes and returns the outermost VLAN tag.
+func (e *Ethernet) PopVLAN() (v VLAN, ok bool) {
+	if len(e.VLANs) == 0 {
+		return
+	}
+	v = e.VLANs[0]
+	e.VLANs = e.VLANs[1:]
+	return v, true
+}
+
+func (e *Ethernet) Len() (n uint16) {
+	n += uint16(4 * len(e.VLANs))
 	n += 12
 	n += 2
 	if e.Data != nil {
@@ -56,42 +122,37 @@ func (e *Ethernet) Len() (n uint16) {
 }
 
 func (e *Ethernet) MarshalBinary() (data []byte, err error) {
-	data = make([]byte, int(e.Len()))
-	bytes := make([]byte, 0)
-	n := 0
-	copy(data[n:], e.HWDst)
-	n += len(e.HWDst)
-	copy(data[n:], e.HWSrc)
-	n += len(e.HWSrc)
+	return e.AppendBinary(make([]byte, 0, int(e.Len())))
+}
 
-	if e.VLANID.VID != 0 {
-		bytes, err = e.VLANID.MarshalBinary()
-		if err != nil {
-			return
-		}
-		copy(data[n:], bytes)
-		n += len(bytes)
+func (e *Ethernet) AppendBinary(b []byte) (data []byte, err error) {
+	n := len(b)
+	data = util.Extend(b, 12)
+	copy(data[n:n+6], e.HWDst)
+	n += 6
+	copy(data[n:n+6], e.HWSrc)
+	n += 6
+
+	for _, v := range e.VLANs {
+		data, _ = v.AppendBinary(data)
 	}
 
+	n = len(data)
+	data = util.Extend(data, 2)
 	binary.BigEndian.PutUint16(data[n:n+2], e.Ethertype)
-	n += 2
 
 	if e.Data != nil {
-		bytes, err = e.Data.MarshalBinary()
-		if err != nil {
-			return
-		}
-		copy(data[n:n+len(bytes)], bytes)
+		data, err = util.AppendBinary(data, e.Data)
 	}
 	return
 }
 
 func (e *Ethernet) UnmarshalBinary(data []byte) error {
 	if len(data) < 14 {
-		return errors.New("The []byte is too short to unmarshal a full Ethernet message.")
+		return util.NewTruncatedError("Ethernet message", 14, len(data))
 	}
 
-	n := 1
+	n := 0
 
 	e.HWDst = net.HardwareAddr(make([]byte, 6))
 	e.HWSrc = net.HardwareAddr(make([]byte, 6))
@@ -102,50 +163,77 @@ func (e *Ethernet) UnmarshalBinary(data []byte) error {
 	copy(e.HWSrc, data[n:])
 	n += len(e.HWSrc)
 
+	e.VLANs = make([]VLAN, 0)
 	e.Ethertype = binary.BigEndian.Uint16(data[n:])
-	if e.Ethertype == VLAN_MSG {
-		e.VLANID = *new(VLAN)
-		err := e.VLANID.UnmarshalBinary(data[n:])
+	for IsVLAN(e.Ethertype) {
+		v := VLAN{}
+		err := v.UnmarshalBinary(data[n:])
 		if err != nil {
 			return err
 		}
-		n += int(e.VLANID.Len())
+		e.VLANs = append(e.VLANs, v)
+		n += int(v.Len())
 
+		if len(data) < n+2 {
+			return util.NewTruncatedError("Ethernet message", n+2, len(data))
+		}
 		e.Ethertype = binary.BigEndian.Uint16(data[n:])
-	} else {
-		e.VLANID = *new(VLAN)
-		e.VLANID.VID = 0
 	}
 	n += 2
 
-	switch e.Ethertype {
-	case IPv4_MSG:
-		e.Data = new(ipv4.IPv4)
-	case ARP_MSG:
-		e.Data = new(arp.ARP)
-	default:
-		e.Data = new(util.Buffer)
+	// An 802.3 frame carries its length in place of the ethertype.
+	// Anything past it is padding.
+	if e.Ethertype <= MAX_LENGTH {
+		end := len(data)
+		if n+int(e.Ethertype) < end {
+			end = n + int(e.Ethertype)
+		}
+		e.Data = new(LLC)
+		return e.Data.UnmarshalBinary(data[n:end])
 	}
+
+	e.Data = newPayload(e.Ethertype)
 	return e.Data.UnmarshalBinary(data[n:])
 }
 
+// Sets the length of 802.3 frames from their LLC payload.
+func (e *Ethernet) FixLengths() error {
+	if l, ok := e.Data.(*LLC); ok {
+		e.Ethertype = l.Len()
+	}
+	return nil
+}
+
+func (e *Ethernet) Payload() util.Message {
+	return e.Data
+}
+
 const (
 	PCP_MASK = 0xe000
 	DEI_MASK = 0x1000
 	VID_MASK = 0x0fff
 )
 
+// An 802.1Q tag, or an 802.1ad service tag when TPID is QINQ_MSG.
 type VLAN struct {
 	TPID uint16
-	PCP  uint8
-	DEI  uint8
-	VID  uint8
+	PCP  uint8  //3-bits
+	DEI  uint8  //1-bit
+	VID  uint16 //12-bits
 }
 
-func NewVLAN() *VLAN {
+// Returns an 802.1Q tag for vid.
+func NewVLAN(vid uint16) *VLAN {
 	v := new(VLAN)
-	v.TPID = 0x8100
-	v.VID = 0
+	v.TPID = VLAN_MSG
+	v.VID = vid & VID_MASK
+	return v
+}
+
+// Returns an 802.1ad service tag for vid.
+func NewServiceVLAN(vid uint16) *VLAN {
+	v := NewVLAN(vid)
+	v.TPID = QINQ_MSG
 	return v
 }
 
@@ -154,23 +242,28 @@ func (v *VLAN) Len() (n uint16) {
 }
 
 func (v *VLAN) MarshalBinary() (data []byte, err error) {
-	data = make([]byte, v.Len())
-	binary.BigEndian.PutUint16(data[:2], v.TPID)
+	return v.AppendBinary(make([]byte, 0, int(v.Len())))
+}
+
+func (v *VLAN) AppendBinary(b []byte) (data []byte, err error) {
+	n := len(b)
+	data = util.Extend(b, int(v.Len()))
+	binary.BigEndian.PutUint16(data[n:n+2], v.TPID)
 	var tci uint16
-	tci = (tci | uint16(v.PCP)<<13) + (tci | uint16(v.DEI)<<12) + (tci | uint16(v.VID))
-	binary.BigEndian.PutUint16(data[2:], tci)
+	tci = uint16(v.PCP)<<13&PCP_MASK | uint16(v.DEI)<<12&DEI_MASK | v.VID&VID_MASK
+	binary.BigEndian.PutUint16(data[n+2:], tci)
 	return
 }
 
 func (v *VLAN) UnmarshalBinary(data []byte) error {
 	if len(data) < 4 {
-		return errors.New("The []byte is too short to unmarshal a full VLAN header.")
+		return util.NewTruncatedError("VLAN header", 4, len(data))
 	}
 	v.TPID = binary.BigEndian.Uint16(data[:2])
 	var tci uint16
 	tci = binary.BigEndian.Uint16(data[2:])
 	v.PCP = uint8(PCP_MASK & tci >> 13)
 	v.DEI = uint8(DEI_MASK & tci >> 12)
-	v.VID = uint8(VID_MASK & tci)
+	v.VID = VID_MASK & tci
 	return nil
 }
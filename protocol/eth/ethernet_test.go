@@ -6,6 +6,15 @@ import (
 	"net"
 	"strings"
 	"testing"
+
+	"github.com/jonstout/ogo/protocol/arp"
+	"github.com/jonstout/ogo/protocol/icmpv6"
+	"github.com/jonstout/ogo/protocol/ipv4"
+	"github.com/jonstout/ogo/protocol/ipv6"
+	"github.com/jonstout/ogo/protocol/lldp"
+	"github.com/jonstout/ogo/protocol/mpls"
+	"github.com/jonstout/ogo/protocol/udp"
+	"github.com/jonstout/ogo/protocol/util"
 )
 
 func TestEthMarshalBinary(t *testing.T) {
@@ -27,8 +36,7 @@ func TestEthMarshalBinary(t *testing.T) {
 }
 
 func TestEthUnmarshalBinary(t *testing.T) {
-	b := "   00 " + // Delim
-	        "0a b0 0c 0d e0 0f " + // HWDst
+	b := "   0a b0 0c 0d e0 0f " + // HWDst
 		"00 00 00 00 00 ff " + // HWSrc
 		"88 00 " // Ethertype
 	b = strings.Replace(b, " ", "", -1)
@@ -42,8 +50,8 @@ func TestEthUnmarshalBinary(t *testing.T) {
 	dst, _ := net.ParseMAC("0a:b0:0c:0d:e0:0f")
 	src, _ := net.ParseMAC("00:00:00:00:00:ff")
 
-	if int(a.Len()) != (len(byte) - 1) {
-		t.Errorf("Got length of %d, expected %d.", a.Len(), (len(byte) - 1))
+	if int(a.Len()) != len(byte) {
+		t.Errorf("Got length of %d, expected %d.", a.Len(), len(byte))
 	} else if a.Ethertype != 0x8800 {
 		t.Errorf("Got type %d, expected %d.", a.Ethertype, 0x0880)
 	} else if bytes.Compare(a.HWDst, dst) != 0 {
@@ -56,3 +64,193 @@ func TestEthUnmarshalBinary(t *testing.T) {
 		t.Errorf("Received length of %d, expected %d", len(a.HWSrc), len(src))
 	}
 }
+
+func TestEthQinQ(t *testing.T) {
+	b := "   0a b0 0c 0d e0 0f " + // HWDst
+		"00 00 00 00 00 ff " + // HWSrc
+		"88 a8 a0 64 " + // Service tag, PCP 5, VID 100
+		"81 00 0f a0 " + // Customer tag, VID 4000
+		"88 00 " // Ethertype
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	e := New()
+	if err := e.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if int(e.Len()) != len(data) {
+		t.Errorf("Got length of %d, expected %d.", e.Len(), len(data))
+	}
+	if e.Ethertype != 0x8800 {
+		t.Errorf("Got type %x, expected %x.", e.Ethertype, 0x8800)
+	}
+	if len(e.VLANs) != 2 {
+		t.Fatalf("Got %d VLAN tags, expected 2.", len(e.VLANs))
+	}
+	if v := e.VLANs[0]; v.TPID != QINQ_MSG || v.PCP != 5 || v.VID != 100 {
+		t.Errorf("Got outer tag %+v, expected VID 100 PCP 5.", v)
+	}
+	if v := e.VLANs[1]; v.TPID != VLAN_MSG || v.VID != 4000 {
+		t.Errorf("Got inner tag %+v, expected VID 4000.", v)
+	}
+
+	out, _ := e.MarshalBinary()
+	d := hex.EncodeToString(out)
+	if (len(b) != len(d)) || (b != d) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", d)
+		t.Errorf("Received length of %d, expected %d", len(d), len(b))
+	}
+}
+
+func TestEthPushPopVLAN(t *testing.T) {
+	e := New()
+	e.PushVLAN(*NewVLAN(4000))
+	e.PushVLAN(*NewServiceVLAN(100))
+	if e.Len() != 22 {
+		t.Errorf("Got length of %d, expected 22.", e.Len())
+	}
+	if v, ok := e.VLAN(); !ok || v.VID != 100 {
+		t.Errorf("Got outer tag %+v, expected VID 100.", v)
+	}
+	e.PopVLAN()
+	if v, ok := e.PopVLAN(); !ok || v.VID != 4000 {
+		t.Errorf("Got tag %+v, expected VID 4000.", v)
+	}
+	if _, ok := e.PopVLAN(); ok {
+		t.Error("Popped a tag from an untagged frame.")
+	}
+}
+
+func TestEthLLDP(t *testing.T) {
+	b := "   01 80 c2 00 00 0e 00 11 22 33 44 55 88 cc " + // Ethernet
+		"02 07 04 00 11 22 33 44 55 " + // Chassis ID
+		"04 03 07 00 05 " + // Port ID
+		"06 02 00 78 " + // TTL
+		"00 00 " // End
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	e := New()
+	if err := e.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if d, ok := e.Data.(*lldp.LLDP); !ok || d.TTL.Seconds != 120 {
+		t.Errorf("Got payload %v, expected an LLDPDU.", e.Data)
+	}
+}
+
+func TestEthLLC(t *testing.T) {
+	b := "   01 80 c2 00 00 00 " + // HWDst
+		"00 00 00 00 00 ff " + // HWSrc
+		"00 07 " + // Length
+		"42 42 03 " + // DSAP, SSAP, Control
+		"00 00 00 80 " + // Data
+		"00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 " +
+		"00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 " // Padding
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	e := New()
+	if err := e.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	l, ok := e.Data.(*LLC)
+	if !ok {
+		t.Fatalf("Got payload %T, expected *LLC.", e.Data)
+	}
+	if l.DSAP != SAP_STP || l.SSAP != SAP_STP || !l.Unnumbered() {
+		t.Errorf("Got LLC %02x %02x %04x.", l.DSAP, l.SSAP, l.Control)
+	}
+	if l.Data.Len() != 4 {
+		t.Errorf("Got %d bytes of data, expected 4.", l.Data.Len())
+	}
+
+	e.Ethertype = 0
+	e.FixLengths()
+	if e.Ethertype != 7 {
+		t.Errorf("Got length %d, expected 7.", e.Ethertype)
+	}
+}
+
+func TestEthSNAP(t *testing.T) {
+	b := "   ff ff ff ff ff ff " + // HWDst
+		"00 00 00 00 00 ff " + // HWSrc
+		"00 24 " + // Length
+		"aa aa 03 " + // DSAP, SSAP, Control
+		"00 00 00 08 06 " + // OUI, ProtocolId
+		"00 01 08 00 06 04 00 01 00 00 00 00 00 ff 0a 00 00 01 " + // ARP
+		"00 00 00 00 00 00 0a 00 00 02 "
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	e := New()
+	if err := e.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	l, ok := e.Data.(*LLC)
+	if !ok || !l.IsSNAP() {
+		t.Fatalf("Got payload %T, expected a SNAP *LLC.", e.Data)
+	}
+	if _, ok := l.Data.(*arp.ARP); !ok {
+		t.Errorf("Got SNAP payload %T, expected *arp.ARP.", l.Data)
+	}
+
+	out, err := e.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := hex.EncodeToString(out); s != b {
+		t.Log("Exp:", b)
+		t.Log("Rec:", s)
+		t.Errorf("Received length of %d, expected %d", len(s), len(b))
+	}
+}
+
+func FuzzEthernet(f *testing.F) {
+	seeds := []string{
+		// LLDP
+		"0180c200000e00112233445588cc0207040011223344550403070005060200780000",
+		// 802.3 LLC
+		"0180c20000000000000000ff0007424203000000800000000000000000000000000000000000",
+		// SNAP ARP
+		"ffffffffffff0000000000ff0024aaaa030000000806" +
+			"0001080006040001000000000000ff0a0000010000000000000a000002",
+		// QinQ
+		"0ab00c0de00f0000000000ff88a8a06481000fa08800",
+	}
+	for _, s := range seeds {
+		data, _ := hex.DecodeString(s)
+		f.Add(data)
+	}
+
+	ip := ipv4.New()
+	ip.Protocol = ipv4.Type_UDP
+	ip.Data = udp.New()
+	ip6 := ipv6.New()
+	ip6.NextHeader = ipv6.Type_ICMPv6
+	ip6.Data = icmpv6.NewMessage(icmpv6.Type_NeighborSolicitation,
+		icmpv6.NewNeighborSolicitation(net.ParseIP("fe80::1"), net.HardwareAddr{0, 0, 0, 0, 0, 1}))
+	stack := mpls.New()
+	stack.Labels = append(stack.Labels, mpls.NewLabel(16, 64))
+	stack.Data = ipv4.New()
+	payloads := map[uint16]util.Message{
+		IPv4_MSG: ip,
+		IPv6_MSG: ip6,
+		MPLS_MSG: stack,
+	}
+	for t, p := range payloads {
+		e := New()
+		e.PushVLAN(*NewVLAN(100))
+		e.Ethertype = t
+		e.Data = p
+		if data, err := e.MarshalBinary(); err == nil {
+			f.Add(data)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Unmarshaling must fail with an error rather than panic.
+		New().UnmarshalBinary(data)
+	})
+}
@@ -0,0 +1,172 @@
+package eth
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Ethertype values up to this are the length of an 802.3 frame,
+// whose payload starts with an LLC header.
+const MAX_LENGTH = 1500
+
+// Service access points
+const (
+	SAP_STP  = 0x42
+	SAP_SNAP = 0xaa
+)
+
+var saps = struct {
+	sync.RWMutex
+	m map[uint8]func() util.Message
+}{m: make(map[uint8]func() util.Message)}
+
+// Registers fn as the constructor of the payload of LLC frames sent
+// to the service access point sap, replacing any earlier
+// registration. Payloads with no registration decode as a
+// util.Buffer.
+func RegisterSAP(sap uint8, fn func() util.Message) {
+	saps.Lock()
+	defer saps.Unlock()
+	saps.m[sap] = fn
+}
+
+func newSAPPayload(sap uint8) util.Message {
+	saps.RLock()
+	defer saps.RUnlock()
+	if fn, ok := saps.m[sap]; ok {
+		return fn()
+	}
+	return new(util.Buffer)
+}
+
+// An 802.2 LLC header, followed by a SNAP header when DSAP and SSAP
+// are SAP_SNAP. SNAP payloads with an OUI of zero decode by
+// ProtocolId as an ethertype.
+type LLC struct {
+	DSAP       uint8
+	SSAP       uint8
+	Control    uint16 // 8 bits for unnumbered frames, 16 otherwise.
+	OUI        uint32 //24-bits
+	ProtocolId uint16
+	Data       util.Message
+}
+
+// Returns an unnumbered information frame to and from sap.
+func NewLLC(sap uint8) *LLC {
+	l := new(LLC)
+	l.DSAP = sap
+	l.SSAP = sap
+	l.Control = 0x03
+	return l
+}
+
+// Returns a SNAP frame carrying a payload of the given ethertype.
+func NewSNAP(ethertype uint16) *LLC {
+	l := NewLLC(SAP_SNAP)
+	l.ProtocolId = ethertype
+	return l
+}
+
+// Returns true if the control field is one byte long.
+func (l *LLC) Unnumbered() bool {
+	return l.Control&0x03 == 0x03
+}
+
+func (l *LLC) IsSNAP() bool {
+	return l.DSAP == SAP_SNAP && l.SSAP == SAP_SNAP
+}
+
+func (l *LLC) HeaderLen() (n uint16) {
+	n = 3
+	if !l.Unnumbered() {
+		n += 1
+	}
+	if l.IsSNAP() {
+		n += 5
+	}
+	return
+}
+
+func (l *LLC) Len() (n uint16) {
+	n = l.HeaderLen()
+	if l.Data != nil {
+		n += l.Data.Len()
+	}
+	return
+}
+
+func (l *LLC) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(l.HeaderLen()), int(l.Len()))
+	n := 0
+	data[n] = l.DSAP
+	n += 1
+	data[n] = l.SSAP
+	n += 1
+	if l.Unnumbered() {
+		data[n] = uint8(l.Control)
+		n += 1
+	} else {
+		binary.BigEndian.PutUint16(data[n:], l.Control)
+		n += 2
+	}
+	if l.IsSNAP() {
+		data[n] = uint8(l.OUI >> 16)
+		data[n+1] = uint8(l.OUI >> 8)
+		data[n+2] = uint8(l.OUI)
+		n += 3
+		binary.BigEndian.PutUint16(data[n:], l.ProtocolId)
+		n += 2
+	}
+	if l.Data != nil {
+		var b []byte
+		if b, err = l.Data.MarshalBinary(); err != nil {
+			return
+		}
+		data = append(data, b...)
+	}
+	return
+}
+
+func (l *LLC) UnmarshalBinary(data []byte) error {
+	if len(data) < 3 {
+		l.Data = nil
+		return util.NewTruncatedError("LLC message", 3, len(data))
+	}
+	n := 0
+	l.DSAP = data[n]
+	n += 1
+	l.SSAP = data[n]
+	n += 1
+	l.Control = uint16(data[n])
+	if len(data) < int(l.HeaderLen()) {
+		l.Data = nil
+		return util.NewTruncatedError("LLC message", int(l.HeaderLen()), len(data))
+	}
+	if l.Unnumbered() {
+		n += 1
+	} else {
+		l.Control = binary.BigEndian.Uint16(data[n:])
+		n += 2
+	}
+
+	if l.IsSNAP() {
+		l.OUI = uint32(data[n])<<16 | uint32(data[n+1])<<8 | uint32(data[n+2])
+		n += 3
+		l.ProtocolId = binary.BigEndian.Uint16(data[n:])
+		n += 2
+		if l.OUI == 0 {
+			l.Data = newPayload(l.ProtocolId)
+		} else {
+			l.Data = new(util.Buffer)
+		}
+	} else {
+		l.Data = newSAPPayload(l.DSAP)
+	}
+	return l.Data.UnmarshalBinary(data[n:])
+}
+
+func (l *LLC) Payload() util.Message {
+	return l.Data
+}
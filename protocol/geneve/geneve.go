@@ -0,0 +1,225 @@
+// Package geneve implements Generic Network Virtualization
+// Encapsulation, RFC 8926.
+//
+// Importing geneve registers it as the payload of UDP port 6081.
+package geneve
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/ipv4"
+	"github.com/jonstout/ogo/protocol/ipv6"
+	"github.com/jonstout/ogo/protocol/packet"
+	"github.com/jonstout/ogo/protocol/udp"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// UDP port of Geneve.
+const Port = 6081
+
+// Protocol type of bridged Ethernet frames.
+const Type_TransparentEthernet = 0x6558
+
+func init() {
+	udp.RegisterPort(Port, func() util.Message { return New() })
+}
+
+// Flags
+const (
+	FLAG_OAM      = 1 << 7 // Control message
+	FLAG_CRITICAL = 1 << 6 // Critical options present
+)
+
+type Geneve struct {
+	Version  uint8 //2-bits
+	Flags    uint8
+	Protocol uint16
+	VNI      uint32 //24-bits
+	Options  []Option
+	Data     util.Message
+}
+
+func New() *Geneve {
+	g := new(Geneve)
+	g.Protocol = Type_TransparentEthernet
+	g.Options = make([]Option, 0)
+	g.Data = eth.New()
+	return g
+}
+
+// Returns a Geneve packet carrying inner on network vni.
+func NewGeneve(vni uint32, inner *eth.Ethernet) *Geneve {
+	g := New()
+	g.VNI = vni & 0xffffff
+	g.Data = inner
+	return g
+}
+
+// Appends o, marking the packet as carrying critical options if o
+// is one.
+func (g *Geneve) AddOption(o Option) {
+	g.Options = append(g.Options, o)
+	if o.Critical() {
+		g.Flags |= FLAG_CRITICAL
+	}
+}
+
+// Returns the first option of class and type t.
+func (g *Geneve) Option(class uint16, t uint8) (o Option, ok bool) {
+	for _, o = range g.Options {
+		if o.Class == class && o.Type == t {
+			return o, true
+		}
+	}
+	return Option{}, false
+}
+
+func (g *Geneve) HeaderLen() (n uint16) {
+	n = 8
+	for _, o := range g.Options {
+		n += o.Len()
+	}
+	return
+}
+
+func (g *Geneve) Len() (n uint16) {
+	n = g.HeaderLen()
+	if g.Data != nil {
+		n += g.Data.Len()
+	}
+	return
+}
+
+func (g *Geneve) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 8, int(g.Len()))
+	optLen := (g.HeaderLen() - 8) / 4
+	if optLen > 0x3f {
+		return nil, errors.New("Geneve options are longer than 252 bytes.")
+	}
+	data[0] = g.Version<<6 | uint8(optLen)
+	data[1] = g.Flags
+	binary.BigEndian.PutUint16(data[2:], g.Protocol)
+	binary.BigEndian.PutUint32(data[4:], g.VNI<<8)
+	for _, o := range g.Options {
+		var b []byte
+		if b, err = o.MarshalBinary(); err != nil {
+			return
+		}
+		data = append(data, b...)
+	}
+	if g.Data != nil {
+		var b []byte
+		if b, err = g.Data.MarshalBinary(); err != nil {
+			return
+		}
+		data = append(data, b...)
+	}
+	return
+}
+
+func (g *Geneve) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		g.Data = nil
+		return util.NewTruncatedError("Geneve message", 8, len(data))
+	}
+	g.Version = data[0] >> 6
+	hdr := 8 + int(data[0]&0x3f)*4
+	g.Flags = data[1]
+	g.Protocol = binary.BigEndian.Uint16(data[2:])
+	g.VNI = binary.BigEndian.Uint32(data[4:]) >> 8
+	if len(data) < hdr {
+		g.Data = nil
+		return util.NewTruncatedError("Geneve message", hdr, len(data))
+	}
+
+	g.Options = make([]Option, 0)
+	for n := 8; n < hdr; {
+		o := Option{}
+		if err := o.UnmarshalBinary(data[n:hdr]); err != nil {
+			g.Data = nil
+			return err
+		}
+		g.Options = append(g.Options, o)
+		n += int(o.Len())
+	}
+
+	switch g.Protocol {
+	case Type_TransparentEthernet:
+		g.Data = eth.New()
+	case eth.IPv4_MSG:
+		g.Data = ipv4.New()
+	case eth.IPv6_MSG:
+		g.Data = ipv6.New()
+	default:
+		g.Data = new(util.Buffer)
+	}
+	return g.Data.UnmarshalBinary(data[hdr:])
+}
+
+func (g *Geneve) Payload() util.Message {
+	return g.Data
+}
+
+// A tunnel option. Data is padded to a multiple of 4 bytes.
+type Option struct {
+	Class uint16
+	Type  uint8
+	Data  []byte
+}
+
+// Returns true if a tunnel endpoint that does not understand o must
+// drop the packet.
+func (o *Option) Critical() bool {
+	return o.Type&0x80 != 0
+}
+
+func (o *Option) Len() (n uint16) {
+	return uint16(4 + (len(o.Data)+3)&^3)
+}
+
+func (o *Option) MarshalBinary() (data []byte, err error) {
+	if len(o.Data) > 124 {
+		return nil, errors.New("Geneve option data is longer than 124 bytes.")
+	}
+	data = make([]byte, int(o.Len()))
+	binary.BigEndian.PutUint16(data[0:], o.Class)
+	data[2] = o.Type
+	data[3] = uint8(len(data)-4) / 4
+	copy(data[4:], o.Data)
+	return
+}
+
+func (o *Option) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return util.NewTruncatedError("Geneve option", 4, len(data))
+	}
+	o.Class = binary.BigEndian.Uint16(data[0:])
+	o.Type = data[2]
+	length := int(data[3]&0x1f) * 4
+	if len(data) < 4+length {
+		return util.NewTruncatedError("Geneve option", 4+length, len(data))
+	}
+	o.Data = make([]byte, length)
+	copy(o.Data, data[4:])
+	return nil
+}
+
+// Returns a frame from hwSrc to hwDst carrying g in a UDP datagram
+// from src to dst. The source port is derived from the inner frame
+// so that routers keep its flows on one path. Lengths and checksums
+// are left for packet.Serialize.
+func NewFrame(hwSrc, hwDst net.HardwareAddr, src, dst net.IP, g *Geneve) *eth.Ethernet {
+	var key []byte
+	if e, ok := g.Data.(*eth.Ethernet); ok {
+		key = append(key, e.HWSrc...)
+		key = append(key, e.HWDst...)
+	}
+	u := udp.New()
+	u.PortSrc = udp.EntropyPort(key)
+	u.PortDst = Port
+	u.Data = g
+	return packet.NewIPFrame(hwSrc, hwDst, src, dst, udp.Type_UDP, u)
+}
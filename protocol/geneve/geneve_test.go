@@ -0,0 +1,49 @@
+package geneve
+
+import (
+	"net"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/packet"
+	"github.com/jonstout/ogo/protocol/udp"
+)
+
+func TestGeneveRoundTrip(t *testing.T) {
+	inner := eth.New()
+	inner.HWSrc[5] = 0xaa
+	inner.HWDst[5] = 0xbb
+	inner.Ethertype = 0x8800
+
+	g := NewGeneve(5000, inner)
+	g.AddOption(Option{0x0102, 0x80, []byte{1, 2, 3, 4, 5}})
+	if g.Flags&FLAG_CRITICAL == 0 || g.HeaderLen() != 20 {
+		t.Errorf("Got flags %x and header length %d.", g.Flags, g.HeaderLen())
+	}
+
+	mac, _ := net.ParseMAC("00:00:00:00:00:01")
+	e := NewFrame(mac, mac, net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), g)
+	data, err := packet.Serialize(e, packet.SerializeOptions{FixLengths: true, ComputeChecksums: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := packet.Decode(data)
+	if p.Err != nil {
+		t.Fatal(p.Err)
+	}
+	var u *udp.UDP
+	if !p.Layer(&u) || u.PortDst != Port || u.PortSrc < 49152 {
+		t.Errorf("Got UDP layer %v.", u)
+	}
+	var r *Geneve
+	if !p.Layer(&r) || r.VNI != 5000 || len(r.Options) != 1 {
+		t.Fatalf("Got Geneve layer %v.", r)
+	}
+	if o, ok := r.Option(0x0102, 0x80); !ok || len(o.Data) != 8 || o.Data[4] != 5 {
+		t.Errorf("Got option %v, expected 5 bytes padded to 8.", o)
+	}
+	if i, ok := r.Data.(*eth.Ethernet); !ok || i.HWDst.String() != inner.HWDst.String() {
+		t.Errorf("Got inner frame %v.", r.Data)
+	}
+}
@@ -0,0 +1,188 @@
+// Package gre implements Generic Routing Encapsulation, RFC 2784,
+// with the key and sequence number extensions of RFC 2890.
+//
+// Importing gre registers it as IP protocol 47 for IPv4 and IPv6.
+package gre
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/ipv4"
+	"github.com/jonstout/ogo/protocol/ipv6"
+	"github.com/jonstout/ogo/protocol/packet"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// IP protocol number of GRE.
+const Type_GRE = 0x2f
+
+// Protocol type of bridged Ethernet frames, as used by NVGRE and
+// Ethernet over GRE.
+const Type_TransparentEthernet = 0x6558
+
+func init() {
+	ipv4.RegisterProtocol(Type_GRE, func() util.Message { return New() })
+	ipv6.RegisterProtocol(Type_GRE, func() util.Message { return New() })
+}
+
+// Flags
+const (
+	FLAG_CSUM = 1 << 15 // Checksum present
+	FLAG_KEY  = 1 << 13 // Key present
+	FLAG_SEQ  = 1 << 12 // Sequence number present
+
+	VERSION_MASK = 0x0007
+)
+
+type GRE struct {
+	Flags    uint16 // Including the 3-bit version.
+	Protocol uint16
+	Checksum uint16
+	Key      uint32
+	Seq      uint32
+	Data     util.Message
+}
+
+func New() *GRE {
+	g := new(GRE)
+	g.Data = new(util.Buffer)
+	return g
+}
+
+// Returns a GRE packet carrying the Ethernet frame inner.
+func NewEthernet(inner *eth.Ethernet) *GRE {
+	g := new(GRE)
+	g.Protocol = Type_TransparentEthernet
+	g.Data = inner
+	return g
+}
+
+// Sets the key and the flag marking it present.
+func (g *GRE) SetKey(key uint32) {
+	g.Key = key
+	g.Flags |= FLAG_KEY
+}
+
+// Sets the sequence number and the flag marking it present.
+func (g *GRE) SetSeq(seq uint32) {
+	g.Seq = seq
+	g.Flags |= FLAG_SEQ
+}
+
+func (g *GRE) HeaderLen() (n uint16) {
+	n = 4
+	if g.Flags&FLAG_CSUM != 0 {
+		n += 4
+	}
+	if g.Flags&FLAG_KEY != 0 {
+		n += 4
+	}
+	if g.Flags&FLAG_SEQ != 0 {
+		n += 4
+	}
+	return
+}
+
+func (g *GRE) Len() (n uint16) {
+	n = g.HeaderLen()
+	if g.Data != nil {
+		n += g.Data.Len()
+	}
+	return
+}
+
+func (g *GRE) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(g.HeaderLen()), int(g.Len()))
+	n := 0
+	binary.BigEndian.PutUint16(data[n:], g.Flags)
+	n += 2
+	binary.BigEndian.PutUint16(data[n:], g.Protocol)
+	n += 2
+	if g.Flags&FLAG_CSUM != 0 {
+		binary.BigEndian.PutUint16(data[n:], g.Checksum)
+		n += 4 // Checksum and reserved
+	}
+	if g.Flags&FLAG_KEY != 0 {
+		binary.BigEndian.PutUint32(data[n:], g.Key)
+		n += 4
+	}
+	if g.Flags&FLAG_SEQ != 0 {
+		binary.BigEndian.PutUint32(data[n:], g.Seq)
+		n += 4
+	}
+	if g.Data != nil {
+		var b []byte
+		if b, err = g.Data.MarshalBinary(); err != nil {
+			return
+		}
+		data = append(data, b...)
+	}
+	return
+}
+
+func (g *GRE) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		g.Data = nil
+		return util.NewTruncatedError("GRE message", 4, len(data))
+	}
+	n := 0
+	g.Flags = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	g.Protocol = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	if len(data) < int(g.HeaderLen()) {
+		g.Data = nil
+		return util.NewTruncatedError("GRE message", int(g.HeaderLen()), len(data))
+	}
+	if g.Flags&FLAG_CSUM != 0 {
+		g.Checksum = binary.BigEndian.Uint16(data[n:])
+		n += 4
+	}
+	if g.Flags&FLAG_KEY != 0 {
+		g.Key = binary.BigEndian.Uint32(data[n:])
+		n += 4
+	}
+	if g.Flags&FLAG_SEQ != 0 {
+		g.Seq = binary.BigEndian.Uint32(data[n:])
+		n += 4
+	}
+
+	switch g.Protocol {
+	case Type_TransparentEthernet:
+		g.Data = eth.New()
+	case eth.IPv4_MSG:
+		g.Data = ipv4.New()
+	case eth.IPv6_MSG:
+		g.Data = ipv6.New()
+	default:
+		g.Data = new(util.Buffer)
+	}
+	return g.Data.UnmarshalBinary(data[n:])
+}
+
+func (g *GRE) Payload() util.Message {
+	return g.Data
+}
+
+// Computes and sets the checksum if FLAG_CSUM is set.
+func (g *GRE) SetChecksum() error {
+	if g.Flags&FLAG_CSUM == 0 {
+		return nil
+	}
+	g.Checksum = 0
+	data, err := g.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	g.Checksum = util.Checksum(data)
+	return nil
+}
+
+// Returns a frame from hwSrc to hwDst carrying g in an IP packet
+// from src to dst. Lengths and checksums are left for
+// packet.Serialize.
+func NewFrame(hwSrc, hwDst net.HardwareAddr, src, dst net.IP, g *GRE) *eth.Ethernet {
+	return packet.NewIPFrame(hwSrc, hwDst, src, dst, Type_GRE, g)
+}
@@ -0,0 +1,70 @@
+package gre
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/ipv4"
+)
+
+func TestGREUnmarshalBinary(t *testing.T) {
+	b := "   45 00 00 34 00 00 00 00 40 2f 00 00 " + // IPv4
+		"0a 00 00 01 0a 00 00 02 " +
+		"20 00 65 58 00 00 00 2a " + // GRE, key 42
+		"00 00 00 00 00 bb 00 00 00 00 00 aa 88 00 " + // Inner Ethernet
+		"01 02 03 04 05 06 " // Data
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	ip := ipv4.New()
+	if err := ip.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	g, ok := ip.Data.(*GRE)
+	if !ok {
+		t.Fatalf("Got payload %T, expected *gre.GRE.", ip.Data)
+	}
+	if g.Flags&FLAG_KEY == 0 || g.Key != 42 || g.HeaderLen() != 8 {
+		t.Errorf("Got flags %x and key %d, expected key 42.", g.Flags, g.Key)
+	}
+	if inner, ok := g.Data.(*eth.Ethernet); !ok || inner.Ethertype != 0x8800 {
+		t.Errorf("Got inner frame %v.", g.Data)
+	}
+
+	out, _ := g.MarshalBinary()
+	if d := hex.EncodeToString(out); d != b[40:] {
+		t.Log("Exp:", b[40:])
+		t.Log("Rec:", d)
+		t.Error("GRE did not marshal back to the bytes it was unmarshaled from.")
+	}
+}
+
+func TestGREChecksum(t *testing.T) {
+	g := NewEthernet(eth.New())
+	g.Flags |= FLAG_CSUM
+	g.SetSeq(7)
+	if err := g.SetChecksum(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := g.MarshalBinary()
+	if g.HeaderLen() != 12 || ^onesSum(data) != 0 {
+		t.Errorf("Got checksum %x over %x.", g.Checksum, data)
+	}
+}
+
+// Returns the one's complement sum of data.
+func onesSum(data []byte) uint16 {
+	var s uint32
+	for i := 0; i+1 < len(data); i += 2 {
+		s += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if len(data)%2 == 1 {
+		s += uint32(data[len(data)-1]) << 8
+	}
+	for s > 0xffff {
+		s = s>>16 + s&0xffff
+	}
+	return uint16(s)
+}
@@ -1,46 +1,288 @@
+// Package icmp implements ICMP for IPv4, RFC 792.
 package icmp
 
 import (
 	"encoding/binary"
-	"errors"
+	"net"
+
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// ICMP message types.
+const (
+	Type_EchoReply              = 0
+	Type_DestinationUnreachable = 3
+	Type_Redirect               = 5
+	Type_EchoRequest            = 8
+	Type_TimeExceeded           = 11
+	Type_ParameterProblem       = 12
+)
+
+// Destination unreachable codes.
+const (
+	UNREACH_NET          = 0
+	UNREACH_HOST         = 1
+	UNREACH_PROTOCOL     = 2
+	UNREACH_PORT         = 3
+	UNREACH_FRAG_NEEDED  = 4
+	UNREACH_SRC_FAILED   = 5
+	UNREACH_NET_PROHIB   = 9
+	UNREACH_HOST_PROHIB  = 10
+	UNREACH_ADMIN_PROHIB = 13
+)
+
+// Time exceeded codes.
+const (
+	EXCEEDED_TTL        = 0
+	EXCEEDED_REASSEMBLY = 1
+)
+
+// Redirect codes.
+const (
+	REDIRECT_NET      = 0
+	REDIRECT_HOST     = 1
+	REDIRECT_TOS_NET  = 2
+	REDIRECT_TOS_HOST = 3
 )
 
 type ICMP struct {
 	Type     uint8
 	Code     uint8
 	Checksum uint16
-	Data     []byte
+	Data     util.Message
 }
 
 func New() *ICMP {
 	i := new(ICMP)
-	i.Data = make([]byte, 0)
+	i.Data = new(util.Buffer)
 	return i
 }
 
+// Returns an ICMP message of type t and code carrying body.
+func NewMessage(t, code uint8, body util.Message) *ICMP {
+	i := new(ICMP)
+	i.Type = t
+	i.Code = code
+	i.Data = body
+	return i
+}
+
+// Returns an echo request carrying data.
+func NewEchoRequest(id, seq uint16, data []byte) *ICMP {
+	return NewMessage(Type_EchoRequest, 0, &Echo{id, seq, data})
+}
+
+// Returns the reply to the echo request req.
+func NewEchoReply(req *Echo) *ICMP {
+	return NewMessage(Type_EchoReply, 0, &Echo{req.Id, req.Seq, req.Data})
+}
+
+// Returns a destination unreachable error about the IP datagram
+// original.
+func NewDestinationUnreachable(code uint8, original []byte) *ICMP {
+	return NewMessage(Type_DestinationUnreachable, code, &Unreachable{0, quote(original)})
+}
+
+// Returns the error sent when original is larger than the mtu of
+// the next hop and may not be fragmented.
+func NewFragmentationNeeded(mtu uint16, original []byte) *ICMP {
+	return NewMessage(Type_DestinationUnreachable, UNREACH_FRAG_NEEDED, &Unreachable{mtu, quote(original)})
+}
+
+// Returns a time exceeded error about the IP datagram original.
+func NewTimeExceeded(code uint8, original []byte) *ICMP {
+	return NewMessage(Type_TimeExceeded, code, &TimeExceeded{quote(original)})
+}
+
+// Returns a redirect of the traffic of the IP datagram original to
+// gateway.
+func NewRedirect(code uint8, gateway net.IP, original []byte) *ICMP {
+	return NewMessage(Type_Redirect, code, &Redirect{gateway, quote(original)})
+}
+
+// Returns the part of an IP datagram quoted by error messages, its
+// header and the first 8 bytes of its payload.
+func quote(datagram []byte) []byte {
+	n := len(datagram)
+	if n > 0 {
+		if hdr := int(datagram[0]&0x0f)*4 + 8; hdr < n {
+			n = hdr
+		}
+	}
+	q := make([]byte, n)
+	copy(q, datagram)
+	return q
+}
+
 func (i *ICMP) Len() (n uint16) {
-	return uint16(4 + len(i.Data))
+	n = 4
+	if i.Data != nil {
+		n += i.Data.Len()
+	}
+	return
 }
 
 func (i *ICMP) MarshalBinary() (data []byte, err error) {
-	data = make([]byte, int(i.Len()))
+	data = make([]byte, 4, int(i.Len()))
 	data[0] = i.Type
 	data[1] = i.Code
 	binary.BigEndian.PutUint16(data[2:4], i.Checksum)
-	copy(data[4:], i.Data)
+	if i.Data != nil {
+		var b []byte
+		if b, err = i.Data.MarshalBinary(); err != nil {
+			return
+		}
+		data = append(data, b...)
+	}
 	return
 }
 
 func (i *ICMP) UnmarshalBinary(data []byte) error {
 	if len(data) < 4 {
-		return errors.New("The []byte is too short to unmarshal a full ARP message.")
+		i.Data = nil
+		return util.NewTruncatedError("ICMP message", 4, len(data))
 	}
 	i.Type = data[0]
 	i.Code = data[1]
 	i.Checksum = binary.BigEndian.Uint16(data[2:4])
 
-	for n, _ := range data[4:] {
-		i.Data = append(i.Data, data[n])
+	switch i.Type {
+	case Type_EchoRequest, Type_EchoReply:
+		i.Data = new(Echo)
+	case Type_DestinationUnreachable:
+		i.Data = new(Unreachable)
+	case Type_TimeExceeded:
+		i.Data = new(TimeExceeded)
+	case Type_Redirect:
+		i.Data = new(Redirect)
+	default:
+		i.Data = new(util.Buffer)
+	}
+	return i.Data.UnmarshalBinary(data[4:])
+}
+
+func (i *ICMP) Payload() util.Message {
+	return i.Data
+}
+
+// Computes and sets the checksum of the message.
+func (i *ICMP) SetChecksum() error {
+	i.Checksum = 0
+	data, err := i.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	i.Checksum = util.Checksum(data)
+	return nil
+}
+
+// The body of an echo request or reply.
+type Echo struct {
+	Id   uint16
+	Seq  uint16
+	Data []byte
+}
+
+func (e *Echo) Len() (n uint16) {
+	return uint16(4 + len(e.Data))
+}
+
+func (e *Echo) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(e.Len()))
+	binary.BigEndian.PutUint16(data[0:2], e.Id)
+	binary.BigEndian.PutUint16(data[2:4], e.Seq)
+	copy(data[4:], e.Data)
+	return
+}
+
+func (e *Echo) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return util.NewTruncatedError("Echo message", 4, len(data))
+	}
+	e.Id = binary.BigEndian.Uint16(data[0:2])
+	e.Seq = binary.BigEndian.Uint16(data[2:4])
+	e.Data = make([]byte, len(data)-4)
+	copy(e.Data, data[4:])
+	return nil
+}
+
+// The body of a destination unreachable error. Original holds the
+// IP header and the first bytes of the payload of the datagram that
+// could not be delivered, and can be unmarshaled by ipv4.IPv4.
+type Unreachable struct {
+	NextHopMTU uint16 // Of UNREACH_FRAG_NEEDED errors.
+	Original   []byte
+}
+
+func (u *Unreachable) Len() (n uint16) {
+	return uint16(4 + len(u.Original))
+}
+
+func (u *Unreachable) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(u.Len()))
+	binary.BigEndian.PutUint16(data[2:4], u.NextHopMTU)
+	copy(data[4:], u.Original)
+	return
+}
+
+func (u *Unreachable) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return util.NewTruncatedError("Unreachable message", 4, len(data))
+	}
+	u.NextHopMTU = binary.BigEndian.Uint16(data[2:4])
+	u.Original = make([]byte, len(data)-4)
+	copy(u.Original, data[4:])
+	return nil
+}
+
+// The body of a time exceeded error. Original is as in Unreachable.
+type TimeExceeded struct {
+	Original []byte
+}
+
+func (t *TimeExceeded) Len() (n uint16) {
+	return uint16(4 + len(t.Original))
+}
+
+func (t *TimeExceeded) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(t.Len()))
+	copy(data[4:], t.Original)
+	return
+}
+
+func (t *TimeExceeded) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return util.NewTruncatedError("TimeExceeded message", 4, len(data))
+	}
+	t.Original = make([]byte, len(data)-4)
+	copy(t.Original, data[4:])
+	return nil
+}
+
+// The body of a redirect. Original is as in Unreachable.
+type Redirect struct {
+	Gateway  net.IP
+	Original []byte
+}
+
+func (r *Redirect) Len() (n uint16) {
+	return uint16(4 + len(r.Original))
+}
+
+func (r *Redirect) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(r.Len()))
+	copy(data[0:4], r.Gateway.To4())
+	copy(data[4:], r.Original)
+	return
+}
+
+func (r *Redirect) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return util.NewTruncatedError("Redirect message", 4, len(data))
 	}
+	r.Gateway = make(net.IP, 4)
+	copy(r.Gateway, data[0:4])
+	r.Original = make([]byte, len(data)-4)
+	copy(r.Original, data[4:])
 	return nil
 }
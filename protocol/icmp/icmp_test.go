@@ -0,0 +1,97 @@
+package icmp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestEchoMarshalBinary(t *testing.T) {
+	b := "   08 00 66 67 " + // Type, Code, Checksum
+		"00 01 00 02 " + // Id, Seq
+		"61 62 63 64 65 66 67 68 " // Data
+	b = strings.Replace(b, " ", "", -1)
+
+	i := NewEchoRequest(1, 2, []byte("abcdefgh"))
+	if err := i.SetChecksum(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := i.MarshalBinary()
+	d := hex.EncodeToString(data)
+	if (len(b) != len(d)) || (b != d) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", d)
+		t.Errorf("Received length of %d, expected %d", len(d), len(b))
+	}
+
+	r := New()
+	if err := r.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	req, ok := r.Data.(*Echo)
+	if !ok {
+		t.Fatalf("Got body %T, expected *Echo.", r.Data)
+	}
+	reply := NewEchoReply(req)
+	if reply.Type != Type_EchoReply || reply.Len() != i.Len() {
+		t.Errorf("Got reply type %d, length %d.", reply.Type, reply.Len())
+	}
+}
+
+func TestTimeExceeded(t *testing.T) {
+	original := "   45 00 00 24 00 00 00 00 01 11 00 00 0a 00 00 01 0a 00 01 01 " + // IPv4
+		"30 39 00 35 00 10 00 00 " + // UDP
+		"de ad be ef " // Payload
+	original = strings.Replace(original, " ", "", -1)
+	datagram, _ := hex.DecodeString(original)
+
+	i := NewTimeExceeded(EXCEEDED_TTL, datagram)
+	i.SetChecksum()
+	data, _ := i.MarshalBinary()
+	if len(data) != 4+4+28 {
+		t.Errorf("Got length of %d, expected %d.", len(data), 36)
+	}
+
+	r := New()
+	if err := r.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	body, ok := r.Data.(*TimeExceeded)
+	if !ok {
+		t.Fatalf("Got body %T, expected *TimeExceeded.", r.Data)
+	}
+	if !bytes.Equal(body.Original, datagram[:28]) {
+		t.Log("Exp:", hex.EncodeToString(datagram[:28]))
+		t.Log("Rec:", hex.EncodeToString(body.Original))
+		t.Error("Quoted datagram does not match.")
+	}
+}
+
+func TestRedirectUnmarshalBinary(t *testing.T) {
+	b := "   05 01 00 00 " + // Type, Code, Checksum
+		"0a 00 00 fe " + // Gateway
+		"45 00 00 1c 00 00 00 00 40 11 00 00 0a 00 00 01 0a 00 01 01 " + // IPv4
+		"30 39 00 35 00 08 00 00 " // UDP
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	i := New()
+	if err := i.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	r, ok := i.Data.(*Redirect)
+	if !ok {
+		t.Fatalf("Got body %T, expected *Redirect.", i.Data)
+	}
+	if !r.Gateway.Equal(net.ParseIP("10.0.0.254")) || len(r.Original) != 28 {
+		t.Errorf("Got gateway %s and %d quoted bytes.", r.Gateway, len(r.Original))
+	}
+	out, _ := i.MarshalBinary()
+	if d := hex.EncodeToString(out); d != b {
+		t.Log("Exp:", b)
+		t.Log("Rec:", d)
+		t.Errorf("Received length of %d, expected %d", len(d), len(b))
+	}
+}
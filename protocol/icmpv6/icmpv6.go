@@ -0,0 +1,345 @@
+// Package icmpv6 implements ICMPv6 and the Neighbor Discovery
+// messages defined in RFC 4861.
+package icmpv6
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// IPv6 next header value of ICMPv6.
+const Type_ICMPv6 = 0x3a
+
+// ICMPv6 message types.
+const (
+	Type_DestinationUnreachable = 1
+	Type_PacketTooBig           = 2
+	Type_TimeExceeded           = 3
+	Type_ParameterProblem       = 4
+	Type_EchoRequest            = 128
+	Type_EchoReply              = 129
+	Type_RouterSolicitation     = 133
+	Type_RouterAdvertisement    = 134
+	Type_NeighborSolicitation   = 135
+	Type_NeighborAdvertisement  = 136
+	Type_Redirect               = 137
+)
+
+type ICMPv6 struct {
+	Type     uint8
+	Code     uint8
+	Checksum uint16
+	Data     util.Message
+}
+
+func New() *ICMPv6 {
+	i := new(ICMPv6)
+	i.Data = new(util.Buffer)
+	return i
+}
+
+// Returns an ICMPv6 message of type t carrying body.
+func NewMessage(t uint8, body util.Message) *ICMPv6 {
+	i := new(ICMPv6)
+	i.Type = t
+	i.Data = body
+	return i
+}
+
+func (i *ICMPv6) Len() (n uint16) {
+	n = 4
+	if i.Data != nil {
+		n += i.Data.Len()
+	}
+	return
+}
+
+func (i *ICMPv6) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 4, int(i.Len()))
+	data[0] = i.Type
+	data[1] = i.Code
+	binary.BigEndian.PutUint16(data[2:4], i.Checksum)
+	if i.Data != nil {
+		var b []byte
+		if b, err = i.Data.MarshalBinary(); err != nil {
+			return
+		}
+		data = append(data, b...)
+	}
+	return
+}
+
+func (i *ICMPv6) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		i.Data = nil
+		return util.NewTruncatedError("ICMPv6 message", 4, len(data))
+	}
+	i.Type = data[0]
+	i.Code = data[1]
+	i.Checksum = binary.BigEndian.Uint16(data[2:4])
+
+	switch i.Type {
+	case Type_EchoRequest, Type_EchoReply:
+		i.Data = new(Echo)
+	case Type_RouterSolicitation:
+		i.Data = new(RouterSolicitation)
+	case Type_RouterAdvertisement:
+		i.Data = new(RouterAdvertisement)
+	case Type_NeighborSolicitation:
+		i.Data = new(NeighborSolicitation)
+	case Type_NeighborAdvertisement:
+		i.Data = new(NeighborAdvertisement)
+	default:
+		i.Data = new(util.Buffer)
+	}
+	return i.Data.UnmarshalBinary(data[4:])
+}
+
+func (i *ICMPv6) Payload() util.Message {
+	return i.Data
+}
+
+// Returns the checksum of the message sent from src to dst over
+// the IPv6 pseudo-header. The Checksum field is ignored.
+func (i *ICMPv6) ComputeChecksum(src, dst net.IP) (uint16, error) {
+	data, err := i.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	data[2], data[3] = 0, 0
+	return util.PseudoHeaderChecksum(src, dst, Type_ICMPv6, data), nil
+}
+
+// Computes and sets the checksum of the message sent from src to
+// dst.
+func (i *ICMPv6) SetChecksum(src, dst net.IP) error {
+	c, err := i.ComputeChecksum(src, dst)
+	i.Checksum = c
+	return err
+}
+
+// The body of an echo request or reply.
+type Echo struct {
+	Id   uint16
+	Seq  uint16
+	Data []byte
+}
+
+func (e *Echo) Len() (n uint16) {
+	return uint16(4 + len(e.Data))
+}
+
+func (e *Echo) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(e.Len()))
+	binary.BigEndian.PutUint16(data[0:2], e.Id)
+	binary.BigEndian.PutUint16(data[2:4], e.Seq)
+	copy(data[4:], e.Data)
+	return
+}
+
+func (e *Echo) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return util.NewTruncatedError("Echo message", 4, len(data))
+	}
+	e.Id = binary.BigEndian.Uint16(data[0:2])
+	e.Seq = binary.BigEndian.Uint16(data[2:4])
+	e.Data = make([]byte, len(data)-4)
+	copy(e.Data, data[4:])
+	return nil
+}
+
+// The body of a router solicitation.
+type RouterSolicitation struct {
+	reserved uint32
+	Options  Options
+}
+
+func (r *RouterSolicitation) Len() (n uint16) {
+	return 4 + r.Options.Len()
+}
+
+func (r *RouterSolicitation) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 4, int(r.Len()))
+	binary.BigEndian.PutUint32(data, r.reserved)
+	b, err := r.Options.MarshalBinary()
+	data = append(data, b...)
+	return
+}
+
+func (r *RouterSolicitation) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return util.NewTruncatedError("RouterSolicitation message", 4, len(data))
+	}
+	r.reserved = binary.BigEndian.Uint32(data)
+	return r.Options.UnmarshalBinary(data[4:])
+}
+
+// Router advertisement flags.
+const (
+	RA_MANAGED = 1 << 7
+	RA_OTHER   = 1 << 6
+)
+
+// The body of a router advertisement.
+type RouterAdvertisement struct {
+	CurHopLimit    uint8
+	Flags          uint8
+	RouterLifetime uint16
+	ReachableTime  uint32
+	RetransTimer   uint32
+	Options        Options
+}
+
+func (r *RouterAdvertisement) Len() (n uint16) {
+	return 12 + r.Options.Len()
+}
+
+func (r *RouterAdvertisement) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 12, int(r.Len()))
+	n := 0
+	data[n] = r.CurHopLimit
+	n += 1
+	data[n] = r.Flags
+	n += 1
+	binary.BigEndian.PutUint16(data[n:], r.RouterLifetime)
+	n += 2
+	binary.BigEndian.PutUint32(data[n:], r.ReachableTime)
+	n += 4
+	binary.BigEndian.PutUint32(data[n:], r.RetransTimer)
+	n += 4
+	b, err := r.Options.MarshalBinary()
+	data = append(data, b...)
+	return
+}
+
+func (r *RouterAdvertisement) UnmarshalBinary(data []byte) error {
+	if len(data) < 12 {
+		return util.NewTruncatedError("RouterAdvertisement message", 12, len(data))
+	}
+	n := 0
+	r.CurHopLimit = data[n]
+	n += 1
+	r.Flags = data[n]
+	n += 1
+	r.RouterLifetime = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	r.ReachableTime = binary.BigEndian.Uint32(data[n:])
+	n += 4
+	r.RetransTimer = binary.BigEndian.Uint32(data[n:])
+	n += 4
+	return r.Options.UnmarshalBinary(data[n:])
+}
+
+// The body of a neighbor solicitation.
+type NeighborSolicitation struct {
+	reserved uint32
+	Target   net.IP
+	Options  Options
+}
+
+// Returns a solicitation for target from a host with hardware
+// address mac. A nil mac leaves out the source link-layer address,
+// as duplicate address detection requires.
+func NewNeighborSolicitation(target net.IP, mac net.HardwareAddr) *NeighborSolicitation {
+	s := new(NeighborSolicitation)
+	s.Target = target
+	if mac != nil {
+		s.Options = append(s.Options, NewOptionLinkAddr(OPT_SOURCE_LINK_ADDR, mac))
+	}
+	return s
+}
+
+func (s *NeighborSolicitation) Len() (n uint16) {
+	return 20 + s.Options.Len()
+}
+
+func (s *NeighborSolicitation) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 20, int(s.Len()))
+	binary.BigEndian.PutUint32(data, s.reserved)
+	copy(data[4:20], s.Target.To16())
+	b, err := s.Options.MarshalBinary()
+	data = append(data, b...)
+	return
+}
+
+func (s *NeighborSolicitation) UnmarshalBinary(data []byte) error {
+	if len(data) < 20 {
+		return util.NewTruncatedError("NeighborSolicitation message", 20, len(data))
+	}
+	s.reserved = binary.BigEndian.Uint32(data)
+	s.Target = make([]byte, 16)
+	copy(s.Target, data[4:20])
+	return s.Options.UnmarshalBinary(data[20:])
+}
+
+// Neighbor advertisement flags.
+const (
+	NA_ROUTER    = 1 << 31
+	NA_SOLICITED = 1 << 30
+	NA_OVERRIDE  = 1 << 29
+)
+
+// The body of a neighbor advertisement.
+type NeighborAdvertisement struct {
+	Flags   uint32
+	Target  net.IP
+	Options Options
+}
+
+// Returns an advertisement that target is at mac.
+func NewNeighborAdvertisement(target net.IP, mac net.HardwareAddr, flags uint32) *NeighborAdvertisement {
+	a := new(NeighborAdvertisement)
+	a.Flags = flags
+	a.Target = target
+	if mac != nil {
+		a.Options = append(a.Options, NewOptionLinkAddr(OPT_TARGET_LINK_ADDR, mac))
+	}
+	return a
+}
+
+func (a *NeighborAdvertisement) Len() (n uint16) {
+	return 20 + a.Options.Len()
+}
+
+func (a *NeighborAdvertisement) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 20, int(a.Len()))
+	binary.BigEndian.PutUint32(data, a.Flags)
+	copy(data[4:20], a.Target.To16())
+	b, err := a.Options.MarshalBinary()
+	data = append(data, b...)
+	return
+}
+
+func (a *NeighborAdvertisement) UnmarshalBinary(data []byte) error {
+	if len(data) < 20 {
+		return util.NewTruncatedError("NeighborAdvertisement message", 20, len(data))
+	}
+	a.Flags = binary.BigEndian.Uint32(data)
+	a.Target = make([]byte, 16)
+	copy(a.Target, data[4:20])
+	return a.Options.UnmarshalBinary(data[20:])
+}
+
+// Returns the IPv6 address and hardware address that a neighbor
+// discovery message sent from src proves are bound together. Hosts
+// learn from advertisements and from solicitations with a source
+// link-layer address; solicitations sent from the unspecified
+// address during duplicate address detection prove nothing.
+func NeighborBinding(i *ICMPv6, src net.IP) (ip net.IP, mac net.HardwareAddr, ok bool) {
+	switch body := i.Data.(type) {
+	case *NeighborAdvertisement:
+		if mac, ok = body.Options.LinkAddr(OPT_TARGET_LINK_ADDR); ok {
+			return body.Target, mac, true
+		}
+	case *NeighborSolicitation:
+		if src.IsUnspecified() {
+			return nil, nil, false
+		}
+		if mac, ok = body.Options.LinkAddr(OPT_SOURCE_LINK_ADDR); ok {
+			return src, mac, true
+		}
+	}
+	return nil, nil, false
+}
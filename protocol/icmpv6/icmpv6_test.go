@@ -0,0 +1,75 @@
+package icmpv6
+
+import (
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestNeighborSolicitationMarshalBinary(t *testing.T) {
+	b := "   87 00 7c 97 " + // Type, Code, Checksum
+		"00 00 00 00 " + // Reserved
+		"fe 80 00 00 00 00 00 00 00 00 00 00 00 00 00 02 " + // Target
+		"01 01 00 00 00 00 00 01 " // Source link-layer address
+	b = strings.Replace(b, " ", "", -1)
+
+	mac, _ := net.ParseMAC("00:00:00:00:00:01")
+	i := NewMessage(Type_NeighborSolicitation,
+		NewNeighborSolicitation(net.ParseIP("fe80::2"), mac))
+	i.SetChecksum(net.ParseIP("fe80::1"), net.ParseIP("ff02::1:ff00:2"))
+
+	data, _ := i.MarshalBinary()
+	d := hex.EncodeToString(data)
+	if (len(b) != len(d)) || (b != d) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", d)
+		t.Errorf("Received length of %d, expected %d", len(d), len(b))
+	}
+
+	ip, hw, ok := NeighborBinding(i, net.ParseIP("fe80::1"))
+	if !ok || !ip.Equal(net.ParseIP("fe80::1")) || hw.String() != mac.String() {
+		t.Errorf("Got binding %s %s %t, expected fe80::1 %s.", ip, hw, ok, mac)
+	}
+	if _, _, ok := NeighborBinding(i, net.IPv6unspecified); ok {
+		t.Error("Expected no binding for a duplicate address detection probe.")
+	}
+}
+
+func TestRouterAdvertisementUnmarshalBinary(t *testing.T) {
+	b := "   86 00 00 00 " + // Type, Code, Checksum
+		"40 c0 07 08 00 00 00 00 00 00 00 00 " + // HopLimit, Flags, Lifetime, Timers
+		"01 01 00 00 00 00 00 fe " + // Source link-layer address
+		"05 01 00 00 00 00 05 dc " + // MTU
+		"03 04 40 c0 00 27 8d 00 00 09 3a 80 00 00 00 00 " + // Prefix information
+		"20 01 0d b8 00 00 00 01 00 00 00 00 00 00 00 00 "
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	i := New()
+	if err := i.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	ra, ok := i.Data.(*RouterAdvertisement)
+	if !ok {
+		t.Fatalf("Got %T, expected *RouterAdvertisement.", i.Data)
+	}
+	if ra.CurHopLimit != 64 || ra.Flags != RA_MANAGED|RA_OTHER || ra.RouterLifetime != 1800 {
+		t.Errorf("Got hop limit %d flags %x lifetime %d.", ra.CurHopLimit, ra.Flags, ra.RouterLifetime)
+	}
+	if mtu, ok := ra.Options.MTU(); !ok || mtu != 1500 {
+		t.Errorf("Got mtu %d, expected 1500.", mtu)
+	}
+	prefixes := ra.Options.Prefixes()
+	if len(prefixes) != 1 || prefixes[0].Prefix.String() != "2001:db8:0:1::/64" ||
+		prefixes[0].ValidLifetime != 2592000 {
+		t.Errorf("Got prefixes %v, expected 2001:db8:0:1::/64.", prefixes)
+	}
+
+	d, _ := i.MarshalBinary()
+	if hex.EncodeToString(d) != b {
+		t.Log("Exp:", b)
+		t.Log("Rec:", hex.EncodeToString(d))
+		t.Error("ICMPv6 did not marshal back to its original bytes.")
+	}
+}
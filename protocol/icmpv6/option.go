@@ -0,0 +1,172 @@
+package icmpv6
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Neighbor discovery option types.
+const (
+	OPT_SOURCE_LINK_ADDR = 1
+	OPT_TARGET_LINK_ADDR = 2
+	OPT_PREFIX_INFO      = 3
+	OPT_REDIRECTED       = 4
+	OPT_MTU              = 5
+)
+
+// A neighbor discovery option. Its length on the wire is a
+// multiple of 8 bytes; marshaling pads Data with zeros.
+type Option struct {
+	Type uint8
+	Data []byte
+}
+
+func NewOptionLinkAddr(t uint8, mac net.HardwareAddr) Option {
+	o := Option{t, make([]byte, len(mac))}
+	copy(o.Data, mac)
+	return o
+}
+
+func NewOptionMTU(mtu uint32) Option {
+	o := Option{OPT_MTU, make([]byte, 6)}
+	binary.BigEndian.PutUint32(o.Data[2:], mtu)
+	return o
+}
+
+// Prefix information flags.
+const (
+	PREFIX_ON_LINK    = 1 << 7
+	PREFIX_AUTONOMOUS = 1 << 6
+)
+
+// The contents of a prefix information option.
+type PrefixInfo struct {
+	Prefix            net.IPNet
+	Flags             uint8
+	ValidLifetime     uint32
+	PreferredLifetime uint32
+}
+
+func NewOptionPrefixInfo(p PrefixInfo) Option {
+	o := Option{OPT_PREFIX_INFO, make([]byte, 30)}
+	ones, _ := p.Prefix.Mask.Size()
+	o.Data[0] = uint8(ones)
+	o.Data[1] = p.Flags
+	binary.BigEndian.PutUint32(o.Data[2:], p.ValidLifetime)
+	binary.BigEndian.PutUint32(o.Data[6:], p.PreferredLifetime)
+	copy(o.Data[14:], p.Prefix.IP.To16())
+	return o
+}
+
+func (o *Option) Len() (n uint16) {
+	return uint16(2+len(o.Data)+7) &^ 7
+}
+
+func (o *Option) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(o.Len()))
+	data[0] = o.Type
+	data[1] = uint8(len(data) / 8)
+	copy(data[2:], o.Data)
+	return
+}
+
+func (o *Option) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return util.NewTruncatedError("neighbor discovery option", 8, len(data))
+	}
+	o.Type = data[0]
+	length := int(data[1]) * 8
+	if length == 0 || length > len(data) {
+		return util.NewMalformedError("neighbor discovery option", "length "+strconv.Itoa(length)+" does not fit "+strconv.Itoa(len(data))+" bytes")
+	}
+	o.Data = make([]byte, length-2)
+	copy(o.Data, data[2:length])
+	return nil
+}
+
+// The options that follow a neighbor discovery message.
+type Options []Option
+
+func (opts *Options) Len() (n uint16) {
+	for _, o := range *opts {
+		n += o.Len()
+	}
+	return
+}
+
+func (opts *Options) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 0, int(opts.Len()))
+	for _, o := range *opts {
+		b, err := o.MarshalBinary()
+		if err != nil {
+			return data, err
+		}
+		data = append(data, b...)
+	}
+	return
+}
+
+func (opts *Options) UnmarshalBinary(data []byte) error {
+	*opts = nil
+	n := 0
+	for n < len(data) {
+		o := Option{}
+		if err := o.UnmarshalBinary(data[n:]); err != nil {
+			return err
+		}
+		*opts = append(*opts, o)
+		n += int(o.Len())
+	}
+	return nil
+}
+
+// Returns the first option of type t.
+func (opts Options) Option(t uint8) (o Option, ok bool) {
+	for _, o = range opts {
+		if o.Type == t {
+			return o, true
+		}
+	}
+	return
+}
+
+// Returns the Ethernet address carried by the source or target
+// link-layer address option t.
+func (opts Options) LinkAddr(t uint8) (mac net.HardwareAddr, ok bool) {
+	o, ok := opts.Option(t)
+	if !ok || len(o.Data) < 6 {
+		return nil, false
+	}
+	mac = make(net.HardwareAddr, 6)
+	copy(mac, o.Data)
+	return mac, true
+}
+
+// Returns the link MTU option.
+func (opts Options) MTU() (mtu uint32, ok bool) {
+	if o, ok := opts.Option(OPT_MTU); ok && len(o.Data) >= 6 {
+		return binary.BigEndian.Uint32(o.Data[2:]), true
+	}
+	return
+}
+
+// Returns every prefix information option.
+func (opts Options) Prefixes() (prefixes []PrefixInfo) {
+	for _, o := range opts {
+		if o.Type != OPT_PREFIX_INFO || len(o.Data) < 30 {
+			continue
+		}
+		p := PrefixInfo{}
+		p.Prefix.IP = make(net.IP, 16)
+		copy(p.Prefix.IP, o.Data[14:30])
+		p.Prefix.Mask = net.CIDRMask(int(o.Data[0]), 128)
+		p.Flags = o.Data[1]
+		p.ValidLifetime = binary.BigEndian.Uint32(o.Data[2:])
+		p.PreferredLifetime = binary.BigEndian.Uint32(o.Data[6:])
+		prefixes = append(prefixes, p)
+	}
+	return
+}
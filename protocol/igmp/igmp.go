@@ -0,0 +1,342 @@
+// Package igmp implements the Internet Group Management Protocol,
+// versions 1 and 2 of RFC 2236 and version 3 of RFC 3376.
+package igmp
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Message types
+const (
+	Type_Query    = 0x11
+	Type_ReportV1 = 0x12
+	Type_ReportV2 = 0x16
+	Type_Leave    = 0x17
+	Type_ReportV3 = 0x22
+)
+
+// Group record types of version 3 reports
+const (
+	MODE_IS_INCLUDE        = 1
+	MODE_IS_EXCLUDE        = 2
+	CHANGE_TO_INCLUDE_MODE = 3
+	CHANGE_TO_EXCLUDE_MODE = 4
+	ALLOW_NEW_SOURCES      = 5
+	BLOCK_OLD_SOURCES      = 6
+)
+
+// Destination of version 3 reports.
+var AllRouters = net.IPv4(224, 0, 0, 22)
+
+// Destination of general queries.
+var AllSystems = net.IPv4(224, 0, 0, 1)
+
+// Destination of version 2 leaves.
+var AllRoutersV2 = net.IPv4(224, 0, 0, 2)
+
+// An IGMP message. Group is the group queried, reported or left,
+// and is unused by version 3 reports, which carry Records instead.
+// Version 3 queries carry Flags, QQIC and Sources.
+type IGMP struct {
+	Type        uint8
+	MaxRespCode uint8
+	Checksum    uint16
+	Group       net.IP
+	Version     uint8 // Of queries, 1, 2 or 3.
+	Flags       uint8 // S flag and QRV of version 3 queries.
+	QQIC        uint8
+	Sources     []net.IP
+	Records     []GroupRecord
+}
+
+func New() *IGMP {
+	i := new(IGMP)
+	i.Group = net.IPv4zero.To4()
+	i.Sources = make([]net.IP, 0)
+	i.Records = make([]GroupRecord, 0)
+	return i
+}
+
+// Returns a version 2 query for group, or a general query if group
+// is nil. maxResp is in tenths of a second.
+func NewQuery(group net.IP, maxResp uint8) *IGMP {
+	i := New()
+	i.Type = Type_Query
+	i.Version = 2
+	i.MaxRespCode = maxResp
+	if group != nil {
+		i.Group = group.To4()
+	}
+	return i
+}
+
+// Returns a version 2 report of membership in group.
+func NewReport(group net.IP) *IGMP {
+	i := New()
+	i.Type = Type_ReportV2
+	i.Group = group.To4()
+	return i
+}
+
+// Returns a version 2 leave of group.
+func NewLeave(group net.IP) *IGMP {
+	i := New()
+	i.Type = Type_Leave
+	i.Group = group.To4()
+	return i
+}
+
+// Returns a version 3 report of records.
+func NewReportV3(records ...GroupRecord) *IGMP {
+	i := New()
+	i.Type = Type_ReportV3
+	i.Records = append(i.Records, records...)
+	return i
+}
+
+// Returns the groups the sender of a report has joined.
+func (i *IGMP) Joins() (groups []net.IP) {
+	switch i.Type {
+	case Type_ReportV1, Type_ReportV2:
+		return []net.IP{i.Group}
+	case Type_ReportV3:
+		for _, r := range i.Records {
+			if r.Joins() {
+				groups = append(groups, r.Group)
+			}
+		}
+	}
+	return
+}
+
+// Returns the groups the sender of a leave or report has left.
+func (i *IGMP) Leaves() (groups []net.IP) {
+	switch i.Type {
+	case Type_Leave:
+		return []net.IP{i.Group}
+	case Type_ReportV3:
+		for _, r := range i.Records {
+			if r.Leaves() {
+				groups = append(groups, r.Group)
+			}
+		}
+	}
+	return
+}
+
+func (i *IGMP) Len() (n uint16) {
+	n = 8
+	switch {
+	case i.Type == Type_Query && i.Version == 3:
+		n += 4 + uint16(4*len(i.Sources))
+	case i.Type == Type_ReportV3:
+		for _, r := range i.Records {
+			n += r.Len()
+		}
+	}
+	return
+}
+
+func (i *IGMP) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 8, int(i.Len()))
+	n := 0
+	data[n] = i.Type
+	n += 1
+	data[n] = i.MaxRespCode
+	n += 1
+	binary.BigEndian.PutUint16(data[n:], i.Checksum)
+	n += 2
+
+	if i.Type == Type_ReportV3 {
+		binary.BigEndian.PutUint16(data[n+2:], uint16(len(i.Records)))
+		for _, r := range i.Records {
+			var b []byte
+			if b, err = r.MarshalBinary(); err != nil {
+				return
+			}
+			data = append(data, b...)
+		}
+		return
+	}
+
+	copy(data[n:], i.Group.To4())
+	n += 4
+	if i.Type == Type_Query && i.Version == 3 {
+		b := make([]byte, 4)
+		b[0] = i.Flags
+		b[1] = i.QQIC
+		binary.BigEndian.PutUint16(b[2:], uint16(len(i.Sources)))
+		data = append(data, b...)
+		for _, s := range i.Sources {
+			data = append(data, s.To4()...)
+		}
+	}
+	return
+}
+
+func (i *IGMP) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return util.NewTruncatedError("IGMP message", 8, len(data))
+	}
+	n := 0
+	i.Type = data[n]
+	n += 1
+	i.MaxRespCode = data[n]
+	n += 1
+	i.Checksum = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	i.Group = net.IPv4zero.To4()
+	i.Version = 0
+	i.Flags = 0
+	i.QQIC = 0
+	i.Sources = make([]net.IP, 0)
+	i.Records = make([]GroupRecord, 0)
+
+	if i.Type == Type_ReportV3 {
+		count := int(binary.BigEndian.Uint16(data[n+2:]))
+		n += 4
+		for k := 0; k < count; k++ {
+			r := GroupRecord{}
+			if err := r.UnmarshalBinary(data[n:]); err != nil {
+				return err
+			}
+			i.Records = append(i.Records, r)
+			n += int(r.Len())
+		}
+		return nil
+	}
+
+	i.Group = make(net.IP, 4)
+	copy(i.Group, data[n:])
+	n += 4
+	if i.Type != Type_Query {
+		return nil
+	}
+
+	// Versions are told apart by length and the max response time.
+	switch {
+	case len(data) < 12 && i.MaxRespCode == 0:
+		i.Version = 1
+		return nil
+	case len(data) < 12:
+		i.Version = 2
+		return nil
+	}
+	i.Version = 3
+	i.Flags = data[n]
+	n += 1
+	i.QQIC = data[n]
+	n += 1
+	count := int(binary.BigEndian.Uint16(data[n:]))
+	n += 2
+	if len(data) < n+4*count {
+		return util.NewTruncatedError("IGMP message", n+4*count, len(data))
+	}
+	for k := 0; k < count; k++ {
+		s := make(net.IP, 4)
+		copy(s, data[n:])
+		i.Sources = append(i.Sources, s)
+		n += 4
+	}
+	return nil
+}
+
+// Computes and sets the checksum of the message.
+func (i *IGMP) SetChecksum() error {
+	i.Checksum = 0
+	data, err := i.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	i.Checksum = util.Checksum(data)
+	return nil
+}
+
+// The membership of one group in a version 3 report.
+type GroupRecord struct {
+	Type    uint8
+	Group   net.IP
+	Sources []net.IP
+	AuxData []byte // Padded to a multiple of 4 bytes.
+}
+
+func NewGroupRecord(t uint8, group net.IP, sources ...net.IP) GroupRecord {
+	return GroupRecord{t, group.To4(), sources, nil}
+}
+
+// Returns true if r reports that traffic to the group is wanted.
+func (r *GroupRecord) Joins() bool {
+	switch r.Type {
+	case MODE_IS_EXCLUDE, CHANGE_TO_EXCLUDE_MODE:
+		return true
+	case MODE_IS_INCLUDE, ALLOW_NEW_SOURCES:
+		return len(r.Sources) > 0
+	}
+	return false
+}
+
+// Returns true if r reports that no traffic to the group is wanted.
+func (r *GroupRecord) Leaves() bool {
+	switch r.Type {
+	case MODE_IS_INCLUDE, CHANGE_TO_INCLUDE_MODE:
+		return len(r.Sources) == 0
+	}
+	return false
+}
+
+func (r *GroupRecord) Len() (n uint16) {
+	return uint16(8 + 4*len(r.Sources) + (len(r.AuxData)+3)&^3)
+}
+
+func (r *GroupRecord) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(r.Len()))
+	n := 0
+	data[n] = r.Type
+	n += 1
+	data[n] = uint8((len(r.AuxData) + 3) / 4)
+	n += 1
+	binary.BigEndian.PutUint16(data[n:], uint16(len(r.Sources)))
+	n += 2
+	copy(data[n:], r.Group.To4())
+	n += 4
+	for _, s := range r.Sources {
+		copy(data[n:], s.To4())
+		n += 4
+	}
+	copy(data[n:], r.AuxData)
+	return
+}
+
+func (r *GroupRecord) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return util.NewTruncatedError("IGMP group record", 8, len(data))
+	}
+	n := 0
+	r.Type = data[n]
+	n += 1
+	aux := int(data[n]) * 4
+	n += 1
+	count := int(binary.BigEndian.Uint16(data[n:]))
+	n += 2
+	if len(data) < 8+4*count+aux {
+		return util.NewTruncatedError("IGMP group record", 8+4*count+aux, len(data))
+	}
+	r.Group = make(net.IP, 4)
+	copy(r.Group, data[n:])
+	n += 4
+	r.Sources = make([]net.IP, count)
+	for k := range r.Sources {
+		r.Sources[k] = make(net.IP, 4)
+		copy(r.Sources[k], data[n:])
+		n += 4
+	}
+	r.AuxData = nil
+	if aux > 0 {
+		r.AuxData = make([]byte, aux)
+		copy(r.AuxData, data[n:])
+	}
+	return nil
+}
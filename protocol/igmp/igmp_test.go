@@ -0,0 +1,109 @@
+package igmp
+
+import (
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestIGMPReportV2(t *testing.T) {
+	b := "   16 " + // Type
+		"00 " + // MaxRespCode
+		"f8 fa " + // Checksum
+		"ef 01 02 03 " // Group
+	b = strings.Replace(b, " ", "", -1)
+
+	i := NewReport(net.ParseIP("239.1.2.3"))
+	if err := i.SetChecksum(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := i.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := hex.EncodeToString(data)
+	if (len(b) != len(s)) || (b != s) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", s)
+		t.Errorf("Received length of %d, expected %d", len(s), len(b))
+	}
+
+	r := New()
+	if err := r.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if joins := r.Joins(); len(joins) != 1 || !joins[0].Equal(net.ParseIP("239.1.2.3")) {
+		t.Errorf("Got joins %v, expected [239.1.2.3].", joins)
+	}
+}
+
+func TestIGMPQuery(t *testing.T) {
+	v2, _ := hex.DecodeString("1164ee9b00000000")
+	i := New()
+	if err := i.UnmarshalBinary(v2); err != nil {
+		t.Fatal(err)
+	}
+	if i.Version != 2 {
+		t.Errorf("Got version %d, expected 2.", i.Version)
+	}
+
+	b := "   11 " + // Type
+		"64 " + // MaxRespCode
+		"00 00 " + // Checksum
+		"ef 01 02 03 " + // Group
+		"02 " + // Flags
+		"7d " + // QQIC
+		"00 01 " + // Number of sources
+		"0a 00 00 01 " // Source
+	b = strings.Replace(b, " ", "", -1)
+	v3, _ := hex.DecodeString(b)
+	if err := i.UnmarshalBinary(v3); err != nil {
+		t.Fatal(err)
+	}
+	if i.Version != 3 || len(i.Sources) != 1 || int(i.Len()) != len(v3) {
+		t.Errorf("Got version %d, %d sources, length %d.", i.Version, len(i.Sources), i.Len())
+	}
+	data, _ := i.MarshalBinary()
+	if s := hex.EncodeToString(data); s != b {
+		t.Log("Exp:", b)
+		t.Log("Rec:", s)
+		t.Errorf("Received length of %d, expected %d", len(s), len(b))
+	}
+}
+
+func TestIGMPReportV3(t *testing.T) {
+	b := "   22 00 00 00 " + // Type, Reserved, Checksum
+		"00 00 00 02 " + // Reserved, Number of records
+		"04 00 00 00 ef 01 02 03 " + // Change to exclude, no sources
+		"03 01 00 01 ef 01 02 04 0a 00 00 01 " + // Change to include, one source
+		"de ad be ef " // Auxiliary data
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	i := New()
+	if err := i.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if len(i.Records) != 2 || int(i.Len()) != len(data) {
+		t.Fatalf("Got %d records, length %d.", len(i.Records), i.Len())
+	}
+	if joins := i.Joins(); len(joins) != 1 || !joins[0].Equal(net.ParseIP("239.1.2.3")) {
+		t.Errorf("Got joins %v, expected [239.1.2.3].", joins)
+	}
+	if leaves := i.Leaves(); len(leaves) != 0 {
+		t.Errorf("Got leaves %v, expected none.", leaves)
+	}
+
+	out, _ := i.MarshalBinary()
+	if s := hex.EncodeToString(out); s != b {
+		t.Log("Exp:", b)
+		t.Log("Rec:", s)
+		t.Errorf("Received length of %d, expected %d", len(s), len(b))
+	}
+
+	leave := NewReportV3(NewGroupRecord(CHANGE_TO_INCLUDE_MODE, net.ParseIP("239.1.2.3")))
+	if leaves := leave.Leaves(); len(leaves) != 1 {
+		t.Errorf("Got leaves %v, expected [239.1.2.3].", leaves)
+	}
+}
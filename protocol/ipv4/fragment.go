@@ -0,0 +1,279 @@
+package ipv4
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Identifies the fragments of one packet, as in RFC 791.
+type fragmentKey struct {
+	src, dst [4]byte
+	id       uint16
+	protocol uint8
+}
+
+func newFragmentKey(i *IPv4) (k fragmentKey) {
+	copy(k.src[:], i.NWSrc.To4())
+	copy(k.dst[:], i.NWDst.To4())
+	k.id = i.Id
+	k.protocol = i.Protocol
+	return
+}
+
+type fragment struct {
+	offset int
+	data   []byte
+}
+
+// The fragments of one packet received so far.
+type partial struct {
+	first     *IPv4 // The fragment at offset 0, once seen.
+	fragments []fragment
+	size      int // Bytes held in fragments.
+	total     int // Payload length, once the last fragment is seen.
+	expires   time.Time
+}
+
+// Collects IPv4 fragments, such as those seen in PacketIn, into
+// whole packets. A Reassembler is safe for concurrent use.
+type Reassembler struct {
+	timeout  time.Duration
+	maxBytes int
+
+	pending map[fragmentKey]*partial
+	bytes   int
+	now     func() time.Time
+	sync.Mutex
+}
+
+// Returns a Reassembler that drops packets not completed within
+// timeout, and holds at most maxBytes of fragment data, dropping
+// the packets that expire soonest to make room.
+func NewReassembler(timeout time.Duration, maxBytes int) *Reassembler {
+	r := new(Reassembler)
+	r.timeout = timeout
+	r.maxBytes = maxBytes
+	r.pending = make(map[fragmentKey]*partial)
+	r.now = time.Now
+	return r
+}
+
+// Adds a packet to the Reassembler. Packets that are not fragments
+// are returned as they are. Fragments return nil until the last
+// one of their packet arrives, which returns the reassembled packet
+// with its payload decoded. A fragment that overlaps another or
+// would grow a packet past 65535 bytes drops the whole packet and
+// returns an error.
+func (r *Reassembler) Add(i *IPv4) (*IPv4, error) {
+	if !i.IsFragment() {
+		return i, nil
+	}
+	var data []byte
+	if i.Data != nil {
+		var err error
+		if data, err = i.Data.MarshalBinary(); err != nil {
+			return nil, err
+		}
+	}
+	offset := int(i.FragmentOffset) * 8
+	more := i.Flags&FLAG_MF != 0
+	if more && len(data)%8 != 0 {
+		return nil, errors.New("IPv4 fragment payload is not a multiple of 8 bytes.")
+	}
+	if int(i.HeaderLen())+offset+len(data) > 0xffff {
+		return nil, errors.New("IPv4 fragments exceed the maximum packet size.")
+	}
+	if len(data) > r.maxBytes {
+		return nil, errors.New("IPv4 fragment exceeds the reassembly memory limit.")
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	now := r.now()
+	r.expire(now)
+
+	k := newFragmentKey(i)
+	p, ok := r.pending[k]
+	if !ok {
+		p = &partial{fragments: make([]fragment, 0), expires: now.Add(r.timeout)}
+		r.pending[k] = p
+	}
+
+	for _, f := range p.fragments {
+		if offset < f.offset+len(f.data) && f.offset < offset+len(data) {
+			r.drop(k)
+			return nil, errors.New("IPv4 fragment overlaps an earlier fragment.")
+		}
+	}
+	if !more {
+		if p.total != 0 && p.total != offset+len(data) {
+			r.drop(k)
+			return nil, errors.New("IPv4 packet has two last fragments.")
+		}
+		p.total = offset + len(data)
+		for _, f := range p.fragments {
+			if f.offset+len(f.data) > p.total {
+				r.drop(k)
+				return nil, errors.New("IPv4 fragment lies past the last fragment.")
+			}
+		}
+	} else if p.total != 0 && offset+len(data) > p.total {
+		r.drop(k)
+		return nil, errors.New("IPv4 fragment lies past the last fragment.")
+	}
+	if offset == 0 {
+		p.first = i
+	}
+	p.fragments = append(p.fragments, fragment{offset, data})
+	p.size += len(data)
+	r.bytes += len(data)
+	r.evict(k)
+
+	if p.first == nil || p.total == 0 || p.size != p.total {
+		return nil, nil
+	}
+	r.drop(k)
+	return p.reassemble()
+}
+
+// Drops packets whose timeout has passed.
+func (r *Reassembler) expire(now time.Time) {
+	for k, p := range r.pending {
+		if now.After(p.expires) {
+			r.drop(k)
+		}
+	}
+}
+
+// Drops the packets expiring soonest, other than keep, until the
+// held fragments fit the memory limit.
+func (r *Reassembler) evict(keep fragmentKey) {
+	for r.bytes > r.maxBytes {
+		var oldest fragmentKey
+		found := false
+		for k, p := range r.pending {
+			if k == keep {
+				continue
+			}
+			if !found || p.expires.Before(r.pending[oldest].expires) {
+				oldest = k
+				found = true
+			}
+		}
+		if !found {
+			return
+		}
+		r.drop(oldest)
+	}
+}
+
+func (r *Reassembler) drop(k fragmentKey) {
+	if p, ok := r.pending[k]; ok {
+		r.bytes -= p.size
+		delete(r.pending, k)
+	}
+}
+
+// Returns the number of packets waiting for more fragments.
+func (r *Reassembler) Pending() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.pending)
+}
+
+func (p *partial) reassemble() (*IPv4, error) {
+	data := make([]byte, p.total)
+	for _, f := range p.fragments {
+		copy(data[f.offset:], f.data)
+	}
+
+	ip := *p.first
+	ip.Flags &^= FLAG_MF
+	ip.FragmentOffset = 0
+	ip.Data = newPayload(ip.Protocol)
+	if err := ip.Data.UnmarshalBinary(data); err != nil {
+		return &ip, err
+	}
+	ip.Length = uint16(int(ip.HeaderLen()) + len(data))
+	return &ip, ip.SetChecksum()
+}
+
+// Splits i into fragments whose total length is at most mtu. A
+// packet that fits is returned alone. The fragments carry their
+// payload as a util.Buffer and have their lengths and checksums
+// set. Only the options marked to be copied are repeated after the
+// first fragment.
+func (i *IPv4) Fragment(mtu int) ([]*IPv4, error) {
+	if int(i.Len()) <= mtu {
+		return []*IPv4{i}, nil
+	}
+	if i.Flags&FLAG_DF != 0 {
+		return nil, errors.New("IPv4 packet larger than the MTU has the don't fragment flag set.")
+	}
+	var data []byte
+	if i.Data != nil {
+		var err error
+		if data, err = i.Data.MarshalBinary(); err != nil {
+			return nil, err
+		}
+	}
+	opts, _ := i.Options.MarshalBinary()
+	copied := copiedOptions(opts)
+
+	frags := make([]*IPv4, 0)
+	for off := 0; off < len(data); {
+		f := *i
+		if off > 0 {
+			f.Options = *util.NewBuffer(copied)
+		} else {
+			f.Options = *util.NewBuffer(append([]byte(nil), opts...))
+		}
+		size := (mtu - int(f.HeaderLen())) &^ 7
+		if size < 8 {
+			return nil, errors.New("The MTU is too small to fragment an IPv4 packet.")
+		}
+		end := off + size
+		if end >= len(data) {
+			end = len(data)
+		} else {
+			f.Flags |= FLAG_MF
+		}
+		f.FragmentOffset = i.FragmentOffset + uint16(off/8)
+		f.Data = util.NewBuffer(data[off:end])
+		if err := f.FixLengths(); err != nil {
+			return nil, err
+		}
+		if err := f.SetChecksum(); err != nil {
+			return nil, err
+		}
+		frags = append(frags, &f)
+		off = end
+	}
+	return frags, nil
+}
+
+// Returns the options of opts whose copied flag is set.
+func copiedOptions(opts []byte) []byte {
+	copied := make([]byte, 0)
+	for n := 0; n < len(opts); {
+		switch opts[n] {
+		case 0: // End of options
+			return copied
+		case 1: // No operation
+			n += 1
+			continue
+		}
+		if n+1 >= len(opts) || opts[n+1] < 2 || n+int(opts[n+1]) > len(opts) {
+			return copied
+		}
+		l := int(opts[n+1])
+		if opts[n]&0x80 != 0 {
+			copied = append(copied, opts[n:n+l]...)
+		}
+		n += l
+	}
+	return copied
+}
@@ -0,0 +1,149 @@
+package ipv4
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jonstout/ogo/protocol/udp"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+func newTestPacket(id uint16, size int) *IPv4 {
+	u := udp.New()
+	u.PortSrc = 1234
+	u.PortDst = 9999
+	u.Data = util.NewBuffer(bytes.Repeat([]byte{0xab}, size))
+	u.FixLengths()
+
+	ip := New()
+	ip.Version = 4
+	ip.TTL = 64
+	ip.Id = id
+	ip.Protocol = Type_UDP
+	ip.NWSrc = net.ParseIP("10.0.0.1")
+	ip.NWDst = net.ParseIP("10.0.0.2")
+	ip.Data = u
+	ip.FixLengths()
+	return ip
+}
+
+// Marshals and unmarshals each fragment, as if it was sent through
+// a switch.
+func wire(t *testing.T, frags []*IPv4) []*IPv4 {
+	out := make([]*IPv4, len(frags))
+	for n, f := range frags {
+		data, err := f.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[n] = New()
+		if err = out[n].UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out
+}
+
+func TestFragmentReassemble(t *testing.T) {
+	ip := newTestPacket(7, 100)
+	frags, err := ip.Fragment(60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frags) != 3 {
+		t.Fatalf("Got %d fragments, expected 3.", len(frags))
+	}
+	for n, f := range frags {
+		if f.Len() > 60 || f.Length != f.Len() {
+			t.Errorf("Fragment %d has length %d, expected at most 60.", n, f.Length)
+		}
+	}
+	if frags[1].FragmentOffset != 5 || frags[1].Flags&FLAG_MF == 0 || frags[2].Flags&FLAG_MF != 0 {
+		t.Errorf("Got fragment offset %d and flags %d.", frags[1].FragmentOffset, frags[1].Flags)
+	}
+
+	received := wire(t, frags)
+	if _, ok := received[0].Data.(*util.Buffer); !ok {
+		t.Errorf("Got fragment payload %T, expected *util.Buffer.", received[0].Data)
+	}
+
+	r := NewReassembler(time.Second, 1<<16)
+	for _, n := range []int{2, 0} {
+		if p, err := r.Add(received[n]); p != nil || err != nil {
+			t.Fatalf("Got %v, %v before the last fragment.", p, err)
+		}
+	}
+	p, err := r.Add(received[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == nil {
+		t.Fatal("Got no packet after the last fragment.")
+	}
+	if p.IsFragment() || p.Length != ip.Length || p.Checksum != util.Checksum(mustMarshal(t, p)[:20]) {
+		t.Errorf("Got reassembled header %+v.", p)
+	}
+	u, ok := p.Data.(*udp.UDP)
+	if !ok {
+		t.Fatalf("Got payload %T, expected *udp.UDP.", p.Data)
+	}
+	if u.PortDst != 9999 || u.Data.Len() != 100 {
+		t.Errorf("Got datagram to port %d with %d bytes.", u.PortDst, u.Data.Len())
+	}
+	if r.Pending() != 0 {
+		t.Errorf("Got %d pending packets, expected 0.", r.Pending())
+	}
+}
+
+func mustMarshal(t *testing.T, ip *IPv4) []byte {
+	c := ip.Checksum
+	ip.Checksum = 0
+	data, err := ip.MarshalBinary()
+	ip.Checksum = c
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestReassemblerLimits(t *testing.T) {
+	now := time.Unix(0, 0)
+	r := NewReassembler(time.Second, 100)
+	r.now = func() time.Time { return now }
+
+	a, _ := newTestPacket(1, 100).Fragment(60)
+	b, _ := newTestPacket(2, 100).Fragment(60)
+	a, b = wire(t, a), wire(t, b)
+
+	r.Add(a[0])
+	now = now.Add(2 * time.Second)
+	r.Add(b[0])
+	if r.Pending() != 1 {
+		t.Errorf("Got %d pending packets, expected the first to time out.", r.Pending())
+	}
+
+	// Each fragment holds 40 bytes, so a third evicts the oldest.
+	now = now.Add(time.Millisecond)
+	r.Add(a[0])
+	r.Add(b[1])
+	if r.Pending() != 1 {
+		t.Errorf("Got %d pending packets, expected 1 after eviction.", r.Pending())
+	}
+
+	if _, err := r.Add(b[1]); err == nil {
+		t.Error("Expected an error adding an overlapping fragment.")
+	}
+}
+
+func TestFragmentDontFragment(t *testing.T) {
+	ip := newTestPacket(1, 100)
+	ip.Flags = FLAG_DF
+	if _, err := ip.Fragment(60); err == nil {
+		t.Error("Expected an error fragmenting a packet with DF set.")
+	}
+	if frags, _ := ip.Fragment(1500); len(frags) != 1 || frags[0] != ip {
+		t.Error("Expected a packet within the MTU to be returned alone.")
+	}
+}
@@ -2,22 +2,60 @@ package ipv4
 
 import (
 	"encoding/binary"
-	"errors"
 	"net"
+	"strconv"
+	"sync"
 
 	"github.com/jonstout/ogo/protocol/icmp"
+	"github.com/jonstout/ogo/protocol/igmp"
+	"github.com/jonstout/ogo/protocol/tcp"
 	"github.com/jonstout/ogo/protocol/udp"
 	"github.com/jonstout/ogo/protocol/util"
 )
 
 const (
 	Type_ICMP     = 0x01
+	Type_IGMP     = 0x02
 	Type_TCP      = 0x06
 	Type_UDP      = 0x11
 	Type_IPv6     = 0x29
 	Type_IPv6ICMP = 0x3a
 )
 
+var protocols = struct {
+	sync.RWMutex
+	m map[uint8]func() util.Message
+}{m: map[uint8]func() util.Message{
+	Type_ICMP: func() util.Message { return icmp.New() },
+	Type_IGMP: func() util.Message { return igmp.New() },
+	Type_TCP:  func() util.Message { return tcp.New() },
+	Type_UDP:  func() util.Message { return udp.New() },
+}}
+
+// Registers fn as the constructor of the payload of packets with
+// the given protocol number, replacing any earlier registration.
+// Payloads with no registration decode as a util.Buffer.
+func RegisterProtocol(protocol uint8, fn func() util.Message) {
+	protocols.Lock()
+	defer protocols.Unlock()
+	protocols.m[protocol] = fn
+}
+
+func newPayload(protocol uint8) util.Message {
+	protocols.RLock()
+	defer protocols.RUnlock()
+	if fn, ok := protocols.m[protocol]; ok {
+		return fn()
+	}
+	return new(util.Buffer)
+}
+
+// Flags
+const (
+	FLAG_MF = 1 << 0 // More fragments
+	FLAG_DF = 1 << 1 // Don't fragment
+)
+
 type IPv4 struct {
 	Version        uint8 //4-bits
 	IHL            uint8 //4-bits
@@ -44,15 +82,60 @@ func New() *IPv4 {
 	return ip
 }
 
+// Returns true if i is one fragment of a larger packet.
+func (i *IPv4) IsFragment() bool {
+	return i.Flags&FLAG_MF != 0 || i.FragmentOffset != 0
+}
+
+// Returns the length of the header including options and their
+// padding.
+func (i *IPv4) HeaderLen() (n uint16) {
+	return (20 + i.Options.Len() + 3) &^ 3
+}
+
 func (i *IPv4) Len() (n uint16) {
-	i.IHL = 5
 	if i.Data != nil {
-		return uint16(i.IHL*4) + i.Data.Len()
+		return i.HeaderLen() + i.Data.Len()
+	}
+	return i.HeaderLen()
+}
+
+// Returns an error if the options do not fit in the 60 bytes a
+// header can be at most.
+func (i *IPv4) checkOptions() error {
+	if n := i.Options.Len(); n > 40 {
+		return util.NewMalformedError("IPv4 message", "options of "+strconv.Itoa(int(n))+" bytes are longer than 40")
+	}
+	return nil
+}
+
+// Sets IHL and Length from the header options and payload.
+func (i *IPv4) FixLengths() error {
+	if err := i.checkOptions(); err != nil {
+		return err
 	}
-	return uint16(i.IHL * 4)
+	i.IHL = uint8(i.HeaderLen() / 4)
+	i.Length = i.Len()
+	return nil
 }
 
+// Computes and sets the header checksum.
+func (i *IPv4) SetChecksum() error {
+	i.Checksum = 0
+	data, err := i.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	i.Checksum = util.Checksum(data[:i.HeaderLen()])
+	return nil
+}
+
+// Sets IHL from the header options.
 func (i *IPv4) MarshalBinary() (data []byte, err error) {
+	if err = i.checkOptions(); err != nil {
+		return
+	}
+	i.IHL = uint8(i.HeaderLen() / 4)
 	data = make([]byte, int(i.Len()))
 	b := make([]byte, 0)
 	n := 0
@@ -84,7 +167,7 @@ func (i *IPv4) MarshalBinary() (data []byte, err error) {
 
 	b, err = i.Options.MarshalBinary()
 	copy(data[n:], b)
-	n += len(b)
+	n = int(i.HeaderLen())
 
 	if i.Data != nil {
 		b, err = i.Data.MarshalBinary()
@@ -99,7 +182,7 @@ func (i *IPv4) MarshalBinary() (data []byte, err error) {
 
 func (i *IPv4) UnmarshalBinary(data []byte) error {
 	if len(data) < 20 {
-		return errors.New("The []byte is too short to unmarshal a full IPv4 message.")
+		return util.NewTruncatedError("IPv4 message", 20, len(data))
 	}
 	n := 0
 
@@ -132,21 +215,42 @@ func (i *IPv4) UnmarshalBinary(data []byte) error {
 	n += 1
 	i.Checksum = binary.BigEndian.Uint16(data[n:])
 	n += 2
-	i.NWSrc = data[n:n+4]
+	i.NWSrc = make(net.IP, 4)
+	copy(i.NWSrc, data[n:n+4])
 	n += 4
-	i.NWDst = data[n:n+4]
+	i.NWDst = make(net.IP, 4)
+	copy(i.NWDst, data[n:n+4])
 	n += 4
 
-	i.Options.UnmarshalBinary(data[n:int(i.IHL * 4)])
-	n += int(i.IHL * 4) - n
+	hdr := int(i.IHL) * 4
+	if hdr < 20 || hdr > len(data) {
+		return util.NewMalformedError("IPv4 message", "header length "+strconv.Itoa(hdr)+" does not fit "+strconv.Itoa(len(data))+" bytes")
+	}
+	i.Options.UnmarshalBinary(data[n:hdr])
+	n = hdr
+
+	// Frames may be padded past the end of the packet.
+	end := int(i.Length)
+	if end < n || end > len(data) {
+		end = len(data)
+	}
+	data = data[:end]
 
-	switch i.Protocol {
-	case Type_ICMP:
-		i.Data = icmp.New()
-	case Type_UDP:
-		i.Data = udp.New()
-	default:
+	if n >= len(data) {
+		i.Data = nil
+		return nil
+	}
+
+	// Fragments carry only part of the upper-layer message, so they
+	// are left for a Reassembler.
+	if i.IsFragment() {
 		i.Data = new(util.Buffer)
+	} else {
+		i.Data = newPayload(i.Protocol)
 	}
 	return i.Data.UnmarshalBinary(data[n:])
 }
+
+func (i *IPv4) Payload() util.Message {
+	return i.Data
+}
@@ -6,6 +6,9 @@ import (
 	"net"
 	"strings"
 	"testing"
+
+	"github.com/jonstout/ogo/protocol/tcp"
+	"github.com/jonstout/ogo/protocol/util"
 )
 
 func TestIPv4MarshalBinary(t *testing.T) {
@@ -74,3 +77,48 @@ func TestIPv4UnmarshalBinary(t *testing.T) {
 		t.Errorf("Got nw-dst %d, expected %d.", ip.NWDst, dst)
 	}
 }
+
+func TestIPv4UnmarshalTCP(t *testing.T) {
+	b := "   45 00 00 28 00 00 00 00 40 06 00 00 " + // Header
+		"0a 00 00 01 0a 00 00 02 " + // NWSrc, NWDst
+		"c0 01 00 50 00 00 03 e8 00 00 00 00 " + // Ports, SeqNum, AckNum
+		"50 02 72 10 00 00 00 00 " // DataOffset, Flags, WinSize, ...
+	b = strings.Replace(b, " ", "", -1)
+	byte, _ := hex.DecodeString(b)
+
+	ip := New()
+	if err := ip.UnmarshalBinary(byte); err != nil {
+		t.Fatal(err)
+	}
+	s, ok := ip.Data.(*tcp.TCP)
+	if !ok {
+		t.Fatalf("Got %T, expected *tcp.TCP.", ip.Data)
+	}
+	if s.PortDst != 80 || !s.HasFlags(tcp.SYN) {
+		t.Errorf("Got port %d flags %x, expected 80 and SYN.", s.PortDst, s.Flags)
+	}
+}
+
+func TestIPv4OptionsTooLong(t *testing.T) {
+	ip := New()
+	ip.Version = 4
+	ip.Options = *util.NewBuffer(make([]byte, 44))
+
+	if _, err := ip.MarshalBinary(); err == nil {
+		t.Error("Expected an error marshaling 44 bytes of options.")
+	}
+	if err := ip.FixLengths(); err == nil {
+		t.Error("Expected an error fixing lengths with 44 bytes of options.")
+	}
+	if err := ip.SetChecksum(); err == nil {
+		t.Error("Expected an error computing a checksum with 44 bytes of options.")
+	}
+
+	ip.Options = *util.NewBuffer(make([]byte, 40))
+	if err := ip.FixLengths(); err != nil {
+		t.Fatal(err)
+	}
+	if ip.IHL != 15 {
+		t.Errorf("Got IHL %d, expected 15.", ip.IHL)
+	}
+}
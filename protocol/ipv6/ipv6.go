@@ -0,0 +1,384 @@
+package ipv6
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"github.com/jonstout/ogo/protocol/icmpv6"
+	"github.com/jonstout/ogo/protocol/tcp"
+	"github.com/jonstout/ogo/protocol/udp"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Next header values.
+const (
+	Type_HopByHop = 0x00
+	Type_TCP      = 0x06
+	Type_UDP      = 0x11
+	Type_Routing  = 0x2b
+	Type_Fragment = 0x2c
+	Type_ICMPv6   = 0x3a
+	Type_NoNext   = 0x3b
+	Type_DestOpts = 0x3c
+)
+
+var protocols = struct {
+	sync.RWMutex
+	m map[uint8]func() util.Message
+}{m: map[uint8]func() util.Message{
+	Type_TCP:    func() util.Message { return tcp.New() },
+	Type_UDP:    func() util.Message { return udp.New() },
+	Type_ICMPv6: func() util.Message { return icmpv6.New() },
+}}
+
+// Registers fn as the constructor of upper-layer payloads with the
+// given next header value, replacing any earlier registration.
+// Extension headers cannot be registered. Payloads with no
+// registration decode as a util.Buffer.
+func RegisterProtocol(protocol uint8, fn func() util.Message) {
+	protocols.Lock()
+	defer protocols.Unlock()
+	protocols.m[protocol] = fn
+}
+
+func newPayload(protocol uint8) util.Message {
+	protocols.RLock()
+	defer protocols.RUnlock()
+	if fn, ok := protocols.m[protocol]; ok {
+		return fn()
+	}
+	return new(util.Buffer)
+}
+
+type IPv6 struct {
+	Version      uint8 //4-bits
+	TrafficClass uint8
+	FlowLabel    uint32 //20-bits
+	Length       uint16 // Payload length, including extension headers.
+	NextHeader   uint8
+	HopLimit     uint8
+	NWSrc        net.IP
+	NWDst        net.IP
+	Extensions   []Extension
+	Data         util.Message
+}
+
+func New() *IPv6 {
+	ip := new(IPv6)
+	ip.Version = 6
+	ip.NextHeader = Type_NoNext
+	ip.HopLimit = 64
+	ip.NWSrc = make([]byte, 16)
+	ip.NWDst = make([]byte, 16)
+	ip.Extensions = make([]Extension, 0)
+	return ip
+}
+
+// Returns the next header value of the upper-layer protocol, the
+// one following the last extension header.
+func (i *IPv6) Protocol() uint8 {
+	if len(i.Extensions) > 0 {
+		return i.Extensions[len(i.Extensions)-1].Next()
+	}
+	return i.NextHeader
+}
+
+func (i *IPv6) Len() (n uint16) {
+	n = 40
+	for _, e := range i.Extensions {
+		n += e.Len()
+	}
+	if i.Data != nil {
+		n += i.Data.Len()
+	}
+	return
+}
+
+func (i *IPv6) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 40, int(i.Len()))
+	n := 0
+
+	var vtf uint32 = uint32(i.Version)<<28 | uint32(i.TrafficClass)<<20 | i.FlowLabel&0xfffff
+	binary.BigEndian.PutUint32(data[n:], vtf)
+	n += 4
+	binary.BigEndian.PutUint16(data[n:], i.Length)
+	n += 2
+	data[n] = i.NextHeader
+	n += 1
+	data[n] = i.HopLimit
+	n += 1
+	copy(data[n:], i.NWSrc.To16())
+	n += 16
+	copy(data[n:], i.NWDst.To16())
+	n += 16
+
+	var b []byte
+	for _, e := range i.Extensions {
+		if b, err = e.MarshalBinary(); err != nil {
+			return
+		}
+		data = append(data, b...)
+	}
+	if i.Data != nil {
+		if b, err = i.Data.MarshalBinary(); err != nil {
+			return
+		}
+		data = append(data, b...)
+	}
+	return
+}
+
+func (i *IPv6) UnmarshalBinary(data []byte) error {
+	if len(data) < 40 {
+		return util.NewTruncatedError("IPv6 message", 40, len(data))
+	}
+	n := 0
+
+	vtf := binary.BigEndian.Uint32(data[n:])
+	i.Version = uint8(vtf >> 28)
+	i.TrafficClass = uint8(vtf >> 20)
+	i.FlowLabel = vtf & 0xfffff
+	n += 4
+	i.Length = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	i.NextHeader = data[n]
+	n += 1
+	i.HopLimit = data[n]
+	n += 1
+	i.NWSrc = make([]byte, 16)
+	copy(i.NWSrc, data[n:])
+	n += 16
+	i.NWDst = make([]byte, 16)
+	copy(i.NWDst, data[n:])
+	n += 16
+
+	// A zero payload length is used by jumbograms, whose real
+	// length is in a hop-by-hop option.
+	end := n + int(i.Length)
+	if i.Length == 0 || end > len(data) {
+		end = len(data)
+	}
+
+	i.Extensions = make([]Extension, 0)
+	next := i.NextHeader
+	fragment := false
+	for {
+		var e Extension
+		switch next {
+		case Type_HopByHop:
+			e = NewHopByHop()
+		case Type_Routing:
+			e = NewRouting()
+		case Type_Fragment:
+			e = NewFragment()
+		case Type_DestOpts:
+			e = NewDestinationOptions()
+		}
+		if e == nil {
+			break
+		}
+		if err := e.UnmarshalBinary(data[n:end]); err != nil {
+			return err
+		}
+		if f, ok := e.(*Fragment); ok && f.FragmentOffset != 0 {
+			fragment = true
+		}
+		i.Extensions = append(i.Extensions, e)
+		next = e.Next()
+		n += int(e.Len())
+	}
+
+	i.Data = nil
+	if n >= end || next == Type_NoNext {
+		return nil
+	}
+	if fragment {
+		// Only the first fragment starts with the upper-layer
+		// header.
+		i.Data = new(util.Buffer)
+	} else {
+		i.Data = newPayload(next)
+	}
+	return i.Data.UnmarshalBinary(data[n:end])
+}
+
+// Sets Length from the extension headers and payload.
+func (i *IPv6) FixLengths() error {
+	i.Length = i.Len() - 40
+	return nil
+}
+
+func (i *IPv6) Payload() util.Message {
+	return i.Data
+}
+
+// An IPv6 extension header. Next returns the next header value
+// of the header that follows it.
+type Extension interface {
+	util.Message
+	Next() uint8
+}
+
+// The fields shared by hop-by-hop and destination options headers.
+// Options holds the encoded option TLVs; marshaling pads them with
+// Pad1 options to a multiple of 8 bytes.
+type OptionsHeader struct {
+	NextHeader uint8
+	HdrExtLen  uint8
+	Options    []byte
+}
+
+func (o *OptionsHeader) Next() uint8 {
+	return o.NextHeader
+}
+
+func (o *OptionsHeader) Len() (n uint16) {
+	return uint16(2+len(o.Options)+7) &^ 7
+}
+
+func (o *OptionsHeader) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(o.Len()))
+	o.HdrExtLen = uint8(len(data)/8 - 1)
+	data[0] = o.NextHeader
+	data[1] = o.HdrExtLen
+	copy(data[2:], o.Options)
+	return
+}
+
+func (o *OptionsHeader) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return util.NewTruncatedError("IPv6 options header", 8, len(data))
+	}
+	o.NextHeader = data[0]
+	o.HdrExtLen = data[1]
+	length := 8 + int(o.HdrExtLen)*8
+	if length > len(data) {
+		return util.NewTruncatedError("IPv6 options header", length, len(data))
+	}
+	o.Options = make([]byte, length-2)
+	copy(o.Options, data[2:length])
+	return nil
+}
+
+// Options examined by every node along the path.
+type HopByHop struct {
+	OptionsHeader
+}
+
+func NewHopByHop() *HopByHop {
+	h := new(HopByHop)
+	h.Options = make([]byte, 6)
+	return h
+}
+
+// Options examined by the destination only.
+type DestinationOptions struct {
+	OptionsHeader
+}
+
+func NewDestinationOptions() *DestinationOptions {
+	d := new(DestinationOptions)
+	d.Options = make([]byte, 6)
+	return d
+}
+
+// Lists intermediate nodes to visit on the way to the destination.
+// Data holds the type specific data that follows SegmentsLeft.
+type Routing struct {
+	NextHeader   uint8
+	HdrExtLen    uint8
+	RoutingType  uint8
+	SegmentsLeft uint8
+	Data         []byte
+}
+
+func NewRouting() *Routing {
+	r := new(Routing)
+	r.Data = make([]byte, 4)
+	return r
+}
+
+func (r *Routing) Next() uint8 {
+	return r.NextHeader
+}
+
+func (r *Routing) Len() (n uint16) {
+	return uint16(4+len(r.Data)+7) &^ 7
+}
+
+func (r *Routing) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(r.Len()))
+	r.HdrExtLen = uint8(len(data)/8 - 1)
+	data[0] = r.NextHeader
+	data[1] = r.HdrExtLen
+	data[2] = r.RoutingType
+	data[3] = r.SegmentsLeft
+	copy(data[4:], r.Data)
+	return
+}
+
+func (r *Routing) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return util.NewTruncatedError("IPv6 routing header", 8, len(data))
+	}
+	r.NextHeader = data[0]
+	r.HdrExtLen = data[1]
+	r.RoutingType = data[2]
+	r.SegmentsLeft = data[3]
+	length := 8 + int(r.HdrExtLen)*8
+	if length > len(data) {
+		return util.NewTruncatedError("IPv6 routing header", length, len(data))
+	}
+	r.Data = make([]byte, length-4)
+	copy(r.Data, data[4:length])
+	return nil
+}
+
+// Carries the position of a fragment within the original packet.
+// FragmentOffset is in units of 8 bytes.
+type Fragment struct {
+	NextHeader     uint8
+	reserved       uint8
+	FragmentOffset uint16 //13-bits
+	MoreFragments  bool
+	Id             uint32
+}
+
+func NewFragment() *Fragment {
+	return new(Fragment)
+}
+
+func (f *Fragment) Next() uint8 {
+	return f.NextHeader
+}
+
+func (f *Fragment) Len() (n uint16) {
+	return 8
+}
+
+func (f *Fragment) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(f.Len()))
+	data[0] = f.NextHeader
+	data[1] = f.reserved
+	var off uint16 = f.FragmentOffset << 3
+	if f.MoreFragments {
+		off |= 1
+	}
+	binary.BigEndian.PutUint16(data[2:], off)
+	binary.BigEndian.PutUint32(data[4:], f.Id)
+	return
+}
+
+func (f *Fragment) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return util.NewTruncatedError("IPv6 fragment header", 8, len(data))
+	}
+	f.NextHeader = data[0]
+	f.reserved = data[1]
+	off := binary.BigEndian.Uint16(data[2:])
+	f.FragmentOffset = off >> 3
+	f.MoreFragments = off&1 != 0
+	f.Id = binary.BigEndian.Uint32(data[4:])
+	return nil
+}
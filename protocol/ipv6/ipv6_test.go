@@ -0,0 +1,85 @@
+package ipv6
+
+import (
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/udp"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+func TestIPv6UnmarshalBinary(t *testing.T) {
+	b := "   60 00 00 01 " + // Version, TrafficClass, FlowLabel
+		"00 18 00 40 " + // Length, NextHeader, HopLimit
+		"fe 80 00 00 00 00 00 00 00 00 00 00 00 00 00 01 " + // NWSrc
+		"ff 02 00 00 00 00 00 00 00 00 00 00 00 00 00 01 " + // NWDst
+		"11 00 05 02 00 00 01 00 " + // HopByHop: router alert, PadN
+		"02 22 02 23 00 10 00 00 " + // UDP
+		"01 02 03 04 05 06 07 08 " // Data
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	ip := New()
+	if err := ip.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if ip.Version != 6 || ip.FlowLabel != 1 || ip.HopLimit != 64 {
+		t.Errorf("Got version %d flow %d hop limit %d.", ip.Version, ip.FlowLabel, ip.HopLimit)
+	}
+	if !ip.NWSrc.Equal(net.ParseIP("fe80::1")) || !ip.NWDst.Equal(net.ParseIP("ff02::1")) {
+		t.Errorf("Got %s -> %s.", ip.NWSrc, ip.NWDst)
+	}
+	if len(ip.Extensions) != 1 {
+		t.Fatalf("Got %d extension headers, expected 1.", len(ip.Extensions))
+	}
+	if _, ok := ip.Extensions[0].(*HopByHop); !ok {
+		t.Errorf("Got %T, expected *HopByHop.", ip.Extensions[0])
+	}
+	if ip.Protocol() != Type_UDP {
+		t.Errorf("Got protocol %d, expected %d.", ip.Protocol(), Type_UDP)
+	}
+	if u, ok := ip.Data.(*udp.UDP); !ok || u.PortDst != 547 {
+		t.Errorf("Got %T, expected *udp.UDP to port 547.", ip.Data)
+	}
+
+	d, _ := ip.MarshalBinary()
+	if hex.EncodeToString(d) != b {
+		t.Log("Exp:", b)
+		t.Log("Rec:", hex.EncodeToString(d))
+		t.Error("IPv6 did not marshal back to its original bytes.")
+	}
+}
+
+func TestIPv6Fragment(t *testing.T) {
+	b := "   60 00 00 00 00 10 2c 40 " + // Header
+		"20 01 0d b8 00 00 00 00 00 00 00 00 00 00 00 01 " + // NWSrc
+		"20 01 0d b8 00 00 00 00 00 00 00 00 00 00 00 02 " + // NWDst
+		"11 00 00 b9 00 00 00 2a " + // Fragment: offset 23, M, id 42
+		"aa bb cc dd ee ff 00 11 " // Data
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	ip := New()
+	if err := ip.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	f, ok := ip.Extensions[0].(*Fragment)
+	if !ok {
+		t.Fatalf("Got %T, expected *Fragment.", ip.Extensions[0])
+	}
+	if f.FragmentOffset != 23 || !f.MoreFragments || f.Id != 42 {
+		t.Errorf("Got offset %d more %t id %d.", f.FragmentOffset, f.MoreFragments, f.Id)
+	}
+	if _, ok := ip.Data.(*util.Buffer); !ok {
+		t.Errorf("Got %T, expected the fragment payload as a *util.Buffer.", ip.Data)
+	}
+
+	d, _ := ip.MarshalBinary()
+	if hex.EncodeToString(d) != b {
+		t.Log("Exp:", b)
+		t.Log("Rec:", hex.EncodeToString(d))
+		t.Error("IPv6 did not marshal back to its original bytes.")
+	}
+}
@@ -0,0 +1,245 @@
+// Package lacp implements the Link Aggregation Control Protocol,
+// 802.1AX.
+//
+// Importing lacp registers SlowProtocol as the payload of slow
+// protocol frames. Subtypes other than LACP, such as Marker and
+// OAM, decode as a util.Buffer.
+package lacp
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+func init() {
+	eth.RegisterEthertype(eth.SLOW_MSG, func() util.Message { return new(SlowProtocol) })
+}
+
+// Destination of LACPDUs, the slow protocols multicast address.
+var SlowProtocols = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x02}
+
+// Slow protocol subtype of LACP.
+const Subtype_LACP = 1
+
+// A slow protocol frame, 802.3 annex 57A. Data holds a *LACP for
+// LACPDUs and a util.Buffer for other subtypes. Each begins with
+// the subtype.
+type SlowProtocol struct {
+	Data util.Message
+}
+
+func (s *SlowProtocol) Len() (n uint16) {
+	if s.Data != nil {
+		n = s.Data.Len()
+	}
+	return
+}
+
+func (s *SlowProtocol) MarshalBinary() (data []byte, err error) {
+	if s.Data == nil {
+		return nil, util.NewMalformedError("slow protocol message", "no subtype")
+	}
+	return s.Data.MarshalBinary()
+}
+
+func (s *SlowProtocol) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		s.Data = nil
+		return util.NewTruncatedError("slow protocol message", 1, len(data))
+	}
+	if data[0] == Subtype_LACP {
+		s.Data = New()
+	} else {
+		s.Data = new(util.Buffer)
+	}
+	return s.Data.UnmarshalBinary(data)
+}
+
+func (s *SlowProtocol) Payload() util.Message {
+	return s.Data
+}
+
+// TLV types
+const (
+	TLV_TERMINATOR = 0
+	TLV_ACTOR      = 1
+	TLV_PARTNER    = 2
+	TLV_COLLECTOR  = 3
+)
+
+// Port state bits
+const (
+	STATE_ACTIVITY     = 1 << 0 // Active rather than passive
+	STATE_TIMEOUT      = 1 << 1 // Short rather than long timeout
+	STATE_AGGREGATION  = 1 << 2 // Aggregatable rather than individual
+	STATE_SYNC         = 1 << 3
+	STATE_COLLECTING   = 1 << 4
+	STATE_DISTRIBUTING = 1 << 5
+	STATE_DEFAULTED    = 1 << 6
+	STATE_EXPIRED      = 1 << 7
+)
+
+// The actor or partner of a link.
+type Info struct {
+	SystemPriority uint16
+	System         net.HardwareAddr
+	Key            uint16
+	PortPriority   uint16
+	Port           uint16
+	State          uint8
+}
+
+func (i *Info) Len() (n uint16) {
+	return 20
+}
+
+func (i *Info) marshal(data []byte, typ uint8) {
+	n := 0
+	data[n] = typ
+	n += 1
+	data[n] = uint8(i.Len())
+	n += 1
+	binary.BigEndian.PutUint16(data[n:], i.SystemPriority)
+	n += 2
+	copy(data[n:n+6], i.System)
+	n += 6
+	binary.BigEndian.PutUint16(data[n:], i.Key)
+	n += 2
+	binary.BigEndian.PutUint16(data[n:], i.PortPriority)
+	n += 2
+	binary.BigEndian.PutUint16(data[n:], i.Port)
+	n += 2
+	data[n] = i.State
+}
+
+func (i *Info) unmarshal(data []byte, typ uint8) error {
+	if data[0] != typ || data[1] != uint8(i.Len()) {
+		return util.NewMalformedError("LACP message", "bad actor or partner information")
+	}
+	n := 2
+	i.SystemPriority = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	i.System = make(net.HardwareAddr, 6)
+	copy(i.System, data[n:])
+	n += 6
+	i.Key = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	i.PortPriority = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	i.Port = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	i.State = data[n]
+	return nil
+}
+
+type LACP struct {
+	Subtype           uint8
+	Version           uint8
+	Actor             Info
+	Partner           Info
+	CollectorMaxDelay uint16 // Tens of microseconds
+}
+
+func New() *LACP {
+	l := new(LACP)
+	l.Subtype = Subtype_LACP
+	l.Version = 1
+	l.Actor.System = make(net.HardwareAddr, 6)
+	l.Partner.System = make(net.HardwareAddr, 6)
+	return l
+}
+
+func (l *LACP) Len() (n uint16) {
+	return 110
+}
+
+func (l *LACP) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(l.Len()))
+	n := 0
+	data[n] = l.Subtype
+	n += 1
+	data[n] = l.Version
+	n += 1
+	l.Actor.marshal(data[n:], TLV_ACTOR)
+	n += int(l.Actor.Len())
+	l.Partner.marshal(data[n:], TLV_PARTNER)
+	n += int(l.Partner.Len())
+	data[n] = TLV_COLLECTOR
+	data[n+1] = 16
+	binary.BigEndian.PutUint16(data[n+2:], l.CollectorMaxDelay)
+	n += 16
+	// The terminator and reserved bytes are zero.
+	return
+}
+
+func (l *LACP) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return util.NewTruncatedError("LACP message", 2, len(data))
+	}
+	n := 0
+	l.Subtype = data[n]
+	n += 1
+	if l.Subtype != Subtype_LACP {
+		return util.NewMalformedError("LACP message", "slow protocol subtype "+strconv.Itoa(int(l.Subtype))+" is not LACP")
+	}
+	l.Version = data[n]
+	n += 1
+	// Frames may be cut short of the reserved bytes.
+	if len(data) < n+56 {
+		return util.NewTruncatedError("LACP message", n+56, len(data))
+	}
+	if err := l.Actor.unmarshal(data[n:], TLV_ACTOR); err != nil {
+		return err
+	}
+	n += int(l.Actor.Len())
+	if err := l.Partner.unmarshal(data[n:], TLV_PARTNER); err != nil {
+		return err
+	}
+	n += int(l.Partner.Len())
+	if data[n] != TLV_COLLECTOR || data[n+1] != 16 {
+		return util.NewMalformedError("LACP message", "bad collector information")
+	}
+	l.CollectorMaxDelay = binary.BigEndian.Uint16(data[n+2:])
+	return nil
+}
+
+// Identifies a link aggregation group. Links whose LACPDUs have equal
+// aggregates are bundled together.
+type Aggregate struct {
+	ActorPriority   uint16
+	ActorSystem     string
+	ActorKey        uint16
+	PartnerPriority uint16
+	PartnerSystem   string
+	PartnerKey      uint16
+}
+
+// Returns the aggregate of the link l was received on, or false if
+// the actor has not agreed to aggregate it.
+func (l *LACP) Aggregate() (a Aggregate, ok bool) {
+	if l.Actor.State&STATE_AGGREGATION == 0 || l.Actor.State&STATE_SYNC == 0 {
+		return
+	}
+	a.ActorPriority = l.Actor.SystemPriority
+	a.ActorSystem = l.Actor.System.String()
+	a.ActorKey = l.Actor.Key
+	a.PartnerPriority = l.Partner.SystemPriority
+	a.PartnerSystem = l.Partner.System.String()
+	a.PartnerKey = l.Partner.Key
+	return a, true
+}
+
+// Returns a frame from hwSrc carrying l to the slow protocols
+// address.
+func NewFrame(hwSrc net.HardwareAddr, l *LACP) *eth.Ethernet {
+	e := eth.New()
+	e.HWSrc = hwSrc
+	e.HWDst = SlowProtocols
+	e.Ethertype = eth.SLOW_MSG
+	e.Data = &SlowProtocol{l}
+	return e
+}
@@ -0,0 +1,125 @@
+package lacp
+
+import (
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+var lacpdu = "   01 01 " + // Subtype, Version
+	"01 14 80 00 00 11 22 33 44 55 00 0a 80 00 00 03 3d 00 00 00 " + // Actor
+	"02 14 80 00 00 aa bb cc dd ee 00 0b 80 00 00 07 3d 00 00 00 " + // Partner
+	"03 10 00 32 00 00 00 00 00 00 00 00 00 00 00 00 " + // Collector
+	"00 00 " + // Terminator
+	"00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 " +
+	"00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 " // Reserved
+
+func TestLACPMarshalBinary(t *testing.T) {
+	b := strings.Replace(lacpdu, " ", "", -1)
+
+	l := New()
+	l.Actor = Info{0x8000, net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55}, 10, 0x8000, 3, 0x3d}
+	l.Partner = Info{0x8000, net.HardwareAddr{0, 0xaa, 0xbb, 0xcc, 0xdd, 0xee}, 11, 0x8000, 7, 0x3d}
+	l.CollectorMaxDelay = 50
+
+	data, err := l.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := hex.EncodeToString(data)
+	if (len(b) != len(s)) || (b != s) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", s)
+		t.Errorf("Received length of %d, expected %d", len(s), len(b))
+	}
+}
+
+func TestLACPUnmarshalBinary(t *testing.T) {
+	data, _ := hex.DecodeString(strings.Replace(lacpdu, " ", "", -1))
+
+	l := New()
+	if err := l.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if l.Actor.System.String() != "00:11:22:33:44:55" || l.Actor.Key != 10 || l.Actor.Port != 3 {
+		t.Errorf("Got actor %+v.", l.Actor)
+	}
+	if l.Partner.State&STATE_DISTRIBUTING == 0 {
+		t.Errorf("Got partner state %02x, expected distributing.", l.Partner.State)
+	}
+	if l.CollectorMaxDelay != 50 {
+		t.Errorf("Got collector delay %d, expected 50.", l.CollectorMaxDelay)
+	}
+
+	marker, _ := hex.DecodeString("0201")
+	if err := l.UnmarshalBinary(marker); err == nil {
+		t.Error("Unmarshaled a marker PDU as LACP.")
+	}
+}
+
+func TestLACPAggregate(t *testing.T) {
+	data, _ := hex.DecodeString(strings.Replace(lacpdu, " ", "", -1))
+
+	a, b := New(), New()
+	a.UnmarshalBinary(data)
+	b.UnmarshalBinary(data)
+	b.Actor.Port = 4
+
+	aggA, ok := a.Aggregate()
+	if !ok {
+		t.Fatal("Link is not aggregated.")
+	}
+	aggB, _ := b.Aggregate()
+	if aggA != aggB {
+		t.Errorf("Got aggregates %+v and %+v, expected them equal.", aggA, aggB)
+	}
+
+	b.Actor.State &^= STATE_SYNC
+	if _, ok := b.Aggregate(); ok {
+		t.Error("Got an aggregate for an unsynchronized link.")
+	}
+}
+
+func TestLACPFrame(t *testing.T) {
+	src, _ := net.ParseMAC("00:11:22:33:44:55")
+	data, err := NewFrame(src, New()).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := eth.New()
+	if err := e.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := e.Data.(*SlowProtocol); !ok {
+		t.Errorf("Got payload %T, expected *SlowProtocol.", e.Data)
+	} else if _, ok := s.Data.(*LACP); !ok {
+		t.Errorf("Got slow protocol %T, expected *LACP.", s.Data)
+	}
+}
+
+func TestSlowProtocolMarker(t *testing.T) {
+	// A marker PDU, subtype 2.
+	s := "   02 01 " + // Subtype, Version
+		"01 10 00 03 00 11 22 33 44 55 00 00 00 01 00 00 " + // Marker information
+		"00 00 " // Terminator
+	s = strings.Replace(s, " ", "", -1)
+	data, _ := hex.DecodeString(s)
+
+	p := new(SlowProtocol)
+	if err := p.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.Data.(*util.Buffer); !ok {
+		t.Errorf("Got slow protocol %T, expected *util.Buffer.", p.Data)
+	}
+	b, _ := p.MarshalBinary()
+	if d := hex.EncodeToString(b); d != s {
+		t.Log("Exp:", s)
+		t.Log("Rec:", d)
+		t.Error("Marker PDU did not marshal back to its original bytes.")
+	}
+}
@@ -1,54 +1,185 @@
+// Package lldp implements the Link Layer Discovery Protocol of
+// IEEE 802.1AB.
 package lldp
 
 import (
-	"bytes"
 	"encoding/binary"
+	"errors"
+	"net"
+
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Multicast address LLDP frames are sent to. Bridges never forward
+// frames sent to it.
+var NearestBridge = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e}
+
+// TLV types
+const (
+	TLV_END = iota
+	TLV_CHASSIS_ID
+	TLV_PORT_ID
+	TLV_TTL
+	TLV_PORT_DESC
+	TLV_SYSTEM_NAME
+	TLV_SYSTEM_DESC
+	TLV_SYSTEM_CAPS
+	TLV_MGMT_ADDR
+
+	TLV_ORG_SPECIFIC = 127
 )
 
+// The TLVs following the mandatory ones. Chassis ID, Port ID and
+// TTL TLVs are held by the LLDP fields of the same name, and the
+// End TLV is implied.
+type TLV interface {
+	util.Message
+	TLVType() uint8
+}
+
 type LLDP struct {
 	Chassis ChassisTLV
 	Port    PortTLV
 	TTL     TTLTLV
+	TLVs    []TLV
 }
 
-func (d *LLDP) Len() (n uint16) {
-	return 15
+// Returns an LLDPDU advertising port of chassis, valid for ttl
+// seconds.
+func New(chassis *ChassisTLV, port *PortTLV, ttl uint16) *LLDP {
+	d := new(LLDP)
+	d.Chassis = *chassis
+	d.Port = *port
+	d.TTL = *NewTTLTLV(ttl)
+	d.TLVs = make([]TLV, 0)
+	return d
 }
 
-func (d *LLDP) Read(b []byte) (n int, err error) {
-	m, o, p := 0, 0, 0
-	if m, err = d.Chassis.Read(b); m == 0 {
-		return
+// Appends an optional TLV.
+func (d *LLDP) AddTLV(t TLV) {
+	d.TLVs = append(d.TLVs, t)
+}
+
+// Returns the first optional TLV of type typ.
+func (d *LLDP) TLV(typ uint8) TLV {
+	for _, t := range d.TLVs {
+		if t.TLVType() == typ {
+			return t
+		}
 	}
-	n += m
-	if o, err = d.Port.Read(b); o == 0 {
-		return
+	return nil
+}
+
+// Returns the text of the first optional TLV of type typ, which
+// is one of TLV_PORT_DESC, TLV_SYSTEM_NAME and TLV_SYSTEM_DESC.
+func (d *LLDP) Text(typ uint8) (s string, ok bool) {
+	if t, ok := d.TLV(typ).(*TextTLV); ok {
+		return t.Text, true
 	}
-	n += o
-	if p, err = d.Chassis.Read(b); p == 0 {
-		return
+	return
+}
+
+func (d *LLDP) Len() (n uint16) {
+	n += d.Chassis.Len()
+	n += d.Port.Len()
+	n += d.TTL.Len()
+	for _, t := range d.TLVs {
+		n += t.Len()
 	}
-	n += p
+	n += 2
 	return
 }
 
-func (d *LLDP) Write(b []byte) (n int, err error) {
-	m, o, p := 0, 0, 0
-	if m, err = d.Chassis.Write(b); m == 0 {
-		return
+func (d *LLDP) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 0, int(d.Len()))
+	ts := []TLV{&d.Chassis, &d.Port, &d.TTL}
+	ts = append(ts, d.TLVs...)
+	for _, t := range ts {
+		var b []byte
+		if b, err = t.MarshalBinary(); err != nil {
+			return
+		}
+		data = append(data, b...)
 	}
-	n += m
-	if o, err = d.Port.Write(b[n:]); o == 0 {
-		return
+	// End of LLDPDU
+	data = append(data, 0, 0)
+	return
+}
+
+func (d *LLDP) UnmarshalBinary(data []byte) error {
+	n := 0
+	ts := []TLV{&d.Chassis, &d.Port, &d.TTL}
+	for _, t := range ts {
+		typ, length, err := header(data[n:])
+		if err != nil {
+			return err
+		}
+		if typ != t.TLVType() {
+			return util.NewMalformedError("LLDPDU", "it does not start with the Chassis ID, Port ID and TTL TLVs")
+		}
+		if err := t.UnmarshalBinary(data[n : n+2+length]); err != nil {
+			return err
+		}
+		n += 2 + length
 	}
-	n += o
-	if p, err = d.Chassis.Write(b[n:]); p == 0 {
-		return
+
+	d.TLVs = make([]TLV, 0)
+	// Frames may be padded past the End TLV, and some senders omit
+	// it altogether.
+	for n < len(data) {
+		typ, length, err := header(data[n:])
+		if err != nil {
+			return err
+		}
+		if typ == TLV_END {
+			break
+		}
+		var t TLV
+		switch typ {
+		case TLV_PORT_DESC, TLV_SYSTEM_NAME, TLV_SYSTEM_DESC:
+			t = new(TextTLV)
+		case TLV_SYSTEM_CAPS:
+			t = new(CapabilitiesTLV)
+		case TLV_MGMT_ADDR:
+			t = new(ManagementAddressTLV)
+		case TLV_ORG_SPECIFIC:
+			t = new(OrgTLV)
+		default:
+			t = new(UnknownTLV)
+		}
+		if err := t.UnmarshalBinary(data[n : n+2+length]); err != nil {
+			return err
+		}
+		d.TLVs = append(d.TLVs, t)
+		n += 2 + length
+	}
+	return nil
+}
+
+// Returns the type and value length of the TLV at the start of
+// data, checking that the whole TLV is present.
+func header(data []byte) (typ uint8, length int, err error) {
+	if len(data) < 2 {
+		return 0, 0, util.NewTruncatedError("LLDP TLV", 2, len(data))
+	}
+	typeAndLen := binary.BigEndian.Uint16(data)
+	typ = uint8(typeAndLen >> 9)
+	length = int(typeAndLen & 0x01ff)
+	if len(data) < 2+length {
+		return 0, 0, util.NewTruncatedError("LLDP TLV", 2+length, len(data))
 	}
-	n += p
 	return
 }
 
+// Writes the header of a TLV of type typ with length bytes of value.
+func putHeader(data []byte, typ uint8, length int) error {
+	if length > 0x01ff {
+		return errors.New("LLDP TLV value is longer than 511 bytes.")
+	}
+	binary.BigEndian.PutUint16(data, uint16(typ)<<9|uint16(length))
+	return nil
+}
+
 // Chassis ID subtypes
 const (
 	_ = iota
@@ -62,44 +193,43 @@ const (
 )
 
 type ChassisTLV struct {
-	Type    uint8
-	Length  uint16
 	Subtype uint8
 	Data    []uint8
 }
 
-func (t *ChassisTLV) Read(b []byte) (n int, err error) {
-	buf := new(bytes.Buffer)
-	var tni uint16 = 0
-	typeAndLen := (tni | uint16(t.Type)<<9) + (tni | uint16(t.Length))
-	binary.Write(buf, binary.BigEndian, typeAndLen)
-	binary.Write(buf, binary.BigEndian, t.Subtype)
-	binary.Write(buf, binary.BigEndian, t.Data)
-	n, err = buf.Read(b)
-	return
+// Returns a Chassis ID TLV identifying the chassis by mac.
+func NewChassisMAC(mac net.HardwareAddr) *ChassisTLV {
+	return &ChassisTLV{CH_MAC_ADDR, []byte(mac)}
 }
 
-func (t *ChassisTLV) Write(b []byte) (n int, err error) {
-	buf := bytes.NewBuffer(b)
-	var typeAndLen uint16 = 0
-	if err = binary.Read(buf, binary.BigEndian, &typeAndLen); err != nil {
-		return
-	}
-	n += 2
-	t.Type = uint8(typeAndLen >> 9)
-	t.Length = uint16(uint16(0x01ff) & typeAndLen)
-	if err = binary.Read(buf, binary.BigEndian, &t.Subtype); err != nil {
-		return
-	}
-	n += 1
-	t.Data = make([]uint8, t.Length)
-	if err = binary.Read(buf, binary.BigEndian, &t.Data); err != nil {
+func (t *ChassisTLV) TLVType() uint8 {
+	return TLV_CHASSIS_ID
+}
+
+func (t *ChassisTLV) Len() (n uint16) {
+	return uint16(3 + len(t.Data))
+}
+
+func (t *ChassisTLV) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(t.Len()))
+	if err = putHeader(data, TLV_CHASSIS_ID, len(data)-2); err != nil {
 		return
 	}
-	n += int(t.Length)
+	data[2] = t.Subtype
+	copy(data[3:], t.Data)
 	return
 }
 
+func (t *ChassisTLV) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return util.NewTruncatedError("ChassisTLV message", 4, len(data))
+	}
+	t.Subtype = data[2]
+	t.Data = make([]byte, len(data)-3)
+	copy(t.Data, data[3:])
+	return nil
+}
+
 // Port ID subtypes
 const (
 	_ = iota
@@ -113,72 +243,73 @@ const (
 )
 
 type PortTLV struct {
-	Type    uint8  //7bits
-	Length  uint16 //9bits
 	Subtype uint8
 	Data    []uint8
 }
 
-func (t *PortTLV) Read(b []byte) (n int, err error) {
-	buf := new(bytes.Buffer)
-	var tni uint16 = 0
-	typeAndLen := (tni | uint16(t.Type)<<9) + (tni | uint16(t.Length))
-	binary.Write(buf, binary.BigEndian, typeAndLen)
-	binary.Write(buf, binary.BigEndian, t.Subtype)
-	binary.Write(buf, binary.BigEndian, t.Data)
-	n, err = buf.Read(b)
-	return
+// Returns a Port ID TLV identifying the port by its locally
+// assigned number.
+func NewPortNumber(port uint16) *PortTLV {
+	t := &PortTLV{PT_LOCAL_ASSGN, make([]byte, 2)}
+	binary.BigEndian.PutUint16(t.Data, port)
+	return t
 }
 
-func (t *PortTLV) Write(b []byte) (n int, err error) {
-	buf := bytes.NewBuffer(b)
-	var typeAndLen uint16 = 0
-	if err = binary.Read(buf, binary.BigEndian, &typeAndLen); err != nil {
-		return
-	}
-	n += 2
-	t.Type = uint8(typeAndLen >> 9)
-	t.Length = uint16(uint16(0x01ff) & typeAndLen)
-	if err = binary.Read(buf, binary.BigEndian, &t.Subtype); err != nil {
-		return
-	}
-	n += 1
-	t.Data = make([]uint8, t.Length)
-	if err = binary.Read(buf, binary.BigEndian, &t.Data); err != nil {
+func (t *PortTLV) TLVType() uint8 {
+	return TLV_PORT_ID
+}
+
+func (t *PortTLV) Len() (n uint16) {
+	return uint16(3 + len(t.Data))
+}
+
+func (t *PortTLV) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(t.Len()))
+	if err = putHeader(data, TLV_PORT_ID, len(data)-2); err != nil {
 		return
 	}
-	n += int(t.Length)
+	data[2] = t.Subtype
+	copy(data[3:], t.Data)
 	return
 }
 
+func (t *PortTLV) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return util.NewTruncatedError("PortTLV message", 4, len(data))
+	}
+	t.Subtype = data[2]
+	t.Data = make([]byte, len(data)-3)
+	copy(t.Data, data[3:])
+	return nil
+}
+
 type TTLTLV struct {
-	Type    uint8  //7 bits
-	Length  uint16 //9 bits
 	Seconds uint16
 }
 
-func (t *TTLTLV) Read(b []byte) (n int, err error) {
-	buf := new(bytes.Buffer)
-	var tni uint16 = 0
-	typeAndLen := (tni | uint16(t.Type)<<9) + (tni | uint16(t.Length))
-	binary.Write(buf, binary.BigEndian, typeAndLen)
-	binary.Write(buf, binary.BigEndian, t.Seconds)
-	n, err = buf.Read(b)
+func NewTTLTLV(seconds uint16) *TTLTLV {
+	return &TTLTLV{seconds}
+}
+
+func (t *TTLTLV) TLVType() uint8 {
+	return TLV_TTL
+}
+
+func (t *TTLTLV) Len() (n uint16) {
+	return 4
+}
+
+func (t *TTLTLV) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(t.Len()))
+	putHeader(data, TLV_TTL, 2)
+	binary.BigEndian.PutUint16(data[2:], t.Seconds)
 	return
 }
 
-func (t *TTLTLV) Write(b []byte) (n int, err error) {
-	buf := bytes.NewBuffer(b)
-	var typeAndLen uint16 = 0
-	if err = binary.Read(buf, binary.BigEndian, &typeAndLen); err != nil {
-		return
+func (t *TTLTLV) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return util.NewTruncatedError("TTLTLV message", 4, len(data))
 	}
-	n += 2
-	t.Type = uint8(typeAndLen >> 9)
-	t.Length = uint16(uint16(0x01ff) & typeAndLen)
-	if err = binary.Read(buf, binary.BigEndian, &t.Seconds); err != nil {
-		return
-	}
-	n += 2
-	return
+	t.Seconds = binary.BigEndian.Uint16(data[2:])
+	return nil
 }
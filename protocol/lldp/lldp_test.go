@@ -0,0 +1,88 @@
+package lldp
+
+import (
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+)
+
+var lldpdu = "   02 07 04 00 11 22 33 44 55 " + // Chassis ID
+	"04 03 07 00 05 " + // Port ID
+	"06 02 00 78 " + // TTL
+	"0a 03 73 77 31 " + // System Name
+	"0e 04 00 14 00 10 " + // Capabilities
+	"10 0c 05 01 0a 00 00 01 02 00 00 00 02 00 " + // Management Address
+	"fe 06 00 80 c2 01 00 64 " + // Port VLAN ID
+	"00 00 " // End
+
+func TestLLDPMarshalBinary(t *testing.T) {
+	b := strings.Replace(lldpdu, " ", "", -1)
+
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	d := New(NewChassisMAC(mac), NewPortNumber(5), 120)
+	d.AddTLV(NewSystemName("sw1"))
+	d.AddTLV(NewCapabilitiesTLV(CAP_BRIDGE|CAP_ROUTER, CAP_ROUTER))
+	d.AddTLV(NewManagementAddress(net.ParseIP("10.0.0.1"), 2))
+	d.AddTLV(NewOrgTLV(OUI_8021, 1, []byte{0, 100}))
+
+	data, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := hex.EncodeToString(data)
+	if (len(b) != len(s)) || (b != s) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", s)
+		t.Errorf("Received length of %d, expected %d", len(s), len(b))
+	}
+	if int(d.Len()) != len(data) {
+		t.Errorf("Got length of %d, expected %d.", d.Len(), len(data))
+	}
+}
+
+func TestLLDPUnmarshalBinary(t *testing.T) {
+	b := strings.Replace(lldpdu, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+	// Ethernet padding
+	data = append(data, make([]byte, 8)...)
+
+	d := new(LLDP)
+	if err := d.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if d.Chassis.Subtype != CH_MAC_ADDR || net.HardwareAddr(d.Chassis.Data).String() != "00:11:22:33:44:55" {
+		t.Errorf("Got chassis %v.", d.Chassis)
+	}
+	if d.Port.Subtype != PT_LOCAL_ASSGN || d.TTL.Seconds != 120 {
+		t.Errorf("Got port %v and TTL %d.", d.Port, d.TTL.Seconds)
+	}
+	if len(d.TLVs) != 4 {
+		t.Fatalf("Got %d optional TLVs, expected 4.", len(d.TLVs))
+	}
+	if name, ok := d.Text(TLV_SYSTEM_NAME); !ok || name != "sw1" {
+		t.Errorf("Got system name %q, expected sw1.", name)
+	}
+	if c, ok := d.TLV(TLV_SYSTEM_CAPS).(*CapabilitiesTLV); !ok || c.Enabled != CAP_ROUTER {
+		t.Errorf("Got capabilities %v.", d.TLV(TLV_SYSTEM_CAPS))
+	}
+	m, ok := d.TLV(TLV_MGMT_ADDR).(*ManagementAddressTLV)
+	if !ok || !m.IP().Equal(net.ParseIP("10.0.0.1")) || m.IfNumber != 2 {
+		t.Errorf("Got management address %v.", d.TLV(TLV_MGMT_ADDR))
+	}
+	if o, ok := d.TLV(TLV_ORG_SPECIFIC).(*OrgTLV); !ok || o.OUI != OUI_8021 || o.Subtype != 1 {
+		t.Errorf("Got organizationally specific TLV %v.", d.TLV(TLV_ORG_SPECIFIC))
+	}
+}
+
+func TestLLDPMissingTTL(t *testing.T) {
+	b := "   02 07 04 00 11 22 33 44 55 " + // Chassis ID
+		"04 03 07 00 05 " + // Port ID
+		"00 00 " // End
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	if err := new(LLDP).UnmarshalBinary(data); err == nil {
+		t.Error("Expected an error unmarshaling an LLDPDU without a TTL.")
+	}
+}
@@ -0,0 +1,297 @@
+package lldp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// A Port Description, System Name or System Description TLV.
+type TextTLV struct {
+	Type uint8
+	Text string
+}
+
+func NewPortDescription(s string) *TextTLV {
+	return &TextTLV{TLV_PORT_DESC, s}
+}
+
+func NewSystemName(s string) *TextTLV {
+	return &TextTLV{TLV_SYSTEM_NAME, s}
+}
+
+func NewSystemDescription(s string) *TextTLV {
+	return &TextTLV{TLV_SYSTEM_DESC, s}
+}
+
+func (t *TextTLV) TLVType() uint8 {
+	return t.Type
+}
+
+func (t *TextTLV) Len() (n uint16) {
+	return uint16(2 + len(t.Text))
+}
+
+func (t *TextTLV) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(t.Len()))
+	if err = putHeader(data, t.Type, len(t.Text)); err != nil {
+		return
+	}
+	copy(data[2:], t.Text)
+	return
+}
+
+func (t *TextTLV) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return util.NewTruncatedError("TextTLV message", 2, len(data))
+	}
+	t.Type = data[0] >> 1
+	t.Text = string(data[2:])
+	return nil
+}
+
+// System capabilities
+const (
+	CAP_OTHER     = 1 << 0
+	CAP_REPEATER  = 1 << 1
+	CAP_BRIDGE    = 1 << 2
+	CAP_WLAN_AP   = 1 << 3
+	CAP_ROUTER    = 1 << 4
+	CAP_TELEPHONE = 1 << 5
+	CAP_DOCSIS    = 1 << 6
+	CAP_STATION   = 1 << 7
+)
+
+// The capabilities a system has and the ones it has enabled.
+type CapabilitiesTLV struct {
+	System  uint16
+	Enabled uint16
+}
+
+func NewCapabilitiesTLV(system, enabled uint16) *CapabilitiesTLV {
+	return &CapabilitiesTLV{system, enabled}
+}
+
+func (t *CapabilitiesTLV) TLVType() uint8 {
+	return TLV_SYSTEM_CAPS
+}
+
+func (t *CapabilitiesTLV) Len() (n uint16) {
+	return 6
+}
+
+func (t *CapabilitiesTLV) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(t.Len()))
+	putHeader(data, TLV_SYSTEM_CAPS, 4)
+	binary.BigEndian.PutUint16(data[2:], t.System)
+	binary.BigEndian.PutUint16(data[4:], t.Enabled)
+	return
+}
+
+func (t *CapabilitiesTLV) UnmarshalBinary(data []byte) error {
+	if len(data) < int(t.Len()) {
+		return util.NewTruncatedError("CapabilitiesTLV message", int(t.Len()), len(data))
+	}
+	t.System = binary.BigEndian.Uint16(data[2:])
+	t.Enabled = binary.BigEndian.Uint16(data[4:])
+	return nil
+}
+
+// Management address subtypes, from the IANA address family numbers.
+const (
+	ADDR_IPv4 = 1
+	ADDR_IPv6 = 2
+	ADDR_MAC  = 6
+)
+
+// Interface numbering subtypes
+const (
+	_ = iota
+	IF_UNKNOWN
+	IF_INDEX
+	IF_PORT_NUMBER
+)
+
+// An address the system can be managed at.
+type ManagementAddressTLV struct {
+	AddrSubtype uint8
+	Address     []byte
+	IfSubtype   uint8
+	IfNumber    uint32
+	OID         []byte
+}
+
+// Returns a Management Address TLV for ip, reached through the
+// interface with index ifIndex.
+func NewManagementAddress(ip net.IP, ifIndex uint32) *ManagementAddressTLV {
+	t := new(ManagementAddressTLV)
+	if ip4 := ip.To4(); ip4 != nil {
+		t.AddrSubtype = ADDR_IPv4
+		t.Address = ip4
+	} else {
+		t.AddrSubtype = ADDR_IPv6
+		t.Address = ip.To16()
+	}
+	t.IfSubtype = IF_INDEX
+	t.IfNumber = ifIndex
+	return t
+}
+
+// Returns the address as an IP, or nil if it is not one.
+func (t *ManagementAddressTLV) IP() net.IP {
+	if t.AddrSubtype == ADDR_IPv4 || t.AddrSubtype == ADDR_IPv6 {
+		return net.IP(t.Address)
+	}
+	return nil
+}
+
+func (t *ManagementAddressTLV) TLVType() uint8 {
+	return TLV_MGMT_ADDR
+}
+
+func (t *ManagementAddressTLV) Len() (n uint16) {
+	return uint16(2 + 1 + 1 + len(t.Address) + 1 + 4 + 1 + len(t.OID))
+}
+
+func (t *ManagementAddressTLV) MarshalBinary() (data []byte, err error) {
+	if len(t.Address) < 1 || len(t.Address) > 31 {
+		return nil, errors.New("LLDP management address must be 1 to 31 bytes.")
+	}
+	if len(t.OID) > 128 {
+		return nil, errors.New("LLDP management OID is longer than 128 bytes.")
+	}
+	data = make([]byte, int(t.Len()))
+	putHeader(data, TLV_MGMT_ADDR, len(data)-2)
+	n := 2
+	data[n] = uint8(1 + len(t.Address))
+	n += 1
+	data[n] = t.AddrSubtype
+	n += 1
+	copy(data[n:], t.Address)
+	n += len(t.Address)
+	data[n] = t.IfSubtype
+	n += 1
+	binary.BigEndian.PutUint32(data[n:], t.IfNumber)
+	n += 4
+	data[n] = uint8(len(t.OID))
+	n += 1
+	copy(data[n:], t.OID)
+	return
+}
+
+func (t *ManagementAddressTLV) UnmarshalBinary(data []byte) error {
+	if len(data) < 3 {
+		return util.NewTruncatedError("ManagementAddressTLV message", 3, len(data))
+	}
+	n := 2
+	addrLen := int(data[n])
+	n += 1
+	if addrLen < 1 {
+		return util.NewMalformedError("ManagementAddressTLV message", "address length is 0")
+	}
+	if len(data) < n+addrLen+6 {
+		return util.NewTruncatedError("ManagementAddressTLV message", n+addrLen+6, len(data))
+	}
+	t.AddrSubtype = data[n]
+	n += 1
+	t.Address = make([]byte, addrLen-1)
+	copy(t.Address, data[n:])
+	n += len(t.Address)
+	t.IfSubtype = data[n]
+	n += 1
+	t.IfNumber = binary.BigEndian.Uint32(data[n:])
+	n += 4
+	oidLen := int(data[n])
+	n += 1
+	if len(data) < n+oidLen {
+		return util.NewTruncatedError("ManagementAddressTLV message", n+oidLen, len(data))
+	}
+	t.OID = make([]byte, oidLen)
+	copy(t.OID, data[n:])
+	return nil
+}
+
+// Organizationally unique identifiers
+const (
+	OUI_8021 = 0x0080c2
+	OUI_8023 = 0x00120f
+)
+
+// An organizationally specific TLV. OUI holds the 24-bit
+// organizationally unique identifier.
+type OrgTLV struct {
+	OUI     uint32
+	Subtype uint8
+	Info    []byte
+}
+
+func NewOrgTLV(oui uint32, subtype uint8, info []byte) *OrgTLV {
+	return &OrgTLV{oui, subtype, info}
+}
+
+func (t *OrgTLV) TLVType() uint8 {
+	return TLV_ORG_SPECIFIC
+}
+
+func (t *OrgTLV) Len() (n uint16) {
+	return uint16(6 + len(t.Info))
+}
+
+func (t *OrgTLV) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(t.Len()))
+	if err = putHeader(data, TLV_ORG_SPECIFIC, len(data)-2); err != nil {
+		return
+	}
+	data[2] = uint8(t.OUI >> 16)
+	data[3] = uint8(t.OUI >> 8)
+	data[4] = uint8(t.OUI)
+	data[5] = t.Subtype
+	copy(data[6:], t.Info)
+	return
+}
+
+func (t *OrgTLV) UnmarshalBinary(data []byte) error {
+	if len(data) < 6 {
+		return util.NewTruncatedError("OrgTLV message", 6, len(data))
+	}
+	t.OUI = uint32(data[2])<<16 | uint32(data[3])<<8 | uint32(data[4])
+	t.Subtype = data[5]
+	t.Info = make([]byte, len(data)-6)
+	copy(t.Info, data[6:])
+	return nil
+}
+
+// A TLV of a type this package does not know.
+type UnknownTLV struct {
+	Type uint8
+	Data []byte
+}
+
+func (t *UnknownTLV) TLVType() uint8 {
+	return t.Type
+}
+
+func (t *UnknownTLV) Len() (n uint16) {
+	return uint16(2 + len(t.Data))
+}
+
+func (t *UnknownTLV) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(t.Len()))
+	if err = putHeader(data, t.Type, len(t.Data)); err != nil {
+		return
+	}
+	copy(data[2:], t.Data)
+	return
+}
+
+func (t *UnknownTLV) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return util.NewTruncatedError("UnknownTLV message", 2, len(data))
+	}
+	t.Type = data[0] >> 1
+	t.Data = make([]byte, len(data)-2)
+	copy(t.Data, data[2:])
+	return nil
+}
@@ -0,0 +1,133 @@
+// Package mpls implements MPLS label stacks, RFC 3032.
+package mpls
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/jonstout/ogo/protocol/ipv4"
+	"github.com/jonstout/ogo/protocol/ipv6"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Reserved labels
+const (
+	LABEL_IPv4_NULL     = 0
+	LABEL_ROUTER_ALERT  = 1
+	LABEL_IPv6_NULL     = 2
+	LABEL_IMPLICIT_NULL = 3
+
+	LABEL_MAX = 0xfffff
+)
+
+// One entry of a label stack.
+type Label struct {
+	Label uint32 //20-bits
+	TC    uint8  //3-bits
+	TTL   uint8
+}
+
+func NewLabel(label uint32, ttl uint8) Label {
+	return Label{label & LABEL_MAX, 0, ttl}
+}
+
+// A label stack and the packet it carries. The bottom-of-stack bit
+// is set on the last label when marshaling, and ends the stack when
+// unmarshaling.
+type MPLS struct {
+	Labels []Label // Top of the stack first.
+	Data   util.Message
+}
+
+func New() *MPLS {
+	m := new(MPLS)
+	m.Labels = make([]Label, 0)
+	m.Data = new(util.Buffer)
+	return m
+}
+
+// Adds l to the top of the stack.
+func (m *MPLS) Push(l Label) {
+	m.Labels = append([]Label{l}, m.Labels...)
+}
+
+// Removes and returns the top of the stack.
+func (m *MPLS) Pop() (l Label, ok bool) {
+	if len(m.Labels) == 0 {
+		return
+	}
+	l = m.Labels[0]
+	m.Labels = m.Labels[1:]
+	return l, true
+}
+
+func (m *MPLS) Len() (n uint16) {
+	n = uint16(4 * len(m.Labels))
+	if m.Data != nil {
+		n += m.Data.Len()
+	}
+	return
+}
+
+func (m *MPLS) MarshalBinary() (data []byte, err error) {
+	if len(m.Labels) == 0 {
+		return nil, errors.New("MPLS label stack is empty.")
+	}
+	data = make([]byte, 4*len(m.Labels), int(m.Len()))
+	n := 0
+	for i, l := range m.Labels {
+		entry := (l.Label&LABEL_MAX)<<12 | uint32(l.TC&0x07)<<9 | uint32(l.TTL)
+		if i == len(m.Labels)-1 {
+			entry |= 1 << 8
+		}
+		binary.BigEndian.PutUint32(data[n:], entry)
+		n += 4
+	}
+	if m.Data != nil {
+		var b []byte
+		if b, err = m.Data.MarshalBinary(); err != nil {
+			return
+		}
+		data = append(data, b...)
+	}
+	return
+}
+
+func (m *MPLS) UnmarshalBinary(data []byte) error {
+	m.Labels = make([]Label, 0)
+	n := 0
+	for {
+		if len(data) < n+4 {
+			m.Data = nil
+			return util.NewTruncatedError("MPLS message", n+4, len(data))
+		}
+		entry := binary.BigEndian.Uint32(data[n:])
+		n += 4
+		m.Labels = append(m.Labels, Label{entry >> 12, uint8(entry>>9) & 0x07, uint8(entry)})
+		if entry&(1<<8) != 0 {
+			break
+		}
+	}
+
+	// Nothing in the header says what the stack carries. Explicit
+	// null labels name the protocol, otherwise it is guessed from
+	// the IP version.
+	bottom := m.Labels[len(m.Labels)-1].Label
+	switch {
+	case bottom == LABEL_IPv4_NULL:
+		m.Data = ipv4.New()
+	case bottom == LABEL_IPv6_NULL:
+		m.Data = ipv6.New()
+	case n < len(data) && data[n]>>4 == 4:
+		m.Data = ipv4.New()
+	case n < len(data) && data[n]>>4 == 6:
+		m.Data = ipv6.New()
+	default:
+		m.Data = new(util.Buffer)
+	}
+	return m.Data.UnmarshalBinary(data[n:])
+}
+
+func (m *MPLS) Payload() util.Message {
+	return m.Data
+}
@@ -0,0 +1,64 @@
+package mpls
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/ipv4"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+func TestMPLSMarshalBinary(t *testing.T) {
+	b := "   00 3e 80 40 " + // Label 1000, TTL 64
+		"07 d0 a3 3f " + // Label 32010, TC 1, TTL 63, bottom of stack
+		"01 02 " // Data
+	b = strings.Replace(b, " ", "", -1)
+
+	m := New()
+	m.Push(Label{32010, 1, 63})
+	m.Push(NewLabel(1000, 64))
+	m.Data = util.NewBuffer([]byte{1, 2})
+
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := hex.EncodeToString(data)
+	if (len(b) != len(d)) || (b != d) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", d)
+		t.Errorf("Received length of %d, expected %d", len(d), len(b))
+	}
+}
+
+func TestMPLSUnmarshalBinary(t *testing.T) {
+	b := "   00 3e 80 40 " + // Label 1000, TTL 64
+		"07 d0 a3 3f " + // Label 32010, TC 1, TTL 63, bottom of stack
+		"45 00 00 14 00 00 00 00 40 11 00 00 " + // IPv4
+		"0a 00 00 01 0a 00 00 02 "
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	m := New()
+	if err := m.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Labels) != 2 {
+		t.Fatalf("Got %d labels, expected 2.", len(m.Labels))
+	}
+	if l := m.Labels[1]; l != (Label{32010, 1, 63}) {
+		t.Errorf("Got bottom label %+v.", l)
+	}
+	if _, ok := m.Data.(*ipv4.IPv4); !ok {
+		t.Errorf("Got payload %T, expected *ipv4.IPv4.", m.Data)
+	}
+	if l, ok := m.Pop(); !ok || l.Label != 1000 || len(m.Labels) != 1 {
+		t.Errorf("Popped %+v, expected label 1000.", l)
+	}
+
+	// A stack without a bottom must not be read past the data.
+	if err := New().UnmarshalBinary(data[:4]); err == nil {
+		t.Error("Expected an error unmarshaling a stack without a bottom.")
+	}
+}
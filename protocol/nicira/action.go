@@ -0,0 +1,564 @@
+package nicira
+
+import (
+	"encoding/binary"
+	"strconv"
+
+	"github.com/jonstout/ogo/protocol/ofp10"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Nicira vendor action subtypes.
+const (
+	NXAST_RESUBMIT       = 1
+	NXAST_SET_TUNNEL     = 2
+	NXAST_REG_MOVE       = 6
+	NXAST_REG_LOAD       = 7
+	NXAST_SET_TUNNEL64   = 9
+	NXAST_RESUBMIT_TABLE = 14
+	NXAST_LEARN          = 16
+)
+
+// nx_action_header: the header shared by all Nicira vendor
+// actions.
+type ActionHeader struct {
+	ofp10.ActionHeader
+	Vendor  uint32
+	Subtype uint16
+}
+
+func newActionHeader(subtype, length uint16) ActionHeader {
+	a := ActionHeader{}
+	a.Type = ofp10.ActionType_Vendor
+	a.Length = length
+	a.Vendor = NX_VENDOR_ID
+	a.Subtype = subtype
+	return a
+}
+
+func (a *ActionHeader) Len() (n uint16) {
+	return a.ActionHeader.Len() + 6
+}
+
+func (a *ActionHeader) MarshalBinary() (data []byte, err error) {
+	data, err = a.ActionHeader.MarshalBinary()
+
+	b := make([]byte, 6)
+	binary.BigEndian.PutUint32(b[:4], a.Vendor)
+	binary.BigEndian.PutUint16(b[4:], a.Subtype)
+	data = append(data, b...)
+	return
+}
+
+func (a *ActionHeader) UnmarshalBinary(data []byte) error {
+	if len(data) < 10 {
+		return util.NewTruncatedError("Nicira ActionHeader", 10, len(data))
+	}
+	err := a.ActionHeader.UnmarshalBinary(data[:4])
+	a.Vendor = binary.BigEndian.Uint32(data[4:8])
+	a.Subtype = binary.BigEndian.Uint16(data[8:10])
+	if int(a.Length) > len(data) {
+		return util.NewMalformedError("Nicira action", "length "+strconv.Itoa(int(a.Length))+" does not fit "+strconv.Itoa(len(data))+" bytes")
+	}
+	return err
+}
+
+// Searches the flow table again, using a flow that is slightly
+// modified from the original lookup: the in_port is changed to
+// InPort. With NXAST_RESUBMIT_TABLE the lookup happens in Table.
+type ActionResubmit struct {
+	ActionHeader
+	InPort uint16
+	Table  uint8
+	pad    []uint8 // Size 3
+}
+
+// Returns an action that resubmits the packet as if it arrived
+// on inPort.
+func NewActionResubmit(inPort uint16) *ActionResubmit {
+	a := new(ActionResubmit)
+	a.ActionHeader = newActionHeader(NXAST_RESUBMIT, 16)
+	a.InPort = inPort
+	a.pad = make([]byte, 3)
+	return a
+}
+
+// Returns an action that resubmits the packet to table as if it
+// arrived on inPort.
+func NewActionResubmitTable(inPort uint16, table uint8) *ActionResubmit {
+	a := NewActionResubmit(inPort)
+	a.Subtype = NXAST_RESUBMIT_TABLE
+	a.Table = table
+	return a
+}
+
+func (a *ActionResubmit) Len() (n uint16) {
+	return 16
+}
+
+func (a *ActionResubmit) MarshalBinary() (data []byte, err error) {
+	data, err = a.ActionHeader.MarshalBinary()
+
+	b := make([]byte, 6)
+	binary.BigEndian.PutUint16(b[:2], a.InPort)
+	b[2] = a.Table
+	copy(b[3:], a.pad)
+	data = append(data, b...)
+	return
+}
+
+func (a *ActionResubmit) UnmarshalBinary(data []byte) error {
+	if len(data) < int(a.Len()) {
+		return util.NewTruncatedError("ActionResubmit", int(a.Len()), len(data))
+	}
+	err := a.ActionHeader.UnmarshalBinary(data)
+	a.InPort = binary.BigEndian.Uint16(data[10:12])
+	a.Table = data[12]
+	a.pad = make([]byte, 3)
+	copy(a.pad, data[13:16])
+	return err
+}
+
+// Sets the 32-bit tunnel id of the packet.
+type ActionSetTunnel struct {
+	ActionHeader
+	pad   []uint8 // Size 2
+	TunId uint32
+}
+
+func NewActionSetTunnel(id uint32) *ActionSetTunnel {
+	a := new(ActionSetTunnel)
+	a.ActionHeader = newActionHeader(NXAST_SET_TUNNEL, 16)
+	a.pad = make([]byte, 2)
+	a.TunId = id
+	return a
+}
+
+func (a *ActionSetTunnel) Len() (n uint16) {
+	return 16
+}
+
+func (a *ActionSetTunnel) MarshalBinary() (data []byte, err error) {
+	data, err = a.ActionHeader.MarshalBinary()
+
+	b := make([]byte, 6)
+	copy(b[:2], a.pad)
+	binary.BigEndian.PutUint32(b[2:], a.TunId)
+	data = append(data, b...)
+	return
+}
+
+func (a *ActionSetTunnel) UnmarshalBinary(data []byte) error {
+	if len(data) < int(a.Len()) {
+		return util.NewTruncatedError("ActionSetTunnel", int(a.Len()), len(data))
+	}
+	err := a.ActionHeader.UnmarshalBinary(data)
+	a.pad = make([]byte, 2)
+	copy(a.pad, data[10:12])
+	a.TunId = binary.BigEndian.Uint32(data[12:16])
+	return err
+}
+
+// Sets the 64-bit tunnel id of the packet.
+type ActionSetTunnel64 struct {
+	ActionHeader
+	pad   []uint8 // Size 6
+	TunId uint64
+}
+
+func NewActionSetTunnel64(id uint64) *ActionSetTunnel64 {
+	a := new(ActionSetTunnel64)
+	a.ActionHeader = newActionHeader(NXAST_SET_TUNNEL64, 24)
+	a.pad = make([]byte, 6)
+	a.TunId = id
+	return a
+}
+
+func (a *ActionSetTunnel64) Len() (n uint16) {
+	return 24
+}
+
+func (a *ActionSetTunnel64) MarshalBinary() (data []byte, err error) {
+	data, err = a.ActionHeader.MarshalBinary()
+
+	b := make([]byte, 14)
+	copy(b[:6], a.pad)
+	binary.BigEndian.PutUint64(b[6:], a.TunId)
+	data = append(data, b...)
+	return
+}
+
+func (a *ActionSetTunnel64) UnmarshalBinary(data []byte) error {
+	if len(data) < int(a.Len()) {
+		return util.NewTruncatedError("ActionSetTunnel64", int(a.Len()), len(data))
+	}
+	err := a.ActionHeader.UnmarshalBinary(data)
+	a.pad = make([]byte, 6)
+	copy(a.pad, data[10:16])
+	a.TunId = binary.BigEndian.Uint64(data[16:24])
+	return err
+}
+
+// Copies NBits bits from field Src starting at bit SrcOfs to field
+// Dst starting at bit DstOfs. Src and Dst are nxm_headers.
+type ActionRegMove struct {
+	ActionHeader
+	NBits  uint16
+	SrcOfs uint16
+	DstOfs uint16
+	Src    uint32
+	Dst    uint32
+}
+
+func NewActionRegMove(src, dst uint32, srcOfs, dstOfs, nBits uint16) *ActionRegMove {
+	a := new(ActionRegMove)
+	a.ActionHeader = newActionHeader(NXAST_REG_MOVE, 24)
+	a.NBits = nBits
+	a.SrcOfs = srcOfs
+	a.DstOfs = dstOfs
+	a.Src = src
+	a.Dst = dst
+	return a
+}
+
+func (a *ActionRegMove) Len() (n uint16) {
+	return 24
+}
+
+func (a *ActionRegMove) MarshalBinary() (data []byte, err error) {
+	data, err = a.ActionHeader.MarshalBinary()
+
+	b := make([]byte, 14)
+	n := 0
+	binary.BigEndian.PutUint16(b[n:], a.NBits)
+	n += 2
+	binary.BigEndian.PutUint16(b[n:], a.SrcOfs)
+	n += 2
+	binary.BigEndian.PutUint16(b[n:], a.DstOfs)
+	n += 2
+	binary.BigEndian.PutUint32(b[n:], a.Src)
+	n += 4
+	binary.BigEndian.PutUint32(b[n:], a.Dst)
+	n += 4
+	data = append(data, b...)
+	return
+}
+
+func (a *ActionRegMove) UnmarshalBinary(data []byte) error {
+	if len(data) < int(a.Len()) {
+		return util.NewTruncatedError("ActionRegMove", int(a.Len()), len(data))
+	}
+	err := a.ActionHeader.UnmarshalBinary(data)
+	n := 10
+	a.NBits = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	a.SrcOfs = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	a.DstOfs = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	a.Src = binary.BigEndian.Uint32(data[n:])
+	n += 4
+	a.Dst = binary.BigEndian.Uint32(data[n:])
+	n += 4
+	return err
+}
+
+// Copies Value into bits [ofs, ofs+nbits) of field Dst. OfsNBits
+// encodes ofs in its upper 10 bits and nbits-1 in its lower 6.
+type ActionRegLoad struct {
+	ActionHeader
+	OfsNBits uint16
+	Dst      uint32
+	Value    uint64
+}
+
+func NewActionRegLoad(dst uint32, ofs, nBits uint16, value uint64) *ActionRegLoad {
+	a := new(ActionRegLoad)
+	a.ActionHeader = newActionHeader(NXAST_REG_LOAD, 24)
+	if nBits > 0 {
+		a.OfsNBits = ofs<<6 | (nBits - 1)
+	}
+	a.Dst = dst
+	a.Value = value
+	return a
+}
+
+// Returns the first bit of Dst that is loaded.
+func (a *ActionRegLoad) Ofs() uint16 {
+	return a.OfsNBits >> 6
+}
+
+// Returns the number of bits of Dst that are loaded.
+func (a *ActionRegLoad) NBits() uint16 {
+	return a.OfsNBits&0x3f + 1
+}
+
+func (a *ActionRegLoad) Len() (n uint16) {
+	return 24
+}
+
+func (a *ActionRegLoad) MarshalBinary() (data []byte, err error) {
+	data, err = a.ActionHeader.MarshalBinary()
+
+	b := make([]byte, 14)
+	binary.BigEndian.PutUint16(b[:2], a.OfsNBits)
+	binary.BigEndian.PutUint32(b[2:6], a.Dst)
+	binary.BigEndian.PutUint64(b[6:], a.Value)
+	data = append(data, b...)
+	return
+}
+
+func (a *ActionRegLoad) UnmarshalBinary(data []byte) error {
+	if len(data) < int(a.Len()) {
+		return util.NewTruncatedError("ActionRegLoad", int(a.Len()), len(data))
+	}
+	err := a.ActionHeader.UnmarshalBinary(data)
+	a.OfsNBits = binary.BigEndian.Uint16(data[10:12])
+	a.Dst = binary.BigEndian.Uint32(data[12:16])
+	a.Value = binary.BigEndian.Uint64(data[16:24])
+	return err
+}
+
+// Flow mod spec header bits for NXAST_LEARN.
+const (
+	NX_LEARN_N_BITS_MASK = 0x3ff
+
+	NX_LEARN_SRC_FIELD     = 0 << 13
+	NX_LEARN_SRC_IMMEDIATE = 1 << 13
+	NX_LEARN_SRC_MASK      = 1 << 13
+
+	NX_LEARN_DST_MATCH  = 0 << 11
+	NX_LEARN_DST_LOAD   = 1 << 11
+	NX_LEARN_DST_OUTPUT = 2 << 11
+	NX_LEARN_DST_MASK   = 3 << 11
+)
+
+// Flags for NXAST_LEARN.
+const (
+	NX_LEARN_F_SEND_FLOW_REM = 1 << 0
+)
+
+// A single flow_mod_spec of an NXAST_LEARN action. The source is
+// either NBits of field SrcField starting at SrcOfs or the
+// immediate value SrcValue. The destination is NBits of field
+// DstField starting at DstOfs, or nothing for an output spec.
+type LearnSpec struct {
+	Header   uint16
+	SrcField uint32
+	SrcOfs   uint16
+	SrcValue []byte
+	DstField uint32
+	DstOfs   uint16
+}
+
+// Returns a spec that matches NBits of dst in the learned flow
+// against the same bits of src in the packet being processed.
+func NewLearnSpecMatch(src uint32, srcOfs uint16, dst uint32, dstOfs uint16, nBits uint16) *LearnSpec {
+	l := new(LearnSpec)
+	l.Header = NX_LEARN_SRC_FIELD | NX_LEARN_DST_MATCH | nBits&NX_LEARN_N_BITS_MASK
+	l.SrcField = src
+	l.SrcOfs = srcOfs
+	l.DstField = dst
+	l.DstOfs = dstOfs
+	return l
+}
+
+// Returns a spec that outputs the learned flow's packets to the
+// port found in NBits of src.
+func NewLearnSpecOutput(src uint32, srcOfs uint16, nBits uint16) *LearnSpec {
+	l := new(LearnSpec)
+	l.Header = NX_LEARN_SRC_FIELD | NX_LEARN_DST_OUTPUT | nBits&NX_LEARN_N_BITS_MASK
+	l.SrcField = src
+	l.SrcOfs = srcOfs
+	return l
+}
+
+func (l *LearnSpec) NBits() uint16 {
+	return l.Header & NX_LEARN_N_BITS_MASK
+}
+
+func (l *LearnSpec) Len() (n uint16) {
+	n = 2
+	if l.Header&NX_LEARN_SRC_MASK == NX_LEARN_SRC_IMMEDIATE {
+		n += (l.NBits() + 15) / 16 * 2
+	} else {
+		n += 6
+	}
+	if l.Header&NX_LEARN_DST_MASK != NX_LEARN_DST_OUTPUT {
+		n += 6
+	}
+	return
+}
+
+func (l *LearnSpec) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(l.Len()))
+	binary.BigEndian.PutUint16(data[:2], l.Header)
+	n := 2
+	if l.Header&NX_LEARN_SRC_MASK == NX_LEARN_SRC_IMMEDIATE {
+		size := int(l.NBits()+15) / 16 * 2
+		// Immediate values are right aligned.
+		if len(l.SrcValue) > size {
+			copy(data[n:], l.SrcValue[len(l.SrcValue)-size:])
+		} else {
+			copy(data[n+size-len(l.SrcValue):], l.SrcValue)
+		}
+		n += size
+	} else {
+		binary.BigEndian.PutUint32(data[n:], l.SrcField)
+		n += 4
+		binary.BigEndian.PutUint16(data[n:], l.SrcOfs)
+		n += 2
+	}
+	if l.Header&NX_LEARN_DST_MASK != NX_LEARN_DST_OUTPUT {
+		binary.BigEndian.PutUint32(data[n:], l.DstField)
+		n += 4
+		binary.BigEndian.PutUint16(data[n:], l.DstOfs)
+		n += 2
+	}
+	return
+}
+
+func (l *LearnSpec) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return util.NewTruncatedError("LearnSpec header", 2, len(data))
+	}
+	l.Header = binary.BigEndian.Uint16(data[:2])
+	if len(data) < int(l.Len()) {
+		return util.NewTruncatedError("LearnSpec", int(l.Len()), len(data))
+	}
+	n := 2
+	if l.Header&NX_LEARN_SRC_MASK == NX_LEARN_SRC_IMMEDIATE {
+		size := int(l.NBits()+15) / 16 * 2
+		l.SrcValue = make([]byte, size)
+		copy(l.SrcValue, data[n:])
+		n += size
+	} else {
+		l.SrcField = binary.BigEndian.Uint32(data[n:])
+		n += 4
+		l.SrcOfs = binary.BigEndian.Uint16(data[n:])
+		n += 2
+	}
+	if l.Header&NX_LEARN_DST_MASK != NX_LEARN_DST_OUTPUT {
+		l.DstField = binary.BigEndian.Uint32(data[n:])
+		n += 4
+		l.DstOfs = binary.BigEndian.Uint16(data[n:])
+		n += 2
+	}
+	return nil
+}
+
+// Adds a flow to table TableId, built from Specs and the packet
+// being processed.
+type ActionLearn struct {
+	ActionHeader
+	IdleTimeout    uint16
+	HardTimeout    uint16
+	Priority       uint16
+	Cookie         uint64
+	Flags          uint16
+	TableId        uint8
+	pad            uint8
+	FinIdleTimeout uint16
+	FinHardTimeout uint16
+	Specs          []LearnSpec
+}
+
+func NewActionLearn() *ActionLearn {
+	a := new(ActionLearn)
+	a.ActionHeader = newActionHeader(NXAST_LEARN, 32)
+	a.Priority = 1000
+	a.Specs = make([]LearnSpec, 0)
+	return a
+}
+
+func (a *ActionLearn) AddSpec(l *LearnSpec) {
+	a.Specs = append(a.Specs, *l)
+}
+
+func (a *ActionLearn) Len() (n uint16) {
+	n = 32
+	for _, l := range a.Specs {
+		n += l.Len()
+	}
+	// Specs end at the first all zero spec header, which
+	// the padding provides.
+	n += uint16(pad8(int(n)))
+	return
+}
+
+func (a *ActionLearn) MarshalBinary() (data []byte, err error) {
+	a.Length = a.Len()
+	data, err = a.ActionHeader.MarshalBinary()
+
+	b := make([]byte, 22)
+	n := 0
+	binary.BigEndian.PutUint16(b[n:], a.IdleTimeout)
+	n += 2
+	binary.BigEndian.PutUint16(b[n:], a.HardTimeout)
+	n += 2
+	binary.BigEndian.PutUint16(b[n:], a.Priority)
+	n += 2
+	binary.BigEndian.PutUint64(b[n:], a.Cookie)
+	n += 8
+	binary.BigEndian.PutUint16(b[n:], a.Flags)
+	n += 2
+	b[n] = a.TableId
+	n += 1
+	b[n] = a.pad
+	n += 1
+	binary.BigEndian.PutUint16(b[n:], a.FinIdleTimeout)
+	n += 2
+	binary.BigEndian.PutUint16(b[n:], a.FinHardTimeout)
+	n += 2
+	data = append(data, b...)
+
+	for _, l := range a.Specs {
+		if b, err = l.MarshalBinary(); err != nil {
+			return
+		}
+		data = append(data, b...)
+	}
+	data = append(data, make([]byte, pad8(len(data)))...)
+	return
+}
+
+func (a *ActionLearn) UnmarshalBinary(data []byte) error {
+	if len(data) < 32 {
+		return util.NewTruncatedError("ActionLearn", 32, len(data))
+	}
+	err := a.ActionHeader.UnmarshalBinary(data)
+	if err != nil {
+		return err
+	}
+	n := 10
+	a.IdleTimeout = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	a.HardTimeout = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	a.Priority = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	a.Cookie = binary.BigEndian.Uint64(data[n:])
+	n += 8
+	a.Flags = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	a.TableId = data[n]
+	n += 1
+	a.pad = data[n]
+	n += 1
+	a.FinIdleTimeout = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	a.FinHardTimeout = binary.BigEndian.Uint16(data[n:])
+	n += 2
+
+	a.Specs = make([]LearnSpec, 0)
+	end := int(a.Length)
+	for n+2 <= end && binary.BigEndian.Uint16(data[n:]) != 0 {
+		l := new(LearnSpec)
+		if err = l.UnmarshalBinary(data[n:end]); err != nil {
+			return err
+		}
+		a.Specs = append(a.Specs, *l)
+		n += int(l.Len())
+	}
+	return nil
+}
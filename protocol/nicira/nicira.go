@@ -0,0 +1,441 @@
+// Package nicira implements the Nicira (NX) vendor extensions to
+// OpenFlow 1.0 used by Open vSwitch. Importing the package
+// registers its decoders with ofp10, so ofp10.Parse and
+// ofp10.DecodeAction return the types defined here.
+//
+// Struct documentation is taken from Open vSwitch's
+// include/openflow/nicira-ext.h.
+package nicira
+
+import (
+	"encoding/binary"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/ofp10"
+	"github.com/jonstout/ogo/protocol/ofpxx"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// The Nicira vendor id.
+const NX_VENDOR_ID = 0x00002320
+
+// Nicira vendor message subtypes.
+const (
+	NXT_ROLE_REQUEST         = 10
+	NXT_ROLE_REPLY           = 11
+	NXT_SET_FLOW_FORMAT      = 12
+	NXT_FLOW_MOD             = 13
+	NXT_FLOW_REMOVED         = 14
+	NXT_FLOW_MOD_TABLE_ID    = 15
+	NXT_SET_PACKET_IN_FORMAT = 16
+	NXT_PACKET_IN            = 17
+)
+
+// Flow formats for NXT_SET_FLOW_FORMAT.
+const (
+	NXFF_OPENFLOW10 = 0
+	NXFF_NXM        = 2
+)
+
+// Packet in formats for NXT_SET_PACKET_IN_FORMAT.
+const (
+	NXPIF_OPENFLOW10 = 0
+	NXPIF_NXM        = 1
+)
+
+func init() {
+	ofp10.RegisterVendorMessage(NX_VENDOR_ID, NXT_SET_FLOW_FORMAT,
+		func() util.Message { return NewSetFlowFormat(0) })
+	ofp10.RegisterVendorMessage(NX_VENDOR_ID, NXT_FLOW_MOD,
+		func() util.Message { return NewFlowMod() })
+	ofp10.RegisterVendorMessage(NX_VENDOR_ID, NXT_SET_PACKET_IN_FORMAT,
+		func() util.Message { return NewSetPacketInFormat(0) })
+	ofp10.RegisterVendorMessage(NX_VENDOR_ID, NXT_PACKET_IN,
+		func() util.Message { return NewPacketIn() })
+	// Other messages still decode to their Nicira header.
+	ofp10.RegisterVendorMessage(NX_VENDOR_ID, ofp10.VENDOR_SUBTYPE_ANY,
+		func() util.Message { return new(Header) })
+
+	ofp10.RegisterVendorAction(NX_VENDOR_ID, NXAST_RESUBMIT,
+		func() ofp10.Action { return NewActionResubmit(0) })
+	ofp10.RegisterVendorAction(NX_VENDOR_ID, NXAST_RESUBMIT_TABLE,
+		func() ofp10.Action { return NewActionResubmitTable(0, 0) })
+	ofp10.RegisterVendorAction(NX_VENDOR_ID, NXAST_SET_TUNNEL,
+		func() ofp10.Action { return NewActionSetTunnel(0) })
+	ofp10.RegisterVendorAction(NX_VENDOR_ID, NXAST_SET_TUNNEL64,
+		func() ofp10.Action { return NewActionSetTunnel64(0) })
+	ofp10.RegisterVendorAction(NX_VENDOR_ID, NXAST_REG_MOVE,
+		func() ofp10.Action { return NewActionRegMove(0, 0, 0, 0, 0) })
+	ofp10.RegisterVendorAction(NX_VENDOR_ID, NXAST_REG_LOAD,
+		func() ofp10.Action { return NewActionRegLoad(0, 0, 0, 0) })
+	ofp10.RegisterVendorAction(NX_VENDOR_ID, NXAST_LEARN,
+		func() ofp10.Action { return NewActionLearn() })
+}
+
+// nicira_header: the header shared by all Nicira vendor
+// messages.
+type Header struct {
+	ofpxx.Header
+	Vendor  uint32
+	Subtype uint32
+}
+
+func NewHeader(subtype uint32) *Header {
+	h := new(Header)
+	h.Header = ofpxx.NewOfp10Header()
+	h.Header.Type = ofp10.Type_Vendor
+	h.Vendor = NX_VENDOR_ID
+	h.Subtype = subtype
+	return h
+}
+
+func (h *Header) VendorId() uint32 {
+	return h.Vendor
+}
+
+func (h *Header) Len() (n uint16) {
+	return h.Header.Len() + 8
+}
+
+func (h *Header) MarshalBinary() (data []byte, err error) {
+	data, err = h.Header.MarshalBinary()
+
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b[:4], h.Vendor)
+	binary.BigEndian.PutUint32(b[4:], h.Subtype)
+	data = append(data, b...)
+	return
+}
+
+func (h *Header) UnmarshalBinary(data []byte) error {
+	if len(data) < 16 {
+		return util.NewTruncatedError("Nicira Header", 16, len(data))
+	}
+	err := h.Header.UnmarshalBinary(data)
+	h.Vendor = binary.BigEndian.Uint32(data[8:12])
+	h.Subtype = binary.BigEndian.Uint32(data[12:16])
+	return err
+}
+
+// Selects the flow format used by flow mods, flow removed
+// messages and flow stats.
+type SetFlowFormat struct {
+	Header
+	Format uint32
+}
+
+func NewSetFlowFormat(format uint32) *SetFlowFormat {
+	s := new(SetFlowFormat)
+	s.Header = *NewHeader(NXT_SET_FLOW_FORMAT)
+	s.Format = format
+	return s
+}
+
+func (s *SetFlowFormat) Len() (n uint16) {
+	return s.Header.Len() + 4
+}
+
+func (s *SetFlowFormat) MarshalBinary() (data []byte, err error) {
+	s.Header.Length = s.Len()
+	data, err = s.Header.MarshalBinary()
+
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, s.Format)
+	data = append(data, b...)
+	return
+}
+
+func (s *SetFlowFormat) UnmarshalBinary(data []byte) error {
+	if len(data) < int(s.Len()) {
+		return util.NewTruncatedError("SetFlowFormat message", int(s.Len()), len(data))
+	}
+	err := s.Header.UnmarshalBinary(data)
+	s.Format = binary.BigEndian.Uint32(data[16:])
+	return err
+}
+
+// Selects the format of packet in messages sent to the
+// controller.
+type SetPacketInFormat struct {
+	Header
+	Format uint32
+}
+
+func NewSetPacketInFormat(format uint32) *SetPacketInFormat {
+	s := new(SetPacketInFormat)
+	s.Header = *NewHeader(NXT_SET_PACKET_IN_FORMAT)
+	s.Format = format
+	return s
+}
+
+func (s *SetPacketInFormat) Len() (n uint16) {
+	return s.Header.Len() + 4
+}
+
+func (s *SetPacketInFormat) MarshalBinary() (data []byte, err error) {
+	s.Header.Length = s.Len()
+	data, err = s.Header.MarshalBinary()
+
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, s.Format)
+	data = append(data, b...)
+	return
+}
+
+func (s *SetPacketInFormat) UnmarshalBinary(data []byte) error {
+	if len(data) < int(s.Len()) {
+		return util.NewTruncatedError("SetPacketInFormat message", int(s.Len()), len(data))
+	}
+	err := s.Header.UnmarshalBinary(data)
+	s.Format = binary.BigEndian.Uint32(data[16:])
+	return err
+}
+
+// nx_flow_mod: a flow mod whose match is an nx_match. The
+// switch must be set to the NXFF_NXM flow format first.
+type FlowMod struct {
+	Header
+	Cookie      uint64
+	Command     uint16
+	IdleTimeout uint16
+	HardTimeout uint16
+	Priority    uint16
+	BufferId    uint32
+	OutPort     uint16
+	Flags       uint16
+	MatchLen    uint16
+	pad         []uint8 // Size 6
+	Match       Match
+	Actions     []ofp10.Action
+}
+
+func NewFlowMod() *FlowMod {
+	f := new(FlowMod)
+	f.Header = *NewHeader(NXT_FLOW_MOD)
+	f.Command = ofp10.FC_ADD
+	f.Priority = 1000
+	f.BufferId = 0xffffffff
+	f.OutPort = ofp10.P_NONE
+	f.pad = make([]byte, 6)
+	f.Match = *NewMatch()
+	f.Actions = make([]ofp10.Action, 0)
+	return f
+}
+
+func (f *FlowMod) AddAction(a ofp10.Action) {
+	f.Actions = append(f.Actions, a)
+}
+
+func (f *FlowMod) Len() (n uint16) {
+	n = f.Header.Len() + 32
+	m := int(f.Match.Len())
+	n += uint16(m + pad8(m))
+	for _, a := range f.Actions {
+		n += a.Len()
+	}
+	return
+}
+
+func (f *FlowMod) MarshalBinary() (data []byte, err error) {
+	f.Header.Length = f.Len()
+	f.MatchLen = f.Match.Len()
+	data, err = f.Header.MarshalBinary()
+
+	b := make([]byte, 32)
+	n := 0
+	binary.BigEndian.PutUint64(b[n:], f.Cookie)
+	n += 8
+	binary.BigEndian.PutUint16(b[n:], f.Command)
+	n += 2
+	binary.BigEndian.PutUint16(b[n:], f.IdleTimeout)
+	n += 2
+	binary.BigEndian.PutUint16(b[n:], f.HardTimeout)
+	n += 2
+	binary.BigEndian.PutUint16(b[n:], f.Priority)
+	n += 2
+	binary.BigEndian.PutUint32(b[n:], f.BufferId)
+	n += 4
+	binary.BigEndian.PutUint16(b[n:], f.OutPort)
+	n += 2
+	binary.BigEndian.PutUint16(b[n:], f.Flags)
+	n += 2
+	binary.BigEndian.PutUint16(b[n:], f.MatchLen)
+	n += 2
+	copy(b[n:], f.pad)
+	data = append(data, b...)
+
+	if b, err = f.Match.MarshalBinary(); err != nil {
+		return
+	}
+	data = append(data, b...)
+	data = append(data, make([]byte, pad8(len(b)))...)
+
+	for _, a := range f.Actions {
+		if b, err = a.MarshalBinary(); err != nil {
+			return
+		}
+		data = append(data, b...)
+	}
+	return
+}
+
+func (f *FlowMod) UnmarshalBinary(data []byte) error {
+	if len(data) < 48 {
+		return util.NewTruncatedError("Nicira FlowMod message", 48, len(data))
+	}
+	err := f.Header.UnmarshalBinary(data)
+	n := int(f.Header.Len())
+	f.Cookie = binary.BigEndian.Uint64(data[n:])
+	n += 8
+	f.Command = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	f.IdleTimeout = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	f.HardTimeout = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	f.Priority = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	f.BufferId = binary.BigEndian.Uint32(data[n:])
+	n += 4
+	f.OutPort = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	f.Flags = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	f.MatchLen = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	f.pad = make([]byte, 6)
+	copy(f.pad, data[n:])
+	n += 6
+
+	end := int(f.Header.Length)
+	if end > len(data) {
+		end = len(data)
+	}
+	m := int(f.MatchLen)
+	if n+m+pad8(m) > end {
+		return util.NewMalformedError("Nicira FlowMod message", "match is longer than the message")
+	}
+	if err = f.Match.UnmarshalBinary(data[n : n+m]); err != nil {
+		return err
+	}
+	n += m + pad8(m)
+
+	f.Actions = make([]ofp10.Action, 0)
+	for n < end {
+		a, err := ofp10.DecodeAction(data[n:end])
+		if err != nil {
+			return err
+		}
+		f.Actions = append(f.Actions, a)
+		// Step by the length on the wire, which may hold more
+		// padding than Len allows for.
+		n += int(a.Header().Length)
+	}
+	return err
+}
+
+// nx_packet_in: a packet in message whose metadata is described
+// by an nx_match. Sent when the switch is set to the NXPIF_NXM
+// packet in format.
+type PacketIn struct {
+	Header
+	BufferId uint32
+	TotalLen uint16
+	Reason   uint8
+	TableId  uint8
+	Cookie   uint64
+	MatchLen uint16
+	pad      []uint8 // Size 6
+	Match    Match
+	Data     eth.Ethernet
+}
+
+func NewPacketIn() *PacketIn {
+	p := new(PacketIn)
+	p.Header = *NewHeader(NXT_PACKET_IN)
+	p.BufferId = 0xffffffff
+	p.pad = make([]byte, 6)
+	p.Match = *NewMatch()
+	p.Data = *eth.New()
+	return p
+}
+
+func (p *PacketIn) Len() (n uint16) {
+	n = p.Header.Len() + 24
+	m := int(p.Match.Len())
+	n += uint16(m + pad8(m) + 2)
+	n += p.Data.Len()
+	return
+}
+
+func (p *PacketIn) MarshalBinary() (data []byte, err error) {
+	p.Header.Length = p.Len()
+	p.MatchLen = p.Match.Len()
+	data, err = p.Header.MarshalBinary()
+
+	b := make([]byte, 24)
+	n := 0
+	binary.BigEndian.PutUint32(b[n:], p.BufferId)
+	n += 4
+	binary.BigEndian.PutUint16(b[n:], p.TotalLen)
+	n += 2
+	b[n] = p.Reason
+	n += 1
+	b[n] = p.TableId
+	n += 1
+	binary.BigEndian.PutUint64(b[n:], p.Cookie)
+	n += 8
+	binary.BigEndian.PutUint16(b[n:], p.MatchLen)
+	n += 2
+	copy(b[n:], p.pad)
+	data = append(data, b...)
+
+	if b, err = p.Match.MarshalBinary(); err != nil {
+		return
+	}
+	data = append(data, b...)
+	data = append(data, make([]byte, pad8(len(b))+2)...)
+
+	if b, err = p.Data.MarshalBinary(); err != nil {
+		return
+	}
+	data = append(data, b...)
+	return
+}
+
+func (p *PacketIn) UnmarshalBinary(data []byte) error {
+	if len(data) < 40 {
+		return util.NewTruncatedError("Nicira PacketIn message", 40, len(data))
+	}
+	err := p.Header.UnmarshalBinary(data)
+	n := int(p.Header.Len())
+	p.BufferId = binary.BigEndian.Uint32(data[n:])
+	n += 4
+	p.TotalLen = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	p.Reason = data[n]
+	n += 1
+	p.TableId = data[n]
+	n += 1
+	p.Cookie = binary.BigEndian.Uint64(data[n:])
+	n += 8
+	p.MatchLen = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	p.pad = make([]byte, 6)
+	copy(p.pad, data[n:])
+	n += 6
+
+	m := int(p.MatchLen)
+	if n+m+pad8(m)+2 > len(data) {
+		return util.NewMalformedError("Nicira PacketIn message", "match is longer than the message")
+	}
+	if err = p.Match.UnmarshalBinary(data[n : n+m]); err != nil {
+		return err
+	}
+	n += m + pad8(m) + 2
+
+	if n < len(data) {
+		err = p.Data.UnmarshalBinary(data[n:])
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package nicira
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/ofp10"
+)
+
+func TestMatchMarshalBinary(t *testing.T) {
+	b := "   00 00 04 06 00 00 00 00 00 01 " + // NXM_OF_ETH_SRC
+		"00 01 01 08 00 00 00 05 00 00 00 0f " // NXM_NX_REG0 masked
+	b = strings.Replace(b, " ", "", -1)
+
+	m := NewMatch()
+	m.Add(NXM_OF_ETH_SRC, []byte{0, 0, 0, 0, 0, 1})
+	m.AddMasked(NXM_NX_REG0, []byte{0, 0, 0, 5}, []byte{0, 0, 0, 0x0f})
+	data, _ := m.MarshalBinary()
+	d := hex.EncodeToString(data)
+	if (len(b) != len(d)) || (b != d) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", d)
+		t.Errorf("Received length of %d, expected %d", len(d), len(b))
+	}
+
+	m2 := NewMatch()
+	if err := m2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	e, ok := m2.Entry(NXM_NX_REG0)
+	if !ok || !e.HasMask() || e.Mask[3] != 0x0f || e.Value[3] != 5 {
+		t.Errorf("Got entry %v, expected masked reg0.", e)
+	}
+}
+
+func TestFlowModParse(t *testing.T) {
+	b := "   01 04 00 48 00 00 00 01 " + // Header
+		"00 00 23 20 00 00 00 0d " + // Vendor, Subtype
+		"00 00 00 00 00 00 00 00 " + // Cookie
+		"00 00 00 00 00 00 03 e8 " + // Command, Idle, Hard, Priority
+		"ff ff ff ff ff ff 00 00 " + // BufferId, OutPort, Flags
+		"00 06 00 00 00 00 00 00 " + // MatchLen, pad
+		"00 00 00 02 00 01 00 00 " + // NXM_OF_IN_PORT, pad
+		"ff ff 00 10 00 00 23 20 " + // Resubmit
+		"00 01 00 02 00 00 00 00 "
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	msg, err := ofp10.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, ok := msg.(*FlowMod)
+	if !ok {
+		t.Fatalf("Got %T, expected *FlowMod.", msg)
+	}
+	if e, ok := f.Match.Entry(NXM_OF_IN_PORT); !ok || e.Value[1] != 1 {
+		t.Errorf("Got match %v, expected in_port 1.", f.Match)
+	}
+	if len(f.Actions) != 1 {
+		t.Fatalf("Got %d actions, expected 1.", len(f.Actions))
+	}
+	if a, ok := f.Actions[0].(*ActionResubmit); !ok || a.InPort != 2 {
+		t.Errorf("Got action %v, expected resubmit to port 2.", f.Actions[0])
+	}
+
+	d, _ := f.MarshalBinary()
+	if hex.EncodeToString(d) != b {
+		t.Log("Exp:", b)
+		t.Log("Rec:", hex.EncodeToString(d))
+		t.Error("FlowMod did not marshal back to its original bytes.")
+	}
+}
+
+func TestActionRegLoad(t *testing.T) {
+	b := "   ff ff 00 18 00 00 23 20 00 07 " + // Header
+		"00 1f 00 01 00 04 " + // OfsNBits, Dst
+		"00 00 00 00 00 00 00 2a " // Value
+	b = strings.Replace(b, " ", "", -1)
+
+	a := NewActionRegLoad(NXM_NX_REG0, 0, 32, 42)
+	data, _ := a.MarshalBinary()
+	d := hex.EncodeToString(data)
+	if (len(b) != len(d)) || (b != d) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", d)
+		t.Errorf("Received length of %d, expected %d", len(d), len(b))
+	}
+
+	act, err := ofp10.DecodeAction(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := act.(*ActionRegLoad)
+	if !ok {
+		t.Fatalf("Got %T, expected *ActionRegLoad.", act)
+	}
+	if r.NBits() != 32 || r.Ofs() != 0 || r.Value != 42 {
+		t.Errorf("Got ofs %d nbits %d value %d, expected 0 32 42.",
+			r.Ofs(), r.NBits(), r.Value)
+	}
+}
+
+func TestActionLearnRoundTrip(t *testing.T) {
+	a := NewActionLearn()
+	a.TableId = 1
+	a.AddSpec(NewLearnSpecMatch(NXM_OF_ETH_SRC, 0, NXM_OF_ETH_DST, 0, 48))
+	a.AddSpec(NewLearnSpecOutput(NXM_OF_IN_PORT, 0, 16))
+	data, _ := a.MarshalBinary()
+	if len(data)%8 != 0 || len(data) != int(a.Len()) {
+		t.Fatalf("Got length %d, expected %d padded to 8.", len(data), a.Len())
+	}
+
+	act, err := ofp10.DecodeAction(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, ok := act.(*ActionLearn)
+	if !ok {
+		t.Fatalf("Got %T, expected *ActionLearn.", act)
+	}
+	if l.TableId != 1 || len(l.Specs) != 2 {
+		t.Errorf("Got table %d with %d specs, expected 1 with 2.", l.TableId, len(l.Specs))
+	} else if l.Specs[1].SrcField != NXM_OF_IN_PORT || l.Specs[1].NBits() != 16 {
+		t.Errorf("Got output spec %v, expected in_port.", l.Specs[1])
+	}
+}
+
+func TestFlowModParsePaddedAction(t *testing.T) {
+	learn := NewActionLearn()
+	learn.AddSpec(NewLearnSpecOutput(NXM_OF_IN_PORT, 0, 16))
+	f := NewFlowMod()
+	f.Actions = append(f.Actions, learn, NewActionResubmit(3))
+	data, _ := f.MarshalBinary()
+
+	// Pad the learn action by another 8 bytes.
+	n := len(data) - 16
+	data = append(data[:n], append(make([]byte, 8), data[n:]...)...)
+	binary.BigEndian.PutUint16(data[2:4], uint16(len(data)))
+	binary.BigEndian.PutUint16(data[n-int(learn.Len())+2:], learn.Len()+8)
+
+	f = NewFlowMod()
+	if err := f.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Actions) != 2 {
+		t.Fatalf("Got %d actions, expected 2.", len(f.Actions))
+	}
+	if r, ok := f.Actions[1].(*ActionResubmit); !ok || r.InPort != 3 {
+		t.Errorf("Got %#v, expected a resubmit to port 3.", f.Actions[1])
+	}
+}
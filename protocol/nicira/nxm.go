@@ -0,0 +1,207 @@
+package nicira
+
+import (
+	"encoding/binary"
+
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Builds an nxm_header from its vendor class, field number and
+// payload length in bytes.
+func nxmHeader(class, field, length uint32) uint32 {
+	return class<<16 | field<<9 | length
+}
+
+// Returns the nxm_header of register idx.
+func NXMReg(idx uint32) uint32 {
+	return nxmHeader(0x0001, idx, 4)
+}
+
+// nxm_header vendor classes.
+const (
+	NXM_OF_CLASS = 0x0000
+	NXM_NX_CLASS = 0x0001
+)
+
+// OpenFlow 1.0 compatible nxm_header fields.
+const (
+	NXM_OF_IN_PORT   = NXM_OF_CLASS<<16 | 0<<9 | 2
+	NXM_OF_ETH_DST   = NXM_OF_CLASS<<16 | 1<<9 | 6
+	NXM_OF_ETH_SRC   = NXM_OF_CLASS<<16 | 2<<9 | 6
+	NXM_OF_ETH_TYPE  = NXM_OF_CLASS<<16 | 3<<9 | 2
+	NXM_OF_VLAN_TCI  = NXM_OF_CLASS<<16 | 4<<9 | 2
+	NXM_OF_IP_TOS    = NXM_OF_CLASS<<16 | 5<<9 | 1
+	NXM_OF_IP_PROTO  = NXM_OF_CLASS<<16 | 6<<9 | 1
+	NXM_OF_IP_SRC    = NXM_OF_CLASS<<16 | 7<<9 | 4
+	NXM_OF_IP_DST    = NXM_OF_CLASS<<16 | 8<<9 | 4
+	NXM_OF_TCP_SRC   = NXM_OF_CLASS<<16 | 9<<9 | 2
+	NXM_OF_TCP_DST   = NXM_OF_CLASS<<16 | 10<<9 | 2
+	NXM_OF_UDP_SRC   = NXM_OF_CLASS<<16 | 11<<9 | 2
+	NXM_OF_UDP_DST   = NXM_OF_CLASS<<16 | 12<<9 | 2
+	NXM_OF_ICMP_TYPE = NXM_OF_CLASS<<16 | 13<<9 | 1
+	NXM_OF_ICMP_CODE = NXM_OF_CLASS<<16 | 14<<9 | 1
+	NXM_OF_ARP_OP    = NXM_OF_CLASS<<16 | 15<<9 | 2
+	NXM_OF_ARP_SPA   = NXM_OF_CLASS<<16 | 16<<9 | 4
+	NXM_OF_ARP_TPA   = NXM_OF_CLASS<<16 | 17<<9 | 4
+)
+
+// Nicira extension nxm_header fields.
+const (
+	NXM_NX_REG0     = NXM_NX_CLASS<<16 | 0<<9 | 4
+	NXM_NX_REG1     = NXM_NX_CLASS<<16 | 1<<9 | 4
+	NXM_NX_REG2     = NXM_NX_CLASS<<16 | 2<<9 | 4
+	NXM_NX_REG3     = NXM_NX_CLASS<<16 | 3<<9 | 4
+	NXM_NX_REG4     = NXM_NX_CLASS<<16 | 4<<9 | 4
+	NXM_NX_REG5     = NXM_NX_CLASS<<16 | 5<<9 | 4
+	NXM_NX_REG6     = NXM_NX_CLASS<<16 | 6<<9 | 4
+	NXM_NX_REG7     = NXM_NX_CLASS<<16 | 7<<9 | 4
+	NXM_NX_TUN_ID   = NXM_NX_CLASS<<16 | 16<<9 | 8
+	NXM_NX_ARP_SHA  = NXM_NX_CLASS<<16 | 17<<9 | 6
+	NXM_NX_ARP_THA  = NXM_NX_CLASS<<16 | 18<<9 | 6
+	NXM_NX_IPV6_SRC = NXM_NX_CLASS<<16 | 19<<9 | 16
+	NXM_NX_IPV6_DST = NXM_NX_CLASS<<16 | 20<<9 | 16
+)
+
+// A single nx_match entry: an nxm_header followed by its value
+// and, when the header has its mask bit set, a mask of the same
+// size.
+type MatchEntry struct {
+	Header uint32
+	Value  []byte
+	Mask   []byte
+}
+
+// Returns a match entry for field header. A nil mask matches
+// value exactly.
+func NewMatchEntry(header uint32, value, mask []byte) *MatchEntry {
+	m := new(MatchEntry)
+	m.Header = header &^ (1<<8 | 0xff)
+	m.Value = value
+	m.Mask = mask
+	return m
+}
+
+func (m *MatchEntry) Class() uint16 {
+	return uint16(m.Header >> 16)
+}
+
+func (m *MatchEntry) Field() uint8 {
+	return uint8(m.Header >> 9 & 0x7f)
+}
+
+func (m *MatchEntry) HasMask() bool {
+	return m.Mask != nil
+}
+
+func (m *MatchEntry) Len() (n uint16) {
+	return uint16(4 + len(m.Value) + len(m.Mask))
+}
+
+func (m *MatchEntry) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(m.Len()))
+	h := m.Header &^ (1<<8 | 0xff)
+	h |= uint32(len(m.Value) + len(m.Mask))
+	if m.HasMask() {
+		h |= 1 << 8
+	}
+	binary.BigEndian.PutUint32(data[:4], h)
+	n := 4
+	copy(data[n:], m.Value)
+	n += len(m.Value)
+	copy(data[n:], m.Mask)
+	return
+}
+
+func (m *MatchEntry) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return util.NewTruncatedError("MatchEntry header", 4, len(data))
+	}
+	m.Header = binary.BigEndian.Uint32(data[:4])
+	length := int(m.Header & 0xff)
+	if len(data) < 4+length {
+		return util.NewTruncatedError("MatchEntry", 4+length, len(data))
+	}
+	if m.Header&(1<<8) != 0 {
+		m.Value = make([]byte, length/2)
+		m.Mask = make([]byte, length/2)
+		copy(m.Value, data[4:])
+		copy(m.Mask, data[4+length/2:])
+	} else {
+		m.Value = make([]byte, length)
+		m.Mask = nil
+		copy(m.Value, data[4:])
+	}
+	return nil
+}
+
+// nx_match: a flow match built from a list of nxm entries.
+type Match struct {
+	Entries []MatchEntry
+}
+
+func NewMatch() *Match {
+	m := new(Match)
+	m.Entries = make([]MatchEntry, 0)
+	return m
+}
+
+// Matches field header exactly against value.
+func (m *Match) Add(header uint32, value []byte) {
+	m.Entries = append(m.Entries, *NewMatchEntry(header, value, nil))
+}
+
+// Matches the bits of field header set in mask against value.
+func (m *Match) AddMasked(header uint32, value, mask []byte) {
+	m.Entries = append(m.Entries, *NewMatchEntry(header, value, mask))
+}
+
+// Returns the entry for field header.
+func (m *Match) Entry(header uint32) (e MatchEntry, ok bool) {
+	for _, e = range m.Entries {
+		if e.Header&^(1<<8|0xff) == header&^(1<<8|0xff) {
+			return e, true
+		}
+	}
+	return
+}
+
+// Length of the match without trailing padding.
+func (m *Match) Len() (n uint16) {
+	for _, e := range m.Entries {
+		n += e.Len()
+	}
+	return
+}
+
+func (m *Match) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 0, int(m.Len()))
+	for _, e := range m.Entries {
+		b, err := e.MarshalBinary()
+		if err != nil {
+			return data, err
+		}
+		data = append(data, b...)
+	}
+	return
+}
+
+// Unmarshals exactly len(data) bytes of nxm entries. Callers
+// strip any trailing padding.
+func (m *Match) UnmarshalBinary(data []byte) error {
+	m.Entries = make([]MatchEntry, 0)
+	n := 0
+	for n < len(data) {
+		e := new(MatchEntry)
+		if err := e.UnmarshalBinary(data[n:]); err != nil {
+			return err
+		}
+		m.Entries = append(m.Entries, *e)
+		n += int(e.Len())
+	}
+	return nil
+}
+
+// Returns the number of bytes needed to pad n to a multiple of 8.
+func pad8(n int) int {
+	return (8 - n%8) % 8
+}
@@ -0,0 +1,125 @@
+package ofp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/nicira"
+	"github.com/jonstout/ogo/protocol/ofp10"
+	"github.com/jonstout/ogo/protocol/ofp13"
+	"github.com/jonstout/ogo/protocol/ofpxx"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Returns one of every ofp10, ofp13 and nicira message and action,
+// with bodies filled in where they have one.
+func appendTestMessages() map[string]util.Message {
+	hello, _ := ofpxx.NewHello(1)
+
+	flowStats := ofp10.NewFlowStats()
+	flowStats.Actions = append(flowStats.Actions, ofp10.NewActionOutput(1))
+	flowReply := ofp10.NewStatsReply(ofp10.StatsType_Flow)
+	flowReply.Body = append(flowReply.Body, flowStats)
+
+	queueReply := ofp10.NewQueueGetConfigReply()
+	queue := ofp10.NewPacketQueue(1)
+	queue.Properties = append(queue.Properties, ofp10.NewQueuePropMinRate(100))
+	queueReply.Queues = append(queueReply.Queues, *queue)
+
+	flowMod := ofp10.NewFlowMod()
+	flowMod.AddAction(ofp10.NewActionOutput(2))
+
+	packetOut := ofp10.NewPacketOut()
+	packetOut.AddAction(ofp10.NewActionOutput(3))
+	packetOut.Data = eth.New()
+
+	nxFlowMod := nicira.NewFlowMod()
+	nxFlowMod.Match.Add(nicira.NXM_OF_ETH_TYPE, []byte{0x08, 0x00})
+	nxFlowMod.Actions = append(nxFlowMod.Actions, nicira.NewActionResubmit(1))
+
+	learn := nicira.NewActionLearn()
+	learn.Specs = append(learn.Specs, *nicira.NewLearnSpecOutput(nicira.NXM_OF_IN_PORT, 0, 16))
+
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+
+	return map[string]util.Message{
+		"ofpxx.Header":                ofp10.NewEchoRequest(),
+		"ofpxx.Hello":                 hello,
+		"ofp10.ErrorMsg":              ofp10.NewErrorMsg(),
+		"ofp10.SwitchConfig":          ofp10.NewSetConfig(),
+		"ofp10.SwitchFeatures":        ofp10.NewFeaturesReply(),
+		"ofp10.FlowMod":               flowMod,
+		"ofp10.FlowRemoved":           ofp10.NewFlowRemoved(),
+		"ofp10.PacketOut":             packetOut,
+		"ofp10.PacketIn":              ofp10.NewPacketIn(),
+		"ofp10.VendorHeader":          &ofp10.VendorHeader{Header: ofpxx.NewOfp10Header(), Data: []byte{1, 2}},
+		"ofp10.PortMod":               ofp10.NewPortMod(1),
+		"ofp10.PortStatus":            ofp10.NewPortStatus(),
+		"ofp10.QueueGetConfigRequest": ofp10.NewQueueGetConfigRequest(1),
+		"ofp10.QueueGetConfigReply":   queueReply,
+		"ofp10.StatsRequest flow":     ofp10.NewStatsRequest(ofp10.StatsType_Flow),
+		"ofp10.StatsRequest aggr":     ofp10.NewStatsRequest(ofp10.StatsType_Aggregate),
+		"ofp10.StatsRequest port":     ofp10.NewStatsRequest(ofp10.StatsType_Port),
+		"ofp10.StatsRequest queue":    ofp10.NewStatsRequest(ofp10.StatsType_Queue),
+		"ofp10.StatsRequest desc":     ofp10.NewStatsRequest(ofp10.StatsType_Desc),
+		"ofp10.StatsReply":            flowReply,
+		"ofp10.FlowStatsRequest":      ofp10.NewFlowStatsRequest(),
+		"ofp10.AggregateStatsRequest": ofp10.NewAggregateStatsRequest(),
+		"ofp10.PortStatsRequest":      ofp10.NewPortStatsRequest(),
+		"ofp10.QueueStatsRequest":     ofp10.NewQueueStatsRequest(),
+		"ofp10.DescStats":             ofp10.NewDescStats(),
+		"ofp10.FlowStats":             flowStats,
+		"ofp10.AggregateStats":        ofp10.NewAggregateStats(),
+		"ofp10.TableStats":            ofp10.NewTableStats(),
+		"ofp10.PortStats":             ofp10.NewPortStats(),
+		"ofp10.QueueStats":            ofp10.NewQueueStats(),
+		"ofp10.VendorStats":           ofp10.NewVendorStats(1),
+		"ofp10.Match":                 ofp10.NewMatch(),
+		"ofp10.PhyPort":               ofp10.NewPhyPort(),
+		"ofp10.PacketQueue":           queue,
+		"ofp10.ActionOutput":          ofp10.NewActionOutput(1),
+		"ofp10.ActionEnqueue":         ofp10.NewActionEnqueue(1, 2),
+		"ofp10.ActionVLANVID":         ofp10.NewActionVLANVID(10),
+		"ofp10.ActionVLANPCP":         ofp10.NewActionVLANPCP(3),
+		"ofp10.ActionStripVLAN":       ofp10.NewActionStripVLAN(),
+		"ofp10.ActionDLAddr":          ofp10.NewActionDLSrc(mac),
+		"ofp10.ActionNWAddr":          ofp10.NewActionNWDst(net.IPv4(10, 0, 0, 1)),
+		"ofp10.ActionNWTOS":           ofp10.NewActionNWTOS(4),
+		"ofp10.ActionTPPort":          ofp10.NewActionTPSrc(80),
+		"ofp10.ActionVendor":          ofp10.NewActionVendor(1),
+		"ofp13.ExperimenterHeader":    ofp13.NewExperimenterHeader(1, 2),
+		"nicira.Header":               nicira.NewHeader(nicira.NXT_FLOW_MOD),
+		"nicira.SetFlowFormat":        nicira.NewSetFlowFormat(nicira.NXFF_NXM),
+		"nicira.SetPacketInFormat":    nicira.NewSetPacketInFormat(nicira.NXPIF_NXM),
+		"nicira.FlowMod":              nxFlowMod,
+		"nicira.PacketIn":             nicira.NewPacketIn(),
+		"nicira.ActionResubmit":       nicira.NewActionResubmitTable(1, 2),
+		"nicira.ActionSetTunnel":      nicira.NewActionSetTunnel(5),
+		"nicira.ActionSetTunnel64":    nicira.NewActionSetTunnel64(5),
+		"nicira.ActionRegMove":        nicira.NewActionRegMove(nicira.NXM_OF_IN_PORT, nicira.NXM_OF_ETH_TYPE, 0, 0, 16),
+		"nicira.ActionRegLoad":        nicira.NewActionRegLoad(nicira.NXM_OF_ETH_TYPE, 0, 16, 0x800),
+		"nicira.ActionLearn":          learn,
+	}
+}
+
+func TestAppendBinary(t *testing.T) {
+	for name, m := range appendTestMessages() {
+		exp, err := m.MarshalBinary()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		rec, err := util.AppendBinary(nil, m)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(exp, rec) {
+			t.Logf("Exp: %x", exp)
+			t.Logf("Rec: %x", rec)
+			t.Errorf("%s: appended %d bytes, marshaled %d.", name, len(rec), len(exp))
+		}
+	}
+}
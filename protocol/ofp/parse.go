@@ -1,17 +1,26 @@
 package ofp
 
 import (
+	"strconv"
+
 	"github.com/jonstout/ogo/protocol/ofp10"
 	"github.com/jonstout/ogo/protocol/ofp13"
 	"github.com/jonstout/ogo/protocol/util"
 )
 
+// Returns the message at the start of b, parsed by the package of
+// its version.
 func Parse(b []byte) (message util.Message, err error) {
+	if len(b) < 1 {
+		return nil, util.NewTruncatedError("OpenFlow message", 8, len(b))
+	}
 	switch b[0] {
 	case 1:
 		message, err = ofp10.Parse(b)
 	case 4:
 		message, err = ofp13.Parse(b)
+	default:
+		err = util.NewMalformedError("OpenFlow message", "unsupported version "+strconv.Itoa(int(b[0])))
 	}
 	return
 }
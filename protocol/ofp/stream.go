@@ -0,0 +1,90 @@
+package ofp
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/jonstout/ogo/protocol/ofpxx"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Returned by Decode when a message was read whole but could not be
+// parsed. The decoder has moved past the message, so decoding can
+// continue. Decode also returns as much of the message as was
+// parsed, which is nil if not even its type was known.
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+// Reads OpenFlow messages from a stream, such as a TCP or TLS
+// connection, a unix socket or a capture being replayed.
+type Decoder struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	d := new(Decoder)
+	if br, ok := r.(*bufio.Reader); ok {
+		d.r = br
+	} else {
+		d.r = bufio.NewReader(r)
+	}
+	d.buf = make([]byte, 0, 2048)
+	return d
+}
+
+// Returns the next message without parsing it. The message is only
+// valid until the next call to ReadMessage or Decode.
+func (d *Decoder) ReadMessage() ([]byte, error) {
+	b, err := ofpxx.ReadMessage(d.r, d.buf)
+	d.buf = b[:0]
+	return b, err
+}
+
+// Reads and parses the next message. Errors other than a
+// *ParseError come from the stream and end it.
+//
+// Switches cut the packets they send to the controller short, so
+// messages whose payload fails to parse are returned along with
+// the *ParseError. Their payload is decoded as far as it goes.
+func (d *Decoder) Decode() (util.Message, error) {
+	b, err := d.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := Parse(b)
+	if err != nil {
+		return msg, &ParseError{err}
+	}
+	return msg, nil
+}
+
+// Writes OpenFlow messages to a stream. An Encoder is not safe for
+// use by more than one goroutine at a time.
+type Encoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	e := new(Encoder)
+	e.w = w
+	e.buf = make([]byte, 0, 2048)
+	return e
+}
+
+// Marshals msg and writes it with a single call to Write.
+func (e *Encoder) Encode(msg util.Message) error {
+	b, err := util.AppendBinary(e.buf[:0], msg)
+	if err != nil {
+		return err
+	}
+	e.buf = b
+	_, err = e.w.Write(b)
+	return err
+}
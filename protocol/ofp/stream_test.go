@@ -0,0 +1,145 @@
+package ofp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/nicira"
+	"github.com/jonstout/ogo/protocol/ofp10"
+	"github.com/jonstout/ogo/protocol/ofpxx"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	var stream bytes.Buffer
+	enc := NewEncoder(&stream)
+	if err := enc.Encode(ofp10.NewEchoRequest()); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(ofp10.NewFeaturesRequest()); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := NewDecoder(&stream)
+	msg, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, ok := msg.(*ofpxx.Header); !ok || h.Type != ofp10.Type_EchoRequest {
+		t.Errorf("Got %#v, expected an echo request.", msg)
+	}
+	msg, err = dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, ok := msg.(*ofpxx.Header); !ok || h.Type != ofp10.Type_FeaturesRequest {
+		t.Errorf("Got %#v, expected a features request.", msg)
+	}
+	if _, err = dec.Decode(); err != io.EOF {
+		t.Errorf("Got error %v, expected %v.", err, io.EOF)
+	}
+}
+
+// Messages with bodies must reach the stream whole, not just their
+// headers.
+func TestEncodeDecodeBodies(t *testing.T) {
+	flowStats := ofp10.NewFlowStats()
+	flowStats.Priority = 7
+	flowStats.Actions = append(flowStats.Actions, ofp10.NewActionOutput(1))
+	flowReply := ofp10.NewStatsReply(ofp10.StatsType_Flow)
+	flowReply.Body = append(flowReply.Body, flowStats)
+
+	portMod := ofp10.NewPortMod(2)
+	portMod.HWAddr = []byte{0, 1, 2, 3, 4, 5}
+
+	nxFlowMod := nicira.NewFlowMod()
+	nxFlowMod.Match.Add(nicira.NXM_OF_ETH_TYPE, []byte{0x08, 0x00})
+	nxFlowMod.Actions = append(nxFlowMod.Actions, nicira.NewActionResubmit(1))
+
+	msgs := []util.Message{
+		ofp10.NewStatsRequest(ofp10.StatsType_Flow),
+		ofp10.NewQueueGetConfigRequest(1),
+		portMod,
+		flowReply,
+		nxFlowMod,
+	}
+
+	var stream bytes.Buffer
+	enc := NewEncoder(&stream)
+	exp := make([][]byte, len(msgs))
+	for i, m := range msgs {
+		exp[i], _ = m.MarshalBinary()
+		if err := enc.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := NewDecoder(&stream)
+	for i, m := range msgs {
+		msg, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("%T: %v", m, err)
+		}
+		if reflect.TypeOf(msg) != reflect.TypeOf(m) {
+			t.Errorf("Got %T, expected %T.", msg, m)
+			continue
+		}
+		if rec, _ := msg.MarshalBinary(); !bytes.Equal(rec, exp[i]) {
+			t.Logf("Exp: %x", exp[i])
+			t.Logf("Rec: %x", rec)
+			t.Errorf("%T did not round trip.", m)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("Got error %v, expected %v.", err, io.EOF)
+	}
+}
+
+func TestDecodeParseError(t *testing.T) {
+	s := "   01 ff 00 08 00 00 00 01 " + // Unknown type
+		"01 02 00 08 00 00 00 02 " // Echo request
+	s = strings.Replace(s, " ", "", -1)
+	data, _ := hex.DecodeString(s)
+	dec := NewDecoder(bytes.NewReader(data))
+
+	if msg, err := dec.Decode(); err == nil {
+		t.Fatal("Decoded a message of unknown type.")
+	} else if _, ok := err.(*ParseError); !ok {
+		t.Errorf("Got error %#v, expected a *ParseError.", err)
+	} else if msg != nil {
+		t.Errorf("Got %#v, expected no message.", msg)
+	}
+	msg, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, ok := msg.(*ofpxx.Header); !ok || h.Xid != 2 {
+		t.Errorf("Got %#v, expected the echo request that follows.", msg)
+	}
+}
+
+func TestDecodeTruncatedPacketIn(t *testing.T) {
+	s := "   01 0a 00 24 00 00 00 03 " + // Header
+		"ff ff ff ff 00 40 00 01 00 00 " + // BufferId, TotalLen, InPort, Reason, pad
+		"ff ff ff ff ff ff 00 11 22 33 44 55 08 00 " + // Ethernet
+		"45 00 00 3c " // IPv4 header cut short
+	s = strings.Replace(s, " ", "", -1)
+	data, _ := hex.DecodeString(s)
+	dec := NewDecoder(bytes.NewReader(data))
+
+	msg, err := dec.Decode()
+	if _, ok := err.(*ParseError); !ok {
+		t.Errorf("Got error %#v, expected a *ParseError.", err)
+	}
+	p, ok := msg.(*ofp10.PacketIn)
+	if !ok {
+		t.Fatalf("Got %#v, expected the PacketIn.", msg)
+	}
+	if p.InPort != 1 || p.Data.Ethertype != 0x0800 {
+		t.Errorf("Got in port %d and ethertype %#x, expected 1 and 0x800.", p.InPort, p.Data.Ethertype)
+	}
+}
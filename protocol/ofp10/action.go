@@ -2,8 +2,8 @@ package ofp10
 
 import (
 	"encoding/binary"
-	"errors"
 	"net"
+	"strconv"
 
 	"github.com/jonstout/ogo/protocol/util"
 )
@@ -44,32 +44,74 @@ func (a *ActionHeader) Len() (n uint16) {
 }
 
 func (a *ActionHeader) MarshalBinary() (data []byte, err error) {
-	data = make([]byte, a.Len())
-	binary.BigEndian.PutUint16(data[:2], a.Type)
-	binary.BigEndian.PutUint16(data[2:4], a.Length)
+	return appendActionHeader(make([]byte, 0, int(a.Len())), a), nil
+}
+
+// Appends the encoding of a to b. ActionHeader is embedded in every
+// action, which an AppendBinary method would be promoted to.
+func appendActionHeader(b []byte, a *ActionHeader) (data []byte) {
+	n := len(b)
+	data = util.Extend(b, int(a.Len()))
+	binary.BigEndian.PutUint16(data[n:n+2], a.Type)
+	binary.BigEndian.PutUint16(data[n+2:n+4], a.Length)
 	return
 }
 
 func (a *ActionHeader) UnmarshalBinary(data []byte) error {
-	if len(data) != 4 {
-		return errors.New("The []byte the wrong size to unmarshal an " +
-			"ActionHeader message.")
+	if len(data) < 4 {
+		return util.NewTruncatedError("ActionHeader message", 4, len(data))
 	}
 	a.Type = binary.BigEndian.Uint16(data[:2])
 	a.Length = binary.BigEndian.Uint16(data[2:4])
 	return nil
 }
 
-// TODO: Decode other Action types.
-func DecodeAction(data []byte) Action {
+// Returns the action found at the start of data. Its header
+// length must be a multiple of 8 that fits in data, and must equal
+// the size of actions that have a fixed size. Callers step to the
+// next action by that length.
+func DecodeAction(data []byte) (Action, error) {
+	if len(data) < 4 {
+		return nil, util.NewTruncatedError("Action", 4, len(data))
+	}
 	t := binary.BigEndian.Uint16(data[:2])
+	length := int(binary.BigEndian.Uint16(data[2:4]))
+	if length < 8 || length%8 != 0 || length > len(data) {
+		return nil, util.NewMalformedError("Action", "length "+strconv.Itoa(length)+" is not a multiple of 8 that fits "+strconv.Itoa(len(data))+" bytes")
+	}
+	data = data[:length]
 	var a Action
 	switch t {
 	case ActionType_Output:
 		a = new(ActionOutput)
+	case ActionType_SetVLAN_VID:
+		a = new(ActionVLANVID)
+	case ActionType_SetVLAN_PCP:
+		a = new(ActionVLANPCP)
+	case ActionType_StripVLAN:
+		a = new(ActionStripVLAN)
+	case ActionType_SetDLSrc, ActionType_SetDLDst:
+		a = new(ActionDLAddr)
+	case ActionType_SetNWSrc, ActionType_SetNWDst:
+		a = new(ActionNWAddr)
+	case ActionType_SetNWTOS:
+		a = new(ActionNWTOS)
+	case ActionType_SetTPSrc, ActionType_SetTPDst:
+		a = new(ActionTPPort)
+	case ActionType_Enqueue:
+		a = new(ActionEnqueue)
+	case ActionType_Vendor:
+		return decodeVendorAction(data)
+	default:
+		return nil, util.NewMalformedError("Action", "unknown type "+strconv.Itoa(int(t)))
 	}
-	a.UnmarshalBinary(data)
-	return a
+	if err := a.UnmarshalBinary(data); err != nil {
+		return a, err
+	}
+	if int(a.Len()) != length {
+		return a, util.NewMalformedError("Action", "length "+strconv.Itoa(length)+" does not match type "+strconv.Itoa(int(t)))
+	}
+	return a, nil
 }
 
 // Action structure for OFPAT_OUTPUT, which sends packets out ’port’.
@@ -98,13 +140,13 @@ func (a *ActionOutput) Len() (n uint16) {
 }
 
 func (a *ActionOutput) MarshalBinary() (data []byte, err error) {
-	data = make([]byte, int(a.Len()))
-	b := make([]byte, 0)
-	n := 0
+	return a.AppendBinary(make([]byte, 0, int(a.Len())))
+}
 
-	b, err = a.ActionHeader.MarshalBinary()
-	copy(data[n:], b)
-	n += len(b)
+func (a *ActionOutput) AppendBinary(b []byte) (data []byte, err error) {
+	data = appendActionHeader(b, &a.ActionHeader)
+	n := len(data)
+	data = util.Extend(data, 4)
 	binary.BigEndian.PutUint16(data[n:], a.Port)
 	n += 2
 	binary.BigEndian.PutUint16(data[n:], a.MaxLen)
@@ -114,8 +156,7 @@ func (a *ActionOutput) MarshalBinary() (data []byte, err error) {
 
 func (a *ActionOutput) UnmarshalBinary(data []byte) error {
 	if len(data) < int(a.Len()) {
-		return errors.New("The []byte the wrong size to unmarshal an " +
-			"ActionOutput message.")
+		return util.NewTruncatedError("ActionOutput message", int(a.Len()), len(data))
 	}
 	n := 0
 	err := a.ActionHeader.UnmarshalBinary(data[n:])
@@ -157,24 +198,27 @@ func (a *ActionEnqueue) Len() (n uint16) {
 }
 
 func (a *ActionEnqueue) MarshalBinary() (data []byte, err error) {
-	data, err = a.ActionHeader.MarshalBinary()
+	return a.AppendBinary(make([]byte, 0, int(a.Len())))
+}
 
-	bytes := make([]byte, 12)
-	binary.BigEndian.PutUint16(data[:2], a.Port)
-	copy(bytes[2:8], a.pad)
-	binary.BigEndian.PutUint32(data[8:12], a.QueueId)
+func (a *ActionEnqueue) AppendBinary(b []byte) (data []byte, err error) {
+	data = appendActionHeader(b, &a.ActionHeader)
 
-	data = append(data, bytes...)
+	data = util.Extend(data, 12)
+	bytes := data[len(data)-12:]
+	binary.BigEndian.PutUint16(bytes[:2], a.Port)
+	copy(bytes[2:8], a.pad)
+	binary.BigEndian.PutUint32(bytes[8:12], a.QueueId)
 	return
 }
 
 func (a *ActionEnqueue) UnmarshalBinary(data []byte) error {
-	if len(data) != int(a.Len()) {
-		return errors.New("The []byte the wrong size to unmarshal an " +
-			"ActionEnqueue message.")
+	if len(data) < int(a.Len()) {
+		return util.NewTruncatedError("ActionEnqueue message", int(a.Len()), len(data))
 	}
 	a.ActionHeader.UnmarshalBinary(data[:4])
 	a.Port = binary.BigEndian.Uint16(data[4:6])
+	a.pad = make([]byte, 6)
 	copy(a.pad, data[6:12])
 	a.QueueId = binary.BigEndian.Uint32(data[12:16])
 	return nil
@@ -204,27 +248,37 @@ func (a *ActionVLANVID) Len() (n uint16) {
 }
 
 func (a *ActionVLANVID) MarshalBinary() (data []byte, err error) {
-	data, err = a.ActionHeader.MarshalBinary()
+	return a.AppendBinary(make([]byte, 0, int(a.Len())))
+}
 
-	bytes := make([]byte, 4)
-	binary.BigEndian.PutUint16(data[:2], a.VLANVID)
-	copy(bytes[2:4], a.pad)
+func (a *ActionVLANVID) AppendBinary(b []byte) (data []byte, err error) {
+	data = appendActionHeader(b, &a.ActionHeader)
 
-	data = append(data, bytes...)
+	data = util.Extend(data, 4)
+	bytes := data[len(data)-4:]
+	binary.BigEndian.PutUint16(bytes[:2], a.VLANVID)
+	copy(bytes[2:4], a.pad)
 	return
 }
 
 func (a *ActionVLANVID) UnmarshalBinary(data []byte) error {
-	if len(data) != int(a.Len()) {
-		return errors.New("The []byte the wrong size to unmarshal an " +
-			"ActionVLANVID message.")
+	if len(data) < int(a.Len()) {
+		return util.NewTruncatedError("ActionVLANVID message", int(a.Len()), len(data))
 	}
 	a.ActionHeader.UnmarshalBinary(data[:4])
 	a.VLANVID = binary.BigEndian.Uint16(data[4:6])
+	a.pad = make([]byte, 2)
 	copy(a.pad, data[6:8])
 	return nil
 }
 
+// Returns the actions that tag a packet with vid and pcp. Untagged
+// packets gain a tag, while tagged packets have their outermost tag
+// rewritten; OpenFlow 1.0 cannot push a second tag.
+func NewActionsSetVLAN(vid uint16, pcp uint8) []Action {
+	return []Action{NewActionVLANVID(vid & 0x0fff), NewActionVLANPCP(pcp & 0x07)}
+}
+
 // The vlan_pcp field is 8 bits long, but only the lower 3 bits have meaning.
 type ActionVLANPCP struct {
 	ActionHeader
@@ -247,23 +301,26 @@ func (a *ActionVLANPCP) Len() (n uint16) {
 }
 
 func (a *ActionVLANPCP) MarshalBinary() (data []byte, err error) {
-	data, err = a.ActionHeader.MarshalBinary()
+	return a.AppendBinary(make([]byte, 0, int(a.Len())))
+}
+
+func (a *ActionVLANPCP) AppendBinary(b []byte) (data []byte, err error) {
+	data = appendActionHeader(b, &a.ActionHeader)
 
-	bytes := make([]byte, 4)
+	data = util.Extend(data, 4)
+	bytes := data[len(data)-4:]
 	bytes[0] = a.VLANPCP
 	copy(bytes[1:4], a.pad)
-
-	data = append(data, bytes...)
 	return
 }
 
 func (a *ActionVLANPCP) UnmarshalBinary(data []byte) error {
-	if len(data) != int(a.Len()) {
-		return errors.New("The []byte the wrong size to unmarshal an " +
-			"ActionVLANPCP message.")
+	if len(data) < int(a.Len()) {
+		return util.NewTruncatedError("ActionVLANPCP message", int(a.Len()), len(data))
 	}
 	a.ActionHeader.UnmarshalBinary(data[:4])
 	a.VLANPCP = data[4]
+	a.pad = make([]byte, 3)
 	copy(a.pad, data[5:8])
 	return nil
 }
@@ -289,21 +346,24 @@ func (a *ActionStripVLAN) Len() (n uint16) {
 }
 
 func (a *ActionStripVLAN) MarshalBinary() (data []byte, err error) {
-	data, err = a.ActionHeader.MarshalBinary()
+	return a.AppendBinary(make([]byte, 0, int(a.Len())))
+}
 
-	bytes := make([]byte, 4)
-	copy(bytes[0:4], a.pad)
+func (a *ActionStripVLAN) AppendBinary(b []byte) (data []byte, err error) {
+	data = appendActionHeader(b, &a.ActionHeader)
 
-	data = append(data, bytes...)
+	data = util.Extend(data, 4)
+	bytes := data[len(data)-4:]
+	copy(bytes[0:4], a.pad)
 	return
 }
 
 func (a *ActionStripVLAN) UnmarshalBinary(data []byte) error {
-	if len(data) != int(a.Len()) {
-		return errors.New("The []byte the wrong size to unmarshal an " +
-			"ActionStripVLAN message.")
+	if len(data) < int(a.Len()) {
+		return util.NewTruncatedError("ActionStripVLAN message", int(a.Len()), len(data))
 	}
 	a.ActionHeader.UnmarshalBinary(data[:4])
+	a.pad = make([]byte, 4)
 	copy(a.pad, data[4:8])
 	return nil
 }
@@ -340,23 +400,27 @@ func (a *ActionDLAddr) Len() (n uint16) {
 }
 
 func (a *ActionDLAddr) MarshalBinary() (data []byte, err error) {
-	data, err = a.ActionHeader.MarshalBinary()
+	return a.AppendBinary(make([]byte, 0, int(a.Len())))
+}
+
+func (a *ActionDLAddr) AppendBinary(b []byte) (data []byte, err error) {
+	data = appendActionHeader(b, &a.ActionHeader)
 
-	bytes := make([]byte, 12)
+	data = util.Extend(data, 12)
+	bytes := data[len(data)-12:]
 	copy(bytes[0:6], a.DLAddr)
 	copy(bytes[6:12], a.pad)
-
-	data = append(data, bytes...)
 	return
 }
 
 func (a *ActionDLAddr) UnmarshalBinary(data []byte) error {
-	if len(data) != int(a.Len()) {
-		return errors.New("The []byte the wrong size to unmarshal an " +
-			"ActionDLAddr message.")
+	if len(data) < int(a.Len()) {
+		return util.NewTruncatedError("ActionDLAddr message", int(a.Len()), len(data))
 	}
 	a.ActionHeader.UnmarshalBinary(data[:4])
+	a.DLAddr = make([]byte, ETH_ALEN)
 	copy(a.DLAddr, data[4:10])
+	a.pad = make([]byte, 6)
 	copy(a.pad, data[10:16])
 	return nil
 }
@@ -390,21 +454,24 @@ func (a *ActionNWAddr) Len() (n uint16) {
 }
 
 func (a *ActionNWAddr) MarshalBinary() (data []byte, err error) {
-	data, err = a.ActionHeader.MarshalBinary()
+	return a.AppendBinary(make([]byte, 0, int(a.Len())))
+}
 
-	bytes := make([]byte, 4)
-	copy(bytes[:4], a.NWAddr)
+func (a *ActionNWAddr) AppendBinary(b []byte) (data []byte, err error) {
+	data = appendActionHeader(b, &a.ActionHeader)
 
-	data = append(data, bytes...)
+	data = util.Extend(data, 4)
+	bytes := data[len(data)-4:]
+	copy(bytes[:4], a.NWAddr)
 	return
 }
 
 func (a *ActionNWAddr) UnmarshalBinary(data []byte) error {
-	if len(data) != int(a.Len()) {
-		return errors.New("The []byte the wrong size to unmarshal an " +
-			"ActionDLAddr message.")
+	if len(data) < int(a.Len()) {
+		return util.NewTruncatedError("ActionNWAddr message", int(a.Len()), len(data))
 	}
 	a.ActionHeader.UnmarshalBinary(data[:4])
+	a.NWAddr = make([]byte, 4)
 	copy(a.NWAddr, data[4:8])
 	return nil
 }
@@ -432,23 +499,26 @@ func (a *ActionNWTOS) Len() (n uint16) {
 }
 
 func (a *ActionNWTOS) MarshalBinary() (data []byte, err error) {
-	data, err = a.ActionHeader.MarshalBinary()
+	return a.AppendBinary(make([]byte, 0, int(a.Len())))
+}
 
-	bytes := make([]byte, 4)
-	data[0] = a.NWTOS
-	copy(bytes[1:4], a.pad)
+func (a *ActionNWTOS) AppendBinary(b []byte) (data []byte, err error) {
+	data = appendActionHeader(b, &a.ActionHeader)
 
-	data = append(data, bytes...)
+	data = util.Extend(data, 4)
+	bytes := data[len(data)-4:]
+	bytes[0] = a.NWTOS
+	copy(bytes[1:4], a.pad)
 	return
 }
 
 func (a *ActionNWTOS) UnmarshalBinary(data []byte) error {
-	if len(data) != int(a.Len()) {
-		return errors.New("The []byte the wrong size to unmarshal an " +
-			"ActionDLAddr message.")
+	if len(data) < int(a.Len()) {
+		return util.NewTruncatedError("ActionNWTOS message", int(a.Len()), len(data))
 	}
 	a.ActionHeader.UnmarshalBinary(data[:4])
 	a.NWTOS = data[4]
+	a.pad = make([]byte, 3)
 	copy(a.pad, data[5:8])
 	return nil
 }
@@ -486,32 +556,36 @@ func (a *ActionTPPort) Len() (n uint16) {
 }
 
 func (a *ActionTPPort) MarshalBinary() (data []byte, err error) {
-	data, err = a.ActionHeader.MarshalBinary()
+	return a.AppendBinary(make([]byte, 0, int(a.Len())))
+}
 
-	bytes := make([]byte, 4)
-	binary.BigEndian.PutUint16(data[:2], a.TPPort)
-	copy(bytes[2:4], a.pad)
+func (a *ActionTPPort) AppendBinary(b []byte) (data []byte, err error) {
+	data = appendActionHeader(b, &a.ActionHeader)
 
-	data = append(data, bytes...)
+	data = util.Extend(data, 4)
+	bytes := data[len(data)-4:]
+	binary.BigEndian.PutUint16(bytes[:2], a.TPPort)
+	copy(bytes[2:4], a.pad)
 	return
 }
 
 func (a *ActionTPPort) UnmarshalBinary(data []byte) error {
-	if len(data) != int(a.Len()) {
-		return errors.New("The []byte the wrong size to unmarshal an " +
-			"ActionNWTOS message.")
+	if len(data) < int(a.Len()) {
+		return util.NewTruncatedError("ActionTPPort message", int(a.Len()), len(data))
 	}
 	a.ActionHeader.UnmarshalBinary(data[:4])
 	a.TPPort = binary.BigEndian.Uint16(data[4:6])
+	a.pad = make([]byte, 2)
 	copy(a.pad, data[6:8])
 	return nil
 }
 
 // The Vendor field is the Vendor ID, which takes the same form as in struct
-// ofp_vendor.
+// ofp_vendor. Data holds the vendor defined body of the action.
 type ActionVendor struct {
 	ActionHeader
 	Vendor uint32
+	Data   []byte
 }
 
 func NewActionVendor(vendor uint32) *ActionVendor {
@@ -519,29 +593,40 @@ func NewActionVendor(vendor uint32) *ActionVendor {
 	a.Type = ActionType_Vendor
 	a.Length = 8
 	a.Vendor = vendor
+	a.Data = make([]byte, 0)
 	return a
 }
 
 func (a *ActionVendor) Len() (n uint16) {
-	return a.ActionHeader.Len() + 4
+	return a.ActionHeader.Len() + 4 + uint16(len(a.Data))
 }
 
 func (a *ActionVendor) MarshalBinary() (data []byte, err error) {
-	data, err = a.ActionHeader.MarshalBinary()
+	return a.AppendBinary(make([]byte, 0, int(a.Len())))
+}
+
+func (a *ActionVendor) AppendBinary(b []byte) (data []byte, err error) {
+	a.Length = a.Len()
+	data = appendActionHeader(b, &a.ActionHeader)
 
-	bytes := make([]byte, 4)
-	binary.BigEndian.PutUint32(data[:4], a.Vendor)
+	data = util.Extend(data, 4)
+	bytes := data[len(data)-4:]
+	binary.BigEndian.PutUint32(bytes[:4], a.Vendor)
 
-	data = append(data, bytes...)
+	data = append(data, a.Data...)
 	return
 }
 
 func (a *ActionVendor) UnmarshalBinary(data []byte) error {
-	if len(data) != int(a.Len()) {
-		return errors.New("The []byte the wrong size to unmarshal an " +
-			"ActionVendor message.")
+	if len(data) < 8 {
+		return util.NewTruncatedError("ActionVendor message", 8, len(data))
 	}
 	a.ActionHeader.UnmarshalBinary(data[:4])
 	a.Vendor = binary.BigEndian.Uint32(data[4:8])
+	if int(a.Length) < 8 || int(a.Length) > len(data) {
+		return util.NewMalformedError("ActionVendor message", "length "+strconv.Itoa(int(a.Length))+" does not fit "+strconv.Itoa(len(data))+" bytes")
+	}
+	a.Data = make([]byte, int(a.Length)-8)
+	copy(a.Data, data[8:a.Length])
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 
 	"github.com/jonstout/ogo/protocol/ofpxx"
+	"github.com/jonstout/ogo/protocol/util"
 )
 
 func NewConfigRequest() *ofpxx.Header {
@@ -59,6 +60,9 @@ func (c *SwitchConfig) MarshalBinary() (data []byte, err error) {
 }
 
 func (c *SwitchConfig) UnmarshalBinary(data []byte) error {
+	if len(data) < 12 {
+		return util.NewTruncatedError("SwitchConfig message", 12, len(data))
+	}
 	var err error
 	next := 0
 
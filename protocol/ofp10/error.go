@@ -17,6 +17,8 @@ type ErrorMsg struct {
 
 func NewErrorMsg() *ErrorMsg {
 	e := new(ErrorMsg)
+	e.Header = ofpxx.NewOfp10Header()
+	e.Header.Type = Type_Error
 	e.Data = *util.NewBuffer(make([]byte, 0))
 	return e
 }
@@ -32,6 +34,7 @@ func (e *ErrorMsg) MarshalBinary() (data []byte, err error) {
 	data = make([]byte, int(e.Len()))
 	next := 0
 
+	e.Header.Length = e.Len()
 	bytes, err := e.Header.MarshalBinary()
 	copy(data[next:], bytes)
 	next += len(bytes)
@@ -44,6 +47,9 @@ func (e *ErrorMsg) MarshalBinary() (data []byte, err error) {
 }
 
 func (e *ErrorMsg) UnmarshalBinary(data []byte) error {
+	if len(data) < 10 {
+		return util.NewTruncatedError("ErrorMsg message", 10, len(data))
+	}
 	next := 0
 	e.Header.UnmarshalBinary(data[next:])
 	next += int(e.Header.Len())
@@ -5,6 +5,7 @@ import (
 	"net"
 
 	"github.com/jonstout/ogo/protocol/ofpxx"
+	"github.com/jonstout/ogo/protocol/util"
 )
 
 type SwitchFeatures struct {
@@ -39,7 +40,7 @@ func NewFeaturesReply() *SwitchFeatures {
 
 func (s *SwitchFeatures) Len() (n uint16) {
 	n = s.Header.Len()
-	n += uint16(len(s.DPID))
+	n += 8
 	n += 16
 	for _, p := range s.Ports {
 		n += p.Len()
@@ -56,6 +57,8 @@ func (s *SwitchFeatures) MarshalBinary() (data []byte, err error) {
 	bytes, err = s.Header.MarshalBinary()
 	copy(data[next:], bytes)
 	next += len(bytes)
+	copy(data[next:next+8], s.DPID)
+	next += 8
 	binary.BigEndian.PutUint32(data[next:], s.Buffers)
 	next += 4
 	data[next] = s.Tables
@@ -79,27 +82,34 @@ func (s *SwitchFeatures) MarshalBinary() (data []byte, err error) {
 }
 
 func (s *SwitchFeatures) UnmarshalBinary(data []byte) error {
+	if len(data) < 32 {
+		return util.NewTruncatedError("SwitchFeatures message", 32, len(data))
+	}
 	var err error
 	next := 0
 	
 	err = s.Header.UnmarshalBinary(data[next:])
 	next = int(s.Header.Len())
+	s.DPID = make(net.HardwareAddr, 8)
 	copy(s.DPID, data[next:])
-	next += len(s.DPID)
+	next += 8
 	s.Buffers = binary.BigEndian.Uint32(data[next:])
 	next += 4
 	s.Tables = data[next]
 	next += 1
+	s.pad = make([]uint8, 3)
 	copy(s.pad, data[next:])
-	next += len(s.pad)
+	next += 3
 	s.Capabilities = binary.BigEndian.Uint32(data[next:])
 	next += 4
 	s.Actions = binary.BigEndian.Uint32(data[next:])
 	next += 4
 
-	for next < len(data) {
+	s.Ports = make([]PhyPort, 0)
+	for next+int(NewPhyPort().Len()) <= len(data) {
 		p := NewPhyPort()
 		err = p.UnmarshalBinary(data[next:])
+		s.Ports = append(s.Ports, *p)
 		next += int(p.Len())
 	}
 	return err
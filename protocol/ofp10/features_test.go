@@ -8,7 +8,7 @@ import (
 )
 
 func TestFeaturesReplyMarshalBinary(t *testing.T) {
-	b := "   01 06 00 20 00 00 00 02" + // Header
+	b := "   01 06 00 20 00 00 00 00" + // Header
 		"00 00 00 00 00 00 00 00" + // DPID
 		"00 00 00 00" + // Buffers
 		"00 00 00 00" + // Tables and pad
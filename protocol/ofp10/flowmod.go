@@ -2,8 +2,10 @@ package ofp10
 
 import (
 	"encoding/binary"
+	"strconv"
 
 	"github.com/jonstout/ogo/protocol/ofpxx"
+	"github.com/jonstout/ogo/protocol/util"
 )
 
 // ofp_flow_mod
@@ -58,12 +60,16 @@ func (f *FlowMod) Len() (n uint16) {
 }
 
 func (f *FlowMod) MarshalBinary() (data []byte, err error) {
+	return f.AppendBinary(make([]byte, 0, int(f.Len())))
+}
+
+func (f *FlowMod) AppendBinary(b []byte) (data []byte, err error) {
 	f.Header.Length = f.Len()
-	data, err = f.Header.MarshalBinary()
-	bytes, err := f.Match.MarshalBinary()
-	data = append(data, bytes...)
+	data = ofpxx.AppendHeader(b, &f.Header)
+	data = appendMatch(data, &f.Match)
 
-	bytes = make([]byte, 24)
+	data = util.Extend(data, 24)
+	bytes := data[len(data)-24:]
 	n := 0
 	binary.BigEndian.PutUint64(bytes[n:], f.Cookie)
 	n += 8
@@ -81,20 +87,32 @@ func (f *FlowMod) MarshalBinary() (data []byte, err error) {
 	n += 2
 	binary.BigEndian.PutUint16(bytes[n:], f.Flags)
 	n += 2
-	data = append(data, bytes...)
 
+	// Actions are ignored by deletes and left out of their length.
+	if f.Command == FC_DELETE || f.Command == FC_DELETE_STRICT {
+		return
+	}
 	for _, a := range f.Actions {
-		bytes, err = a.MarshalBinary()
-		data = append(data, bytes...)
+		if data, err = util.AppendBinary(data, a); err != nil {
+			return
+		}
 	}
 	return
 }
 
 func (f *FlowMod) UnmarshalBinary(data []byte) error {
+	if len(data) < 72 {
+		return util.NewTruncatedError("FlowMod message", 72, len(data))
+	}
 	n := 0
 	f.Header.UnmarshalBinary(data[n:])
 	n += int(f.Header.Len())
-	f.Match.UnmarshalBinary(data[n:])
+	if int(f.Header.Length) < 72 || int(f.Header.Length) > len(data) {
+		return util.NewMalformedError("FlowMod message", "length "+strconv.Itoa(int(f.Header.Length))+" does not fit "+strconv.Itoa(len(data))+" bytes")
+	}
+	if err := f.Match.UnmarshalBinary(data[n:]); err != nil {
+		return err
+	}
 	n += int(f.Match.Len())
 	f.Cookie = binary.BigEndian.Uint64(data[n:])
 	n += 8
@@ -113,10 +131,14 @@ func (f *FlowMod) UnmarshalBinary(data []byte) error {
 	f.Flags = binary.BigEndian.Uint16(data[n:])
 	n += 2
 
+	f.Actions = make([]Action, 0)
 	for n < int(f.Header.Length) {
-		a := DecodeAction(data[n:])
+		a, err := DecodeAction(data[n:f.Header.Length])
+		if err != nil {
+			return err
+		}
 		f.Actions = append(f.Actions, a)
-		n += int(a.Len())
+		n += int(a.Header().Length)
 	}
 	return nil
 }
@@ -158,6 +180,7 @@ type FlowRemoved struct {
 func NewFlowRemoved() *FlowRemoved {
 	f := new(FlowRemoved)
 	f.Header = ofpxx.NewOfp10Header()
+	f.Header.Type = Type_FlowRemoved
 	f.Match = *NewMatch()
 	f.pad = make([]byte, 1)
 	f.pad2 = make([]byte, 2)
@@ -176,6 +199,7 @@ func (f *FlowRemoved) MarshalBinary() (data []byte, err error) {
 	bytes := make([]byte, 0)
 	next := 0
 
+	f.Header.Length = f.Len()
 	bytes, err = f.Header.MarshalBinary()
 	copy(data[next:], bytes)
 	next += int(f.Header.Len())
@@ -206,6 +230,9 @@ func (f *FlowRemoved) MarshalBinary() (data []byte, err error) {
 }
 
 func (f *FlowRemoved) UnmarshalBinary(data []byte) error {
+	if len(data) < 88 {
+		return util.NewTruncatedError("FlowRemoved message", 88, len(data))
+	}
 	next := 0
 	var err error
 	err = f.Header.UnmarshalBinary(data[next:])
@@ -219,7 +246,7 @@ func (f *FlowRemoved) UnmarshalBinary(data []byte) error {
 	f.Reason = data[next]
 	next += 1
 	copy(f.pad, data[next:])
-	next += len(f.pad)
+	next += 1
 	f.DurationSec = binary.BigEndian.Uint32(data[next:])
 	next += 4
 	f.DurationNSec = binary.BigEndian.Uint32(data[next:])
@@ -227,7 +254,7 @@ func (f *FlowRemoved) UnmarshalBinary(data []byte) error {
 	f.IdleTimeout = binary.BigEndian.Uint16(data[next:])
 	next += 2
 	copy(f.pad2, data[next:])
-	next += len(f.pad2)
+	next += 2
 	f.PacketCount = binary.BigEndian.Uint64(data[next:])
 	next += 8
 	f.ByteCount = binary.BigEndian.Uint64(data[next:])
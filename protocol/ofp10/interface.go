@@ -30,8 +30,10 @@ type EchoReplyReactor interface {
 	EchoReply(dpid net.HardwareAddr)
 }
 
+// Receives vendor messages as the concrete type registered for
+// their vendor and subtype, or as a *VendorHeader.
 type VendorReactor interface {
-	VendorHeader(dpid net.HardwareAddr, v *VendorHeader)
+	Vendor(dpid net.HardwareAddr, msg VendorMessage)
 }
 
 type FeaturesRequestReactor interface {
@@ -93,3 +95,11 @@ type BarrierRequestReactor interface {
 type BarrierReplyReactor interface {
 	BarrierReply(dpid net.HardwareAddr, msg *ofpxx.Header)
 }
+
+type QueueGetConfigRequestReactor interface {
+	QueueGetConfigRequest(req *QueueGetConfigRequest)
+}
+
+type QueueGetConfigReplyReactor interface {
+	QueueGetConfigReply(dpid net.HardwareAddr, rep *QueueGetConfigReply)
+}
@@ -3,6 +3,8 @@ package ofp10
 import (
 	"encoding/binary"
 	"net"
+
+	"github.com/jonstout/ogo/protocol/util"
 )
 
 // ofp_match 1.0
@@ -42,34 +44,40 @@ func (m *Match) Len() (n uint16) {
 }
 
 func (m *Match) MarshalBinary() (data []byte, err error) {
-	data = make([]byte, int(m.Len()))
-	n := 0
+	return appendMatch(make([]byte, 0, int(m.Len())), m), nil
+}
+
+// Appends the encoding of m to b. Match is embedded in stats
+// requests, which an AppendBinary method would be promoted to.
+func appendMatch(b []byte, m *Match) (data []byte) {
+	n := len(b)
+	data = util.Extend(b, int(m.Len()))
 	binary.BigEndian.PutUint32(data[n:], m.Wildcards)
 	n += 4
 	binary.BigEndian.PutUint16(data[n:], m.InPort)
 	n += 2
-	copy(data[n:], m.DLSrc)
-	n += len(m.DLSrc)
-	copy(data[n:], m.DLDst)
-	n += len(m.DLDst)
+	copy(data[n:n+6], m.DLSrc)
+	n += 6
+	copy(data[n:n+6], m.DLDst)
+	n += 6
 	binary.BigEndian.PutUint16(data[n:], m.DLVLAN)
 	n += 2
 	data[n] = m.DLVLANPcp
 	n += 1
-	copy(data[n:], m.pad)
-	n += len(m.pad)
+	copy(data[n:n+1], m.pad)
+	n += 1
 	binary.BigEndian.PutUint16(data[n:], m.DLType)
 	n += 2
 	data[n] = m.NWTos
 	n += 1
 	data[n] = m.NWProto
 	n += 1
-	copy(data[n:], m.pad2)
-	n += len(m.pad2)
-	copy(data[n:], m.NWSrc)
-	n += len(m.NWSrc)
-	copy(data[n:], m.NWDst)
-	n += len(m.NWDst)
+	copy(data[n:n+2], m.pad2)
+	n += 2
+	copy(data[n:n+4], m.NWSrc)
+	n += 4
+	copy(data[n:n+4], m.NWDst)
+	n += 4
 	binary.BigEndian.PutUint16(data[n:], m.TPSrc)
 	n += 2
 	binary.BigEndian.PutUint16(data[n:], m.TPDst)
@@ -78,6 +86,9 @@ func (m *Match) MarshalBinary() (data []byte, err error) {
 }
 
 func (m *Match) UnmarshalBinary(data []byte) error {
+	if m.DLSrc == nil {
+		*m = *NewMatch()
+	}
 	// Any non-zero value fields should not be wildcarded.
 	if m.InPort != 0 {
 		m.Wildcards = m.Wildcards ^ FW_IN_PORT
@@ -116,6 +127,9 @@ func (m *Match) UnmarshalBinary(data []byte) error {
 		m.Wildcards = m.Wildcards ^ FW_TP_DST
 	}
 
+	if len(data) < 40 {
+		return util.NewTruncatedError("Match", 40, len(data))
+	}
 	n := 0
 	m.Wildcards = binary.BigEndian.Uint32(data[n:])
 	n += 4
@@ -150,6 +164,30 @@ func (m *Match) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
+// Matches packets tagged with vid. Passing VLAN_NONE matches
+// untagged packets instead.
+func (m *Match) SetVLAN(vid uint16) {
+	if vid != VLAN_NONE {
+		vid &= 0x0fff
+	}
+	m.DLVLAN = vid
+	m.Wildcards &^= FW_DL_VLAN
+}
+
+// Matches packets whose outermost VLAN tag has priority pcp.
+func (m *Match) SetVLANPcp(pcp uint8) {
+	m.DLVLANPcp = pcp & 0x07
+	m.Wildcards &^= FW_DL_VLAN_PCP
+}
+
+// Matches packets with no VLAN tag.
+func (m *Match) SetUntagged() {
+	m.SetVLAN(VLAN_NONE)
+}
+
+// The DLVLAN of untagged packets.
+const VLAN_NONE = 0xffff
+
 // ofp_flow_wildcards 1.0
 const (
 	FW_IN_PORT  = 1 << 0
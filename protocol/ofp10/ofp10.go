@@ -9,7 +9,6 @@ package ofp10
 
 import (
 	"encoding/binary"
-	"errors"
 
 	"github.com/jonstout/ogo/protocol/eth"
 	"github.com/jonstout/ogo/protocol/ofpxx"
@@ -114,24 +113,24 @@ func (p *PacketOut) AddAction(act Action) {
 func (p *PacketOut) Len() (n uint16) {
 	n += p.Header.Len()
 	n += 8
-	n += p.ActionsLen
-	for _, a := range p.Actions {
-		n += a.Len()
+	n += actionsLen(p.Actions)
+	if p.Data != nil {
+		n += p.Data.Len()
 	}
-	n += p.Data.Len()
-	//if n < 72 { return 72 }
 	return
 }
 
+// Sets Header.Length and ActionsLen before marshaling.
 func (p *PacketOut) MarshalBinary() (data []byte, err error) {
-	data = make([]byte, int(p.Len()))
-	b := make([]byte, 0)
-	n := 0
+	return p.AppendBinary(make([]byte, 0, int(p.Len())))
+}
 
+func (p *PacketOut) AppendBinary(b []byte) (data []byte, err error) {
 	p.Header.Length = p.Len()
-	b, err = p.Header.MarshalBinary()
-	copy(data[n:], b)
-	n += len(b)
+	p.ActionsLen = actionsLen(p.Actions)
+	data = ofpxx.AppendHeader(b, &p.Header)
+	n := len(data)
+	data = util.Extend(data, 8)
 
 	binary.BigEndian.PutUint32(data[n:], p.BufferId)
 	n += 4
@@ -141,18 +140,21 @@ func (p *PacketOut) MarshalBinary() (data []byte, err error) {
 	n += 2
 
 	for _, a := range p.Actions {
-		b, err = a.MarshalBinary()
-		copy(data[n:], b)
-		n += len(b)
+		if data, err = util.AppendBinary(data, a); err != nil {
+			return
+		}
 	}
 
-	b, err = p.Data.MarshalBinary()
-	copy(data[n:], b)
-	n += len(b)
+	if p.Data != nil {
+		data, err = util.AppendBinary(data, p.Data)
+	}
 	return
 }
 
 func (p *PacketOut) UnmarshalBinary(data []byte) error {
+	if len(data) < 16 {
+		return util.NewTruncatedError("PacketOut message", 16, len(data))
+	}
 	err := p.Header.UnmarshalBinary(data)
 	n := p.Header.Len()
 
@@ -163,13 +165,28 @@ func (p *PacketOut) UnmarshalBinary(data []byte) error {
 	p.ActionsLen = binary.BigEndian.Uint16(data[n:])
 	n += 2
 
-	for n < (n + p.ActionsLen) {
-		a := DecodeAction(data[n:])
+	if len(data) < int(n+p.ActionsLen) {
+		return util.NewTruncatedError("PacketOut message", int(n+p.ActionsLen), len(data))
+	}
+	p.Actions = make([]Action, 0)
+	end := n + p.ActionsLen
+	for n < end {
+		a, err := DecodeAction(data[n:end])
+		if err != nil {
+			return err
+		}
 		p.Actions = append(p.Actions, a)
-		n += a.Len()
+		n += a.Header().Length
 	}
 
-	err = p.Data.UnmarshalBinary(data[n:])
+	// Packet data is only included when the packet is not
+	// buffered on the switch.
+	p.Data = nil
+	if int(n) < len(data) {
+		e := eth.New()
+		err = e.UnmarshalBinary(data[n:])
+		p.Data = e
+	}
 	return err
 }
 
@@ -180,6 +197,7 @@ type PacketIn struct {
 	TotalLen uint16
 	InPort   uint16
 	Reason   uint8
+	pad      uint8
 	Data     eth.Ethernet
 }
 
@@ -195,15 +213,21 @@ func NewPacketIn() *PacketIn {
 
 func (p *PacketIn) Len() (n uint16) {
 	n += p.Header.Len()
-	n += 9
+	n += 10
 	n += p.Data.Len()
 	return
 }
 
 func (p *PacketIn) MarshalBinary() (data []byte, err error) {
-	data, err = p.Header.MarshalBinary()
+	return p.AppendBinary(make([]byte, 0, int(p.Len())))
+}
 
-	b := make([]byte, 9)
+func (p *PacketIn) AppendBinary(buf []byte) (data []byte, err error) {
+	p.Header.Length = p.Len()
+	data = ofpxx.AppendHeader(buf, &p.Header)
+
+	data = util.Extend(data, 10)
+	b := data[len(data)-10:]
 	n := 0
 	binary.BigEndian.PutUint32(b, p.BufferId)
 	n += 4
@@ -213,14 +237,17 @@ func (p *PacketIn) MarshalBinary() (data []byte, err error) {
 	n += 2
 	b[n] = p.Reason
 	n += 1
-	data = append(data, b...)
+	b[n] = p.pad
+	n += 1
 
-	b, err = p.Data.MarshalBinary()
-	data = append(data, b...)
+	data, err = p.Data.AppendBinary(data)
 	return
 }
 
 func (p *PacketIn) UnmarshalBinary(data []byte) error {
+	if len(data) < 18 {
+		return util.NewTruncatedError("PacketIn message", 18, len(data))
+	}
 	err := p.Header.UnmarshalBinary(data)
 	n := p.Header.Len()
 
@@ -232,6 +259,8 @@ func (p *PacketIn) UnmarshalBinary(data []byte) error {
 	n += 2
 	p.Reason = data[n]
 	n += 1
+	p.pad = data[n]
+	n += 1
 
 	err = p.Data.UnmarshalBinary(data[n:])
 	return err
@@ -247,29 +276,51 @@ const (
 type VendorHeader struct {
 	Header ofpxx.Header /*Type OFPT_VENDOR*/
 	Vendor uint32
+	Data   []byte
+}
+
+func (v *VendorHeader) VendorId() uint32 {
+	return v.Vendor
+}
+
+func (v *VendorHeader) GetXid() uint32 {
+	return v.Header.Xid
+}
+
+func (v *VendorHeader) SetXid(xid uint32) {
+	v.Header.Xid = xid
 }
 
 func (v *VendorHeader) Len() (n uint16) {
-	return v.Header.Len() + 4
+	return v.Header.Len() + 4 + uint16(len(v.Data))
 }
 
 func (v *VendorHeader) MarshalBinary() (data []byte, err error) {
+	v.Header.Length = v.Len()
 	data, err = v.Header.MarshalBinary()
 
 	b := make([]byte, 4)
-	binary.BigEndian.PutUint32(data[:4], v.Vendor)
+	binary.BigEndian.PutUint32(b[:4], v.Vendor)
 
 	data = append(data, b...)
+	data = append(data, v.Data...)
 	return
 }
 
 func (v *VendorHeader) UnmarshalBinary(data []byte) error {
-	if len(data) < int(v.Len()) {
-		return errors.New("The []byte the wrong size to unmarshal an " +
-			"VendorHeader message.")
+	if len(data) < int(v.Header.Len()) + 4 {
+		return util.NewTruncatedError("VendorHeader message", int(v.Header.Len()) + 4, len(data))
 	}
 	v.Header.UnmarshalBinary(data)
 	n := int(v.Header.Len())
 	v.Vendor = binary.BigEndian.Uint32(data[n:])
+	n += 4
+
+	end := int(v.Header.Length)
+	if end < n || end > len(data) {
+		end = len(data)
+	}
+	v.Data = make([]byte, end-n)
+	copy(v.Data, data[n:end])
 	return nil
 }
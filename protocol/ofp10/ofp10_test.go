@@ -0,0 +1,313 @@
+package ofp10
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/ofpxx"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+func TestPacketOutParse(t *testing.T) {
+	b := "   01 0d 00 32 00 00 00 02 " + // Header
+		"ff ff ff ff 00 01 00 10 " + // BufferId, InPort, ActionsLen
+		"00 00 00 08 ff fc 01 00 " + // ActionOutput
+		"00 01 00 08 00 0a 00 00 " + // ActionVLANVID
+		"0a b0 0c 0d e0 0f " + // HWDst
+		"00 00 00 00 00 ff " + // HWSrc
+		"a0 f1 " + // Ethertype
+		"01 02 03 04 " // Data
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	msg, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := msg.(*PacketOut)
+	if !ok {
+		t.Fatalf("Got %T, expected *PacketOut.", msg)
+	}
+
+	if p.InPort != 1 {
+		t.Errorf("Got in port %d, expected %d.", p.InPort, 1)
+	} else if len(p.Actions) != 2 {
+		t.Fatalf("Got %d actions, expected %d.", len(p.Actions), 2)
+	}
+
+	if a, ok := p.Actions[0].(*ActionOutput); !ok || a.Port != P_ALL {
+		t.Errorf("Got action %v, expected output to P_ALL.", p.Actions[0])
+	}
+	if a, ok := p.Actions[1].(*ActionVLANVID); !ok || a.VLANVID != 10 {
+		t.Errorf("Got action %v, expected VLAN VID 10.", p.Actions[1])
+	}
+
+	e, ok := p.Data.(*eth.Ethernet)
+	if !ok {
+		t.Fatalf("Got data %T, expected *eth.Ethernet.", p.Data)
+	}
+	dst, _ := net.ParseMAC("0a:b0:0c:0d:e0:0f")
+	if e.Ethertype != 0xa0f1 {
+		t.Errorf("Got ethertype %x, expected %x.", e.Ethertype, 0xa0f1)
+	} else if bytes.Compare(e.HWDst, dst) != 0 {
+		t.Errorf("Got hw-dst %s, expected %s.", e.HWDst, dst)
+	} else if e.Data.Len() != 4 {
+		t.Errorf("Got %d bytes of payload, expected %d.", e.Data.Len(), 4)
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	out := NewPacketOut()
+	out.AddAction(NewActionOutput(P_FLOOD))
+	data, _ := out.MarshalBinary()
+
+	for n := 0; n < len(data); n++ {
+		_, err := Parse(data[:n])
+		if _, ok := err.(*util.TruncatedError); !ok {
+			t.Errorf("Got error %v from %d bytes, expected a *util.TruncatedError.", err, n)
+		}
+	}
+
+	// A header length shorter than the header itself.
+	data[3] = 4
+	if _, err := Parse(data); err == nil {
+		t.Error("Parsed a message whose length is shorter than its header.")
+	} else if _, ok := err.(*util.MalformedError); !ok {
+		t.Errorf("Got error %v, expected a *util.MalformedError.", err)
+	}
+}
+
+// Returns a FlowMod holding one output action whose header length
+// is set to length, padded out with zeros to pad bytes.
+func newFlowModActionLength(length uint16, pad int) []byte {
+	mod := NewFlowMod()
+	mod.AddAction(NewActionOutput(1))
+	data, _ := mod.MarshalBinary()
+	data = append(data, make([]byte, pad-8)...)
+	binary.BigEndian.PutUint16(data[2:4], uint16(len(data)))
+	binary.BigEndian.PutUint16(data[74:76], length)
+	return data
+}
+
+func TestParseActionLength(t *testing.T) {
+	if _, err := Parse(newFlowModActionLength(8, 8)); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		length uint16
+		pad    int
+	}{
+		{0, 8},   // Zero length
+		{4, 8},   // Shorter than an action
+		{12, 16}, // Not a multiple of 8
+		{16, 16}, // Padded past the size of an output action
+		{16, 8},  // Longer than the message
+	} {
+		_, err := Parse(newFlowModActionLength(c.length, c.pad))
+		if _, ok := err.(*util.MalformedError); !ok {
+			t.Errorf("Got error %v from an action of length %d in %d bytes, expected a *util.MalformedError.", err, c.length, c.pad)
+		}
+	}
+}
+
+func TestPacketOutParseBuffered(t *testing.T) {
+	b := "   01 0d 00 18 00 00 00 02 " + // Header
+		"00 00 01 00 ff ff 00 08 " + // BufferId, InPort, ActionsLen
+		"00 00 00 08 00 02 00 00 " // ActionOutput
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	msg, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := msg.(*PacketOut)
+	if p.BufferId != 256 {
+		t.Errorf("Got buffer id %d, expected %d.", p.BufferId, 256)
+	} else if len(p.Actions) != 1 {
+		t.Errorf("Got %d actions, expected %d.", len(p.Actions), 1)
+	} else if p.Data != nil {
+		t.Errorf("Got data %v, expected none.", p.Data)
+	}
+}
+
+func TestPacketOutMarshalBinary(t *testing.T) {
+	p := NewPacketOut()
+	p.InPort = 1
+	p.AddAction(NewActionOutput(P_ALL))
+	p.AddAction(NewActionVLANVID(10))
+	e := eth.New()
+	e.Ethertype = 0xa0f1
+	p.Data = e
+
+	data, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(p.Len()) != len(data) || p.Header.Length != 46 {
+		t.Errorf("Got length %d and header length %d, expected %d.", p.Len(), p.Header.Length, 46)
+	}
+	if p.ActionsLen != 16 {
+		t.Errorf("Got actions length %d, expected %d.", p.ActionsLen, 16)
+	}
+	if err := p.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	r := new(PacketOut)
+	if err := r.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	f := NewFlowMod()
+	f.AddAction(NewActionOutput(1))
+	f.MarshalBinary()
+	if err := f.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Header.Length += 8
+	if err := f.Validate(); err == nil {
+		t.Error("Validated a FlowMod with the wrong header length.")
+	}
+
+	f.MarshalBinary()
+	f.Match.DLVLANPcp = 8
+	if err := f.Validate(); err == nil {
+		t.Error("Validated a FlowMod matching VLAN priority 8.")
+	}
+
+	f = NewFlowMod()
+	f.AddAction(NewActionVLANVID(0x1000))
+	f.MarshalBinary()
+	if err := f.Validate(); err == nil {
+		t.Error("Validated a FlowMod setting VLAN id 0x1000.")
+	}
+
+	v := NewActionVendor(0x2320)
+	v.Data = []byte{1, 2, 3}
+	v.Length = v.Len()
+	if err := ValidateActions([]Action{v}); err == nil {
+		t.Error("Validated a vendor action 11 bytes long.")
+	}
+
+	f = NewFlowMod()
+	f.Command = FC_DELETE
+	f.AddAction(NewActionOutput(1))
+	data, _ := f.MarshalBinary()
+	if len(data) != 72 || f.Header.Length != 72 {
+		t.Errorf("Got delete of %d bytes with header length %d, expected %d.", len(data), f.Header.Length, 72)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	out := NewPacketOut()
+	out.AddAction(NewActionOutput(P_FLOOD))
+	out.AddAction(NewActionVendor(0x2320))
+	mod := NewFlowMod()
+	mod.AddAction(NewActionNWDst(net.IPv4(10, 0, 0, 1)))
+	mod.AddAction(NewActionEnqueue(1, 2))
+	features := NewFeaturesReply()
+	features.Ports = append(features.Ports, *NewPhyPort())
+	reply := NewQueueGetConfigReply()
+	queue := NewPacketQueue(1)
+	queue.AddProperty(NewQueuePropMinRate(100))
+	reply.AddQueue(*queue)
+	flows := NewStatsReply(StatsType_Flow)
+	flows.Body = append(flows.Body, NewFlowStats())
+	hello, _ := ofpxx.NewHello(1)
+
+	seeds := []util.Message{
+		hello, NewEchoRequest(), NewErrorMsg(), NewFeaturesRequest(),
+		features, NewConfigRequest(), NewSetConfig(), NewPacketIn(),
+		NewFlowRemoved(), NewPortStatus(), out, mod, NewPortMod(1),
+		NewStatsRequest(StatsType_Desc), flows,
+		NewQueueGetConfigRequest(1), reply, NewVendorStats(0x2320),
+	}
+	for _, m := range seeds {
+		if data, err := m.MarshalBinary(); err == nil {
+			f.Add(data)
+		}
+	}
+	f.Add(newFlowModActionLength(16, 16))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Parsing must fail with an error rather than panic.
+		msg, err := Parse(data)
+		// Actions must account for every byte of a FlowMod
+		// that parsed.
+		if m, ok := msg.(*FlowMod); ok && err == nil && m.Len() != m.Header.Length {
+			t.Errorf("Parsed a FlowMod of length %d whose actions make it %d.", m.Header.Length, m.Len())
+		}
+	})
+}
+
+func newBenchPacketIn() *PacketIn {
+	p := NewPacketIn()
+	p.InPort = 1
+	p.Data = *eth.New()
+	p.Data.Ethertype = 0xa0f1
+	p.Data.Data = util.NewBuffer(make([]byte, 64))
+	return p
+}
+
+func newBenchFlowMod() *FlowMod {
+	f := NewFlowMod()
+	f.Match.NWDst = net.IPv4(10, 0, 0, 1).To4()
+	f.Match.Wildcards &^= FW_NW_DST_MASK
+	f.AddAction(NewActionDLDst(net.HardwareAddr{0, 0, 0, 0, 0, 1}))
+	f.AddAction(NewActionOutput(1))
+	return f
+}
+
+func BenchmarkPacketInMarshalBinary(b *testing.B) {
+	p := newBenchPacketIn()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.MarshalBinary()
+	}
+}
+
+func BenchmarkPacketInAppendBinary(b *testing.B) {
+	p := newBenchPacketIn()
+	buf := make([]byte, 0, 2048)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ = p.AppendBinary(buf[:0])
+	}
+}
+
+func BenchmarkPacketInParse(b *testing.B) {
+	data, _ := newBenchPacketIn().MarshalBinary()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Parse(data)
+	}
+}
+
+func BenchmarkFlowModMarshalBinary(b *testing.B) {
+	f := newBenchFlowMod()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		f.MarshalBinary()
+	}
+}
+
+func BenchmarkFlowModAppendBinary(b *testing.B) {
+	f := newBenchFlowMod()
+	buf := make([]byte, 0, 2048)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ = f.AppendBinary(buf[:0])
+	}
+}
@@ -1,78 +1,88 @@
 package ofp10
 
 import (
-	"errors"
-	
+	"strconv"
+
 	"github.com/jonstout/ogo/protocol/ofpxx"
 	"github.com/jonstout/ogo/protocol/util"
 )
 
+// Returns the message at the start of b, which must hold all of it.
+// Bytes past the length in its header are ignored.
 func Parse(b []byte) (message util.Message, err error) {
-	switch b[1] {
+	h, err := ofpxx.DecodeHeader(b)
+	if err != nil {
+		return
+	}
+	b = b[:h.Length]
+	switch h.Type {
 	case Type_Hello:
 		message = new(ofpxx.Header)
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	case Type_Error:
 		message = new(ErrorMsg)
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	case Type_EchoRequest:
 		message = new(ofpxx.Header)
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	case Type_EchoReply:
 		message = new(ofpxx.Header)
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	case Type_Vendor:
-		message = new(VendorHeader)
-		message.UnmarshalBinary(b)
+		message, err = decodeVendor(b)
 	 case Type_FeaturesRequest:
 		message = NewFeaturesRequest()
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	 case Type_FeaturesReply:
 		message = NewFeaturesReply()
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	case Type_GetConfigRequest:
 		message = new(ofpxx.Header)
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	case Type_GetConfigReply:
 		message = new(SwitchConfig)
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	case Type_SetConfig:
 		message = NewSetConfig()
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	case Type_PacketIn:
 		message = new(PacketIn)
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	case Type_FlowRemoved:
 		message = NewFlowRemoved()
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	case Type_PortStatus:
 		message = new(PortStatus)
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	case Type_PacketOut:
-		break
+		message = NewPacketOut()
+		err = message.UnmarshalBinary(b)
 	case Type_FlowMod:
 		message = NewFlowMod()
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	case Type_PortMod:
-		break
+		message = NewPortMod(0)
+		err = message.UnmarshalBinary(b)
 	case Type_StatsRequest:
 		message = new(StatsRequest)
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	case Type_StatsReply:
 		message = new(StatsReply)
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	 case Type_BarrierRequest:
 		message = new(ofpxx.Header)
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	 case Type_BarrierReply:
 		message = new(ofpxx.Header)
-		message.UnmarshalBinary(b)
+		err = message.UnmarshalBinary(b)
 	case Type_QueueGetConfigRequest:
-		break
+		message = NewQueueGetConfigRequest(0)
+		err = message.UnmarshalBinary(b)
 	case Type_QueueGetConfigReply:
-		break
+		message = NewQueueGetConfigReply()
+		err = message.UnmarshalBinary(b)
 	default:
-		err = errors.New("An unknown v1.0 packet type was received. Parse function will discard data.")
+		err = util.NewMalformedError("v1.0 message", "unknown type "+strconv.Itoa(int(h.Type)))
 	}
 	return
 }
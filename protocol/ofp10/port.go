@@ -5,6 +5,7 @@ import (
 	"net"
 
 	"github.com/jonstout/ogo/protocol/ofpxx"
+	"github.com/jonstout/ogo/protocol/util"
 )
 
 // ofp_phy_port 1.0
@@ -31,7 +32,7 @@ func NewPhyPort() *PhyPort {
 
 func (p *PhyPort) Len() (n uint16) {
 	n += 2
-	n += uint16(len(p.HWAddr) + len(p.Name))
+	n += ETH_ALEN + MAX_PORT_NAME_LEN
 	n += 24
 	return
 }
@@ -41,10 +42,10 @@ func (p *PhyPort) MarshalBinary() (data []byte, err error) {
 	binary.BigEndian.PutUint16(data, p.PortNo)
 	n := 2
 	
-	copy(data[n:], p.HWAddr)
-	n += len(p.HWAddr)
-	copy(data[n:], p.Name)
-	n += len(p.Name)
+	copy(data[n:n+ETH_ALEN], p.HWAddr)
+	n += ETH_ALEN
+	copy(data[n:n+MAX_PORT_NAME_LEN], p.Name)
+	n += MAX_PORT_NAME_LEN
 	
 	binary.BigEndian.PutUint32(data[n:], p.Config)
 	n += 4
@@ -62,6 +63,9 @@ func (p *PhyPort) MarshalBinary() (data []byte, err error) {
 }
 
 func (p *PhyPort) UnmarshalBinary(data []byte) error {
+	if len(data) < 48 {
+		return util.NewTruncatedError("PhyPort message", 48, len(data))
+	}
 	p.PortNo = binary.BigEndian.Uint16(data)
 	n := 2
 
@@ -89,7 +93,7 @@ func (p *PhyPort) UnmarshalBinary(data []byte) error {
 type PortMod struct {
 	ofpxx.Header
 	PortNo uint16
-	HWAddr []uint8
+	HWAddr net.HardwareAddr
 
 	Config    uint32
 	Mask      uint32
@@ -99,6 +103,7 @@ type PortMod struct {
 
 func NewPortMod(port int) *PortMod {
 	p := new(PortMod)
+	p.Header = ofpxx.NewOfp10Header()
 	p.Header.Type = Type_PortMod
 	p.PortNo = uint16(port)
 	p.HWAddr = make([]byte, ETH_ALEN)
@@ -133,11 +138,15 @@ func (p *PortMod) MarshalBinary() (data []byte, err error) {
 }
 
 func (p *PortMod) UnmarshalBinary(data []byte) error {
+	if len(data) < int(p.Len()) {
+		return util.NewTruncatedError("PortMod message", int(p.Len()), len(data))
+	}
 	err := p.Header.UnmarshalBinary(data)
 	n := int(p.Header.Len())
 
 	p.PortNo = binary.BigEndian.Uint16(data[n:])
-	n += 4
+	n += 2
+	p.HWAddr = make([]byte, ETH_ALEN)
 	copy(p.HWAddr, data[n:])
 	n += len(p.HWAddr)
 	p.Config = binary.BigEndian.Uint32(data[n:])
@@ -146,6 +155,7 @@ func (p *PortMod) UnmarshalBinary(data []byte) error {
 	n += 4
 	p.Advertise = binary.BigEndian.Uint32(data[n:])
 	n += 4
+	p.pad = make([]byte, 4)
 	copy(p.pad, data[n:])
 	n += len(p.pad)
 	return err
@@ -0,0 +1,55 @@
+package ofp10
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestPortModMarshalBinary(t *testing.T) {
+	b := "   01 0f 00 20 00 00 00 01 " + // Header
+		"00 03 00 00 00 00 00 ff " + // PortNo, HWAddr
+		"00 00 00 01 00 00 00 01 " + // Config, Mask
+		"00 00 00 00 00 00 00 00 " // Advertise, pad
+	b = strings.Replace(b, " ", "", -1)
+
+	p := NewPortMod(3)
+	p.Xid = 1
+	p.HWAddr[5] = 0xff
+	p.Config = PC_PORT_DOWN
+	p.Mask = PC_PORT_DOWN
+	data, _ := p.MarshalBinary()
+	d := hex.EncodeToString(data)
+	if (len(b) != len(d)) || (b != d) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", d)
+		t.Errorf("Received length of %d, expected %d", len(d), len(b))
+	}
+}
+
+func TestPortModParse(t *testing.T) {
+	b := "   01 0f 00 20 00 00 00 01 " + // Header
+		"00 03 00 00 00 00 00 ff " + // PortNo, HWAddr
+		"00 00 00 01 00 00 00 01 " + // Config, Mask
+		"00 00 00 20 00 00 00 00 " // Advertise, pad
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	msg, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := msg.(*PortMod)
+	if !ok {
+		t.Fatalf("Got %T, expected *PortMod.", msg)
+	}
+	if p.PortNo != 3 {
+		t.Errorf("Got port %d, expected %d.", p.PortNo, 3)
+	} else if p.HWAddr.String() != "00:00:00:00:00:ff" {
+		t.Errorf("Got hw-addr %s, expected %s.", p.HWAddr, "00:00:00:00:00:ff")
+	} else if p.Config != PC_PORT_DOWN || p.Mask != PC_PORT_DOWN {
+		t.Errorf("Got config %d mask %d, expected %d.", p.Config, p.Mask, PC_PORT_DOWN)
+	} else if p.Advertise != PF_1GB_FD {
+		t.Errorf("Got advertise %d, expected %d.", p.Advertise, PF_1GB_FD)
+	}
+}
@@ -0,0 +1,336 @@
+package ofp10
+
+import (
+	"encoding/binary"
+	"strconv"
+
+	"github.com/jonstout/ogo/protocol/ofpxx"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// All ones is used to indicate all queues in a port (for stats
+// retrieval).
+const Q_ALL = 0xffffffff
+
+// Min rate > 1000 means not configured.
+const Q_MIN_RATE_UNCFG = 0xffff
+
+// ofp_queue_properties 1.0
+const (
+	QT_NONE = iota
+	QT_MIN_RATE
+)
+
+// Queue configuration for a given port. Port must be a valid
+// physical port number (< P_MAX).
+type QueueGetConfigRequest struct {
+	ofpxx.Header
+	Port uint16
+	pad  []uint8 // Size 2
+}
+
+func NewQueueGetConfigRequest(port uint16) *QueueGetConfigRequest {
+	q := new(QueueGetConfigRequest)
+	q.Header = ofpxx.NewOfp10Header()
+	q.Header.Type = Type_QueueGetConfigRequest
+	q.Port = port
+	q.pad = make([]byte, 2)
+	return q
+}
+
+func (q *QueueGetConfigRequest) Len() (n uint16) {
+	return q.Header.Len() + 4
+}
+
+func (q *QueueGetConfigRequest) MarshalBinary() (data []byte, err error) {
+	q.Header.Length = q.Len()
+	data, err = q.Header.MarshalBinary()
+
+	b := make([]byte, 4)
+	n := 0
+	binary.BigEndian.PutUint16(b[n:], q.Port)
+	n += 2
+	copy(b[n:], q.pad)
+	n += 2
+	data = append(data, b...)
+	return
+}
+
+func (q *QueueGetConfigRequest) UnmarshalBinary(data []byte) error {
+	if len(data) < int(q.Len()) {
+		return util.NewTruncatedError("QueueGetConfigRequest message", int(q.Len()), len(data))
+	}
+	err := q.Header.UnmarshalBinary(data)
+	n := int(q.Header.Len())
+
+	q.Port = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	q.pad = make([]byte, 2)
+	copy(q.pad, data[n:])
+	n += len(q.pad)
+	return err
+}
+
+// Queue configuration for a given port.
+type QueueGetConfigReply struct {
+	ofpxx.Header
+	Port   uint16
+	pad    []uint8 // Size 6
+	Queues []PacketQueue
+}
+
+func NewQueueGetConfigReply() *QueueGetConfigReply {
+	q := new(QueueGetConfigReply)
+	q.Header = ofpxx.NewOfp10Header()
+	q.Header.Type = Type_QueueGetConfigReply
+	q.pad = make([]byte, 6)
+	q.Queues = make([]PacketQueue, 0)
+	return q
+}
+
+// Adds queue to the list of queues configured on this port.
+func (q *QueueGetConfigReply) AddQueue(queue PacketQueue) {
+	q.Queues = append(q.Queues, queue)
+}
+
+func (q *QueueGetConfigReply) Len() (n uint16) {
+	n = q.Header.Len() + 8
+	for _, p := range q.Queues {
+		n += p.Len()
+	}
+	return
+}
+
+func (q *QueueGetConfigReply) MarshalBinary() (data []byte, err error) {
+	q.Header.Length = q.Len()
+	data, err = q.Header.MarshalBinary()
+
+	b := make([]byte, 8)
+	n := 0
+	binary.BigEndian.PutUint16(b[n:], q.Port)
+	n += 2
+	copy(b[n:], q.pad)
+	n += 6
+	data = append(data, b...)
+
+	for _, p := range q.Queues {
+		b, err = p.MarshalBinary()
+		if err != nil {
+			return
+		}
+		data = append(data, b...)
+	}
+	return
+}
+
+func (q *QueueGetConfigReply) UnmarshalBinary(data []byte) error {
+	if len(data) < 16 {
+		return util.NewTruncatedError("QueueGetConfigReply message", 16, len(data))
+	}
+	err := q.Header.UnmarshalBinary(data)
+	n := int(q.Header.Len())
+
+	q.Port = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	q.pad = make([]byte, 6)
+	copy(q.pad, data[n:])
+	n += len(q.pad)
+
+	end := int(q.Header.Length)
+	if end > len(data) {
+		end = len(data)
+	}
+	q.Queues = make([]PacketQueue, 0)
+	for n < end {
+		p := NewPacketQueue(0)
+		if err = p.UnmarshalBinary(data[n:end]); err != nil {
+			return err
+		}
+		q.Queues = append(q.Queues, *p)
+		n += int(p.Length)
+	}
+	return err
+}
+
+// Full description for a queue.
+type PacketQueue struct {
+	QueueId    uint32
+	Length     uint16
+	pad        []uint8 // Size 2
+	Properties []QueueProp
+}
+
+func NewPacketQueue(id uint32) *PacketQueue {
+	p := new(PacketQueue)
+	p.QueueId = id
+	p.Length = 8
+	p.pad = make([]byte, 2)
+	p.Properties = make([]QueueProp, 0)
+	return p
+}
+
+func (p *PacketQueue) AddProperty(prop QueueProp) {
+	p.Properties = append(p.Properties, prop)
+	p.Length += prop.Len()
+}
+
+func (p *PacketQueue) Len() (n uint16) {
+	n = 8
+	for _, prop := range p.Properties {
+		n += prop.Len()
+	}
+	return
+}
+
+func (p *PacketQueue) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 8)
+	n := 0
+	binary.BigEndian.PutUint32(data[n:], p.QueueId)
+	n += 4
+	binary.BigEndian.PutUint16(data[n:], p.Len())
+	n += 2
+	copy(data[n:], p.pad)
+	n += 2
+
+	for _, prop := range p.Properties {
+		b, err := prop.MarshalBinary()
+		if err != nil {
+			return data, err
+		}
+		data = append(data, b...)
+	}
+	return
+}
+
+func (p *PacketQueue) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return util.NewTruncatedError("PacketQueue message", 8, len(data))
+	}
+	n := 0
+	p.QueueId = binary.BigEndian.Uint32(data[n:])
+	n += 4
+	p.Length = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	p.pad = make([]byte, 2)
+	copy(p.pad, data[n:])
+	n += 2
+
+	if int(p.Length) < n || int(p.Length) > len(data) {
+		return util.NewMalformedError("PacketQueue message", "length "+strconv.Itoa(int(p.Length))+" does not fit "+strconv.Itoa(len(data))+" bytes")
+	}
+	p.Properties = make([]QueueProp, 0)
+	for n < int(p.Length) {
+		prop, err := DecodeQueueProp(data[n:p.Length])
+		if err != nil {
+			return err
+		}
+		p.Properties = append(p.Properties, prop)
+		n += int(prop.Header().Length)
+	}
+	return nil
+}
+
+type QueueProp interface {
+	Header() *QueuePropHeader
+	util.Message
+}
+
+// Returns the queue property found at the start of data.
+func DecodeQueueProp(data []byte) (QueueProp, error) {
+	h := new(QueuePropHeader)
+	if err := h.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	if h.Length < h.Len() || int(h.Length) > len(data) {
+		return nil, util.NewMalformedError("queue property", "length "+strconv.Itoa(int(h.Length))+" does not fit "+strconv.Itoa(len(data))+" bytes")
+	}
+
+	var p QueueProp
+	switch h.Property {
+	case QT_MIN_RATE:
+		p = NewQueuePropMinRate(0)
+	default:
+		p = h
+	}
+	err := p.UnmarshalBinary(data[:h.Length])
+	return p, err
+}
+
+// Common description for a queue.
+type QueuePropHeader struct {
+	Property uint16
+	Length   uint16
+	pad      []uint8 // Size 4
+}
+
+func (q *QueuePropHeader) Header() *QueuePropHeader {
+	return q
+}
+
+func (q *QueuePropHeader) Len() (n uint16) {
+	return 8
+}
+
+func (q *QueuePropHeader) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 8)
+	binary.BigEndian.PutUint16(data[:2], q.Property)
+	binary.BigEndian.PutUint16(data[2:4], q.Length)
+	copy(data[4:8], q.pad)
+	return
+}
+
+func (q *QueuePropHeader) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return util.NewTruncatedError("QueuePropHeader message", 8, len(data))
+	}
+	q.Property = binary.BigEndian.Uint16(data[:2])
+	q.Length = binary.BigEndian.Uint16(data[2:4])
+	q.pad = make([]byte, 4)
+	copy(q.pad, data[4:8])
+	return nil
+}
+
+// Min-Rate queue property description. Rate is in 1/10 of a
+// percent; a value > 1000 means the rate is not configured.
+type QueuePropMinRate struct {
+	QueuePropHeader
+	Rate uint16
+	pad  []uint8 // Size 6
+}
+
+func NewQueuePropMinRate(rate uint16) *QueuePropMinRate {
+	q := new(QueuePropMinRate)
+	q.Property = QT_MIN_RATE
+	q.Length = 16
+	q.QueuePropHeader.pad = make([]byte, 4)
+	q.Rate = rate
+	q.pad = make([]byte, 6)
+	return q
+}
+
+func (q *QueuePropMinRate) Len() (n uint16) {
+	return q.QueuePropHeader.Len() + 8
+}
+
+func (q *QueuePropMinRate) MarshalBinary() (data []byte, err error) {
+	data, err = q.QueuePropHeader.MarshalBinary()
+
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint16(b[:2], q.Rate)
+	copy(b[2:], q.pad)
+	data = append(data, b...)
+	return
+}
+
+func (q *QueuePropMinRate) UnmarshalBinary(data []byte) error {
+	if len(data) < int(q.Len()) {
+		return util.NewTruncatedError("QueuePropMinRate message", int(q.Len()), len(data))
+	}
+	err := q.QueuePropHeader.UnmarshalBinary(data)
+	n := int(q.QueuePropHeader.Len())
+	q.Rate = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	q.pad = make([]byte, 6)
+	copy(q.pad, data[n:])
+	return err
+}
@@ -0,0 +1,64 @@
+package ofp10
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestQueueGetConfigRequestMarshalBinary(t *testing.T) {
+	b := "   01 14 00 0c 00 00 00 01 " + // Header
+		"00 03 00 00 " // Port, pad
+	b = strings.Replace(b, " ", "", -1)
+
+	q := NewQueueGetConfigRequest(3)
+	q.Xid = 1
+	data, _ := q.MarshalBinary()
+	d := hex.EncodeToString(data)
+	if (len(b) != len(d)) || (b != d) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", d)
+		t.Errorf("Received length of %d, expected %d", len(d), len(b))
+	}
+}
+
+func TestQueueGetConfigReplyUnmarshalBinary(t *testing.T) {
+	b := "   01 15 00 30 00 00 00 01 " + // Header
+		"00 03 00 00 00 00 00 00 " + // Port, pad
+		"00 00 00 01 00 18 00 00 " + // QueueId, Length, pad
+		"00 01 00 10 00 00 00 00 " + // Property header
+		"01 f4 00 00 00 00 00 00 " + // Rate, pad
+		"00 00 00 02 00 08 00 00 " // QueueId, Length, pad
+	b = strings.Replace(b, " ", "", -1)
+	bytes, _ := hex.DecodeString(b)
+
+	q := NewQueueGetConfigReply()
+	if err := q.UnmarshalBinary(bytes); err != nil {
+		t.Fatal(err)
+	}
+
+	if int(q.Len()) != len(bytes) {
+		t.Errorf("Got length of %d, expected %d.", q.Len(), len(bytes))
+	} else if q.Port != 3 {
+		t.Errorf("Got port %d, expected %d.", q.Port, 3)
+	} else if len(q.Queues) != 2 {
+		t.Fatalf("Got %d queues, expected %d.", len(q.Queues), 2)
+	} else if q.Queues[0].QueueId != 1 || q.Queues[1].QueueId != 2 {
+		t.Errorf("Got queue ids %d and %d, expected 1 and 2.",
+			q.Queues[0].QueueId, q.Queues[1].QueueId)
+	}
+
+	r, ok := q.Queues[0].Properties[0].(*QueuePropMinRate)
+	if !ok {
+		t.Fatalf("Got wrong QueueProp type.")
+	} else if r.Rate != 500 {
+		t.Errorf("Got rate %d, expected %d.", r.Rate, 500)
+	}
+
+	data, _ := q.MarshalBinary()
+	if d := hex.EncodeToString(data); d != b {
+		t.Log("Exp:", b)
+		t.Log("Rec:", d)
+		t.Error("QueueGetConfigReply did not survive a round trip.")
+	}
+}
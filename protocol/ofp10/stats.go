@@ -2,6 +2,8 @@ package ofp10
 
 import (
 	"encoding/binary"
+	"errors"
+	"strconv"
 
 	"github.com/jonstout/ogo/protocol/ofpxx"
 	"github.com/jonstout/ogo/protocol/util"
@@ -15,11 +17,36 @@ type StatsRequest struct {
 	Body   util.Message
 }
 
+// Returns a request for stats of type t. Requests for
+// StatsType_Desc and StatsType_Table have no Body.
+func NewStatsRequest(t uint16) *StatsRequest {
+	s := new(StatsRequest)
+	s.Header = ofpxx.NewOfp10Header()
+	s.Header.Type = Type_StatsRequest
+	s.Type = t
+	switch t {
+	case StatsType_Flow:
+		s.Body = NewFlowStatsRequest()
+	case StatsType_Aggregate:
+		s.Body = NewAggregateStatsRequest()
+	case StatsType_Port:
+		s.Body = NewPortStatsRequest()
+	case StatsType_Queue:
+		s.Body = NewQueueStatsRequest()
+	}
+	return s
+}
+
 func (s *StatsRequest) Len() (n uint16) {
-	return s.Header.Len() + 4 + s.Body.Len()
+	n = s.Header.Len() + 4
+	if s.Body != nil {
+		n += s.Body.Len()
+	}
+	return
 }
 
 func (s *StatsRequest) MarshalBinary() (data []byte, err error) {
+	s.Header.Length = s.Len()
 	data, err = s.Header.MarshalBinary()
 
 	b := make([]byte, 4)
@@ -30,12 +57,17 @@ func (s *StatsRequest) MarshalBinary() (data []byte, err error) {
 	n += 2
 	data = append(data, b...)
 
-	b, err = s.Body.MarshalBinary()
-	data = append(data, b...)
+	if s.Body != nil {
+		b, err = s.Body.MarshalBinary()
+		data = append(data, b...)
+	}
 	return
 }
 
 func (s *StatsRequest) UnmarshalBinary(data []byte) error {
+	if len(data) < 12 {
+		return util.NewTruncatedError("StatsRequest message", 12, len(data))
+	}
 	err := s.Header.UnmarshalBinary(data)
 	n := s.Header.Len()
 
@@ -44,46 +76,76 @@ func (s *StatsRequest) UnmarshalBinary(data []byte) error {
 	s.Flags = binary.BigEndian.Uint16(data[n:])
 	n += 2
 
-	var req util.Message
+	s.Body = nil
 	switch s.Type {
 	case StatsType_Aggregate:
-		req = s.Body.(*AggregateStatsRequest)
-		err = req.UnmarshalBinary(data[n:])
-	case StatsType_Desc:
-		break
+		s.Body = NewAggregateStatsRequest()
 	case StatsType_Flow:
-		req = s.Body.(*FlowStatsRequest)
-		err = req.UnmarshalBinary(data[n:])
+		s.Body = NewFlowStatsRequest()
 	case StatsType_Port:
-		req = s.Body.(*PortStatsRequest)
-		err = req.UnmarshalBinary(data[n:])
-	case StatsType_Table:
-		break
+		s.Body = NewPortStatsRequest()
 	case StatsType_Queue:
-		req = s.Body.(*QueueStatsRequest)
-		err = req.UnmarshalBinary(data[n:])
+		s.Body = NewQueueStatsRequest()
 	case StatsType_Vendor:
-		break
+		s.Body, err = decodeVendorStats(data[n:])
+		return err
+	}
+	if s.Body != nil {
+		if len(data) < int(n+s.Body.Len()) {
+			return util.NewTruncatedError("StatsRequest body", int(n+s.Body.Len()), len(data))
+		}
+		err = s.Body.UnmarshalBinary(data[n:])
 	}
 	return err
 }
 
-// _stats_reply 1.0
+// ofp_stats_reply 1.0. The body of a reply is a list: it holds a
+// single DescStats or AggregateStats, one FlowStats, TableStats,
+// PortStats or QueueStats per entry, or a single vendor body.
 type StatsReply struct {
 	ofpxx.Header
 	Type   uint16
 	Flags  uint16
-	Body   util.Message
+	Body   []util.Message
+}
+
+func NewStatsReply(t uint16) *StatsReply {
+	s := new(StatsReply)
+	s.Header = ofpxx.NewOfp10Header()
+	s.Header.Type = Type_StatsReply
+	s.Type = t
+	s.Body = make([]util.Message, 0)
+	return s
 }
 
 func (s *StatsReply) Len() (n uint16) {
-	n = s.Header.Len()
+	return uint16(s.size())
+}
+
+// Returns the length of s without the limit of a uint16.
+func (s *StatsReply) size() (n int) {
+	n = int(s.Header.Len())
 	n += 4
-	n += uint16(s.Body.Len())
+	for _, b := range s.Body {
+		n += int(b.Len())
+	}
 	return
 }
 
+// Returns an error if the body of s is too long for a single
+// message.
+func (s *StatsReply) checkSize() error {
+	if n := s.size(); n > 0xffff {
+		return util.NewMalformedError("StatsReply message", "length "+strconv.Itoa(n)+" does not fit in a single message")
+	}
+	return nil
+}
+
 func (s *StatsReply) MarshalBinary() (data []byte, err error) {
+	if err = s.checkSize(); err != nil {
+		return
+	}
+	s.Header.Length = s.Len()
 	data, err = s.Header.MarshalBinary()
 
 	b := make([]byte, 4)
@@ -94,45 +156,132 @@ func (s *StatsReply) MarshalBinary() (data []byte, err error) {
 	n += 2
 	data = append(data, b...)
 
-	b, err = s.Body.MarshalBinary()
-	data = append(data, b...)
+	for _, m := range s.Body {
+		if b, err = m.MarshalBinary(); err != nil {
+			return
+		}
+		data = append(data, b...)
+	}
 	return
 }
 
 func (s *StatsReply) UnmarshalBinary(data []byte) error {
+	if len(data) < 12 {
+		return util.NewTruncatedError("StatsReply message", 12, len(data))
+	}
 	err := s.Header.UnmarshalBinary(data)
-	n := s.Header.Len()
+	n := int(s.Header.Len())
 
 	s.Type = binary.BigEndian.Uint16(data[n:])
 	n += 2
 	s.Flags = binary.BigEndian.Uint16(data[n:])
 	n += 2
 
-	var req util.Message
-	switch s.Type {
-	case StatsType_Aggregate:
-		req = s.Body.(*AggregateStats)
-	case StatsType_Desc:
-		req = s.Body.(*DescStats)
-	case StatsType_Flow:
-		// Array
-		req = s.Body.(*FlowStats)
-	case StatsType_Port:
-		req = s.Body.(*PortStats)
-	case StatsType_Table:
-		// Array
-		req = s.Body.(*TableStats)
-	case StatsType_Queue:
-		// Array
-		req = s.Body.(*QueueStats)
-	case StatsType_Vendor:
-		// Array of Group Stats
-		break
+	end := int(s.Header.Length)
+	if end < n || end > len(data) {
+		end = len(data)
+	}
+
+	s.Body = make([]util.Message, 0)
+	if s.Type == StatsType_Vendor {
+		b, err := decodeVendorStats(data[n:end])
+		s.Body = append(s.Body, b)
+		return err
+	}
+	for n < end {
+		var b util.Message
+		switch s.Type {
+		case StatsType_Desc:
+			b = NewDescStats()
+		case StatsType_Aggregate:
+			b = NewAggregateStats()
+		case StatsType_Flow:
+			b = NewFlowStats()
+		case StatsType_Table:
+			b = NewTableStats()
+		case StatsType_Port:
+			b = NewPortStats()
+		case StatsType_Queue:
+			b = NewQueueStats()
+		default:
+			return util.NewMalformedError("StatsReply message", "unknown type "+strconv.Itoa(int(s.Type)))
+		}
+		if err = b.UnmarshalBinary(data[n:end]); err != nil {
+			return err
+		}
+		s.Body = append(s.Body, b)
+		n += int(b.Len())
 	}
-	err = req.UnmarshalBinary(data[n:])
 	return err
 }
 
+// Appends the body of r, the next reply of a multipart stats
+// reply, to s. The merged reply takes the flags of r, so it is
+// complete once r is the final reply. Returns an error and leaves
+// s unchanged if r is of another type, or if the merged reply
+// would be too long for a single message.
+func (s *StatsReply) Append(r *StatsReply) error {
+	if r.Type != s.Type {
+		return errors.New("Cannot append a StatsReply of a different type.")
+	}
+	if n := s.size() + r.size() - int(r.Header.Len()) - 4; n > 0xffff {
+		return util.NewMalformedError("StatsReply message", "length "+strconv.Itoa(n)+" does not fit in a single message")
+	}
+	s.Body = append(s.Body, r.Body...)
+	s.Flags = r.Flags
+	return nil
+}
+
+// Returns the flows of a StatsType_Flow reply.
+func (s *StatsReply) Flows() []FlowStats {
+	flows := make([]FlowStats, 0, len(s.Body))
+	for _, b := range s.Body {
+		if f, ok := b.(*FlowStats); ok {
+			flows = append(flows, *f)
+		}
+	}
+	return flows
+}
+
+// Returns the tables of a StatsType_Table reply.
+func (s *StatsReply) Tables() []TableStats {
+	tables := make([]TableStats, 0, len(s.Body))
+	for _, b := range s.Body {
+		if t, ok := b.(*TableStats); ok {
+			tables = append(tables, *t)
+		}
+	}
+	return tables
+}
+
+// Returns the ports of a StatsType_Port reply.
+func (s *StatsReply) Ports() []PortStats {
+	ports := make([]PortStats, 0, len(s.Body))
+	for _, b := range s.Body {
+		if p, ok := b.(*PortStats); ok {
+			ports = append(ports, *p)
+		}
+	}
+	return ports
+}
+
+// Returns the queues of a StatsType_Queue reply.
+func (s *StatsReply) Queues() []QueueStats {
+	queues := make([]QueueStats, 0, len(s.Body))
+	for _, b := range s.Body {
+		if q, ok := b.(*QueueStats); ok {
+			queues = append(queues, *q)
+		}
+	}
+	return queues
+}
+
+// ofp_stats_reply_flags 1.0
+const (
+	// More replies to follow.
+	SF_REPLY_MORE = 1 << 0
+)
+
 // _stats_types
 const (
 	/* Description of this OpenFlow switch.
@@ -205,6 +354,9 @@ func (s *DescStats) MarshalBinary() (data []byte, err error) {
 }
 
 func (s *DescStats) UnmarshalBinary(data []byte) error {
+	if len(data) < int(s.Len()) {
+		return util.NewTruncatedError("DescStats message", int(s.Len()), len(data))
+	}
 	n := 0
 	copy(s.MfrDesc, data[n:])
 	n += len(s.MfrDesc)
@@ -251,13 +403,16 @@ func (s *FlowStatsRequest) MarshalBinary() (data []byte, err error) {
 	n += 1
 	b[n] = s.pad
 	n += 1
-	binary.BigEndian.PutUint16(data[n:], s.OutPort)
+	binary.BigEndian.PutUint16(b[n:], s.OutPort)
 	n += 2
 	data = append(data, b...)
 	return
 }
 
 func (s *FlowStatsRequest) UnmarshalBinary(data []byte) error {
+	if len(data) < 44 {
+		return util.NewTruncatedError("FlowStatsRequest message", 44, len(data))
+	}
 	err := s.Match.UnmarshalBinary(data)
 	n := s.Match.Len()
 
@@ -304,7 +459,8 @@ func (s *FlowStats) Len() (n uint16) {
 }
 
 func (s *FlowStats) MarshalBinary() (data []byte, err error) {
-	data = make([]byte, int(s.Len()))
+	s.Length = s.Len()
+	data = make([]byte, 88)
 	n := 0
 
 	binary.BigEndian.PutUint16(data[n:], s.Length)
@@ -314,7 +470,7 @@ func (s *FlowStats) MarshalBinary() (data []byte, err error) {
 	data[n] = s.pad
 	n += 1
 	b, err := s.Match.MarshalBinary()
-	data = append(data, b...)
+	copy(data[n:], b)
 	n += len(b)
 	binary.BigEndian.PutUint32(data[n:], s.DurationSec)
 	n += 4
@@ -338,14 +494,19 @@ func (s *FlowStats) MarshalBinary() (data []byte, err error) {
 	for _, a := range s.Actions {
 		b, err = a.MarshalBinary()
 		data = append(data, b...)
-		n += len(b)
 	}
 	return
 }
 
 func (s *FlowStats) UnmarshalBinary(data []byte) error {
+	if len(data) < 88 {
+		return util.NewTruncatedError("FlowStats message", 88, len(data))
+	}
 	n := 0
 	s.Length = binary.BigEndian.Uint16(data[n:])
+	if s.Length < 88 || int(s.Length) > len(data) {
+		return util.NewMalformedError("FlowStats message", "length "+strconv.Itoa(int(s.Length))+" does not fit "+strconv.Itoa(len(data))+" bytes")
+	}
 	n += 2
 	s.TableId = data[n]
 	n += 1
@@ -371,39 +532,14 @@ func (s *FlowStats) UnmarshalBinary(data []byte) error {
 	n += 8
 	s.ByteCount = binary.BigEndian.Uint64(data[n:])
 	n += 8
+	s.Actions = make([]Action, 0)
 	for n < int(s.Length) {
-		t := binary.BigEndian.Uint16(data[n:])
-		var a Action
-		switch t {
-		case ActionType_Output:
-			a = NewActionOutput(0)
-		case ActionType_SetVLAN_VID:
-			a = NewActionVLANVID(0xffff)
-		case ActionType_SetVLAN_PCP:
-			a = NewActionVLANPCP(0)
-		case ActionType_StripVLAN:
-			a = NewActionStripVLAN()
-		case ActionType_SetDLSrc:
-			a = NewActionDLSrc(make([]byte, 6))
-		case ActionType_SetDLDst:
-			a = NewActionDLDst(make([]byte, 6))
-		case ActionType_SetNWSrc:
-			a = NewActionNWSrc(make([]byte, 4))
-		case ActionType_SetNWDst:
-			a = NewActionNWDst(make([]byte, 4))
-		case ActionType_SetNWTOS:
-			a = NewActionNWTOS(0)
-		case ActionType_SetTPSrc:
-			a = NewActionTPSrc(0)
-		case ActionType_SetTPDst:
-			a = NewActionTPDst(0)
-		case ActionType_Enqueue:
-			a = NewActionEnqueue(0, 0)
-		case ActionType_Vendor:
-			a = NewActionVendor(0)
+		a, err := DecodeAction(data[n:s.Length])
+		if err != nil {
+			return err
 		}
 		s.Actions = append(s.Actions, a)
-		n += int(a.Len())
+		n += int(a.Header().Length)
 	}
 	return err
 }
@@ -417,7 +553,9 @@ type AggregateStatsRequest struct {
 }
 
 func NewAggregateStatsRequest() *AggregateStatsRequest {
-	return new(AggregateStatsRequest)
+	s := new(AggregateStatsRequest)
+	s.Match = *NewMatch()
+	return s
 }
 
 func (s *AggregateStatsRequest) Len() (n uint16) {
@@ -433,13 +571,16 @@ func (s *AggregateStatsRequest) MarshalBinary() (data []byte, err error) {
 	n += 1
 	b[n] = s.pad
 	n += 1
-	binary.BigEndian.PutUint16(data[n:], s.OutPort)
+	binary.BigEndian.PutUint16(b[n:], s.OutPort)
 	n += 2
 	data = append(data, b...)
 	return
 }
 
 func (s *AggregateStatsRequest) UnmarshalBinary(data []byte) error {
+	if len(data) < 44 {
+		return util.NewTruncatedError("AggregateStatsRequest message", 44, len(data))
+	}
 	n := 0
 	s.Match.UnmarshalBinary(data[n:])
 	n += int(s.Match.Len())
@@ -485,6 +626,9 @@ func (s *AggregateStats) MarshalBinary() (data []byte, err error) {
 }
 
 func (s *AggregateStats) UnmarshalBinary(data []byte) error {
+	if len(data) < int(s.Len()) {
+		return util.NewTruncatedError("AggregateStats message", int(s.Len()), len(data))
+	}
 	n := 0
 	s.PacketCount = binary.BigEndian.Uint64(data[n:])
 	n += 8
@@ -542,6 +686,9 @@ func (s *TableStats) MarshalBinary() (data []byte, err error) {
 }
 
 func (s *TableStats) UnmarshalBinary(data []byte) error {
+	if len(data) < int(s.Len()) {
+		return util.NewTruncatedError("TableStats message", int(s.Len()), len(data))
+	}
 	n := 0
 	s.TableId = data[0]
 	n += 1
@@ -593,6 +740,9 @@ func (s *PortStatsRequest) MarshalBinary() (data []byte, err error) {
 }
 
 func (s *PortStatsRequest) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return util.NewTruncatedError("PortStatsRequest message", 8, len(data))
+	}
 	n := 0
 	s.PortNo = binary.BigEndian.Uint16(data[n:])
 	n += 2
@@ -664,6 +814,9 @@ func (s *PortStats) MarshalBinary() (data []byte, err error) {
 }
 
 func (s *PortStats) UnmarshalBinary(data []byte) error {
+	if len(data) < int(s.Len()) {
+		return util.NewTruncatedError("PortStats message", int(s.Len()), len(data))
+	}
 	n := 0
 	s.PortNo = binary.BigEndian.Uint16(data[n:])
 	n += 2
@@ -726,6 +879,9 @@ func (s *QueueStatsRequest) MarshalBinary() (data []byte, err error) {
 }
 
 func (s *QueueStatsRequest) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return util.NewTruncatedError("QueueStatsRequest message", 8, len(data))
+	}
 	n := 0
 	s.PortNo = binary.BigEndian.Uint16(data[n:])
 	n += 2
@@ -745,6 +901,12 @@ type QueueStats struct {
 	TxErrors  uint64
 }
 
+func NewQueueStats() *QueueStats {
+	s := new(QueueStats)
+	s.pad = make([]byte, 2)
+	return s
+}
+
 func (s *QueueStats) Len() (n uint16) {
 	return 32
 }
@@ -769,6 +931,9 @@ func (s *QueueStats) MarshalBinary() (data []byte, err error) {
 }
 
 func (s *QueueStats) UnmarshalBinary(data []byte) error {
+	if len(data) < int(s.Len()) {
+		return util.NewTruncatedError("QueueStats message", int(s.Len()), len(data))
+	}
 	n := 0
 	s.PortNo = binary.BigEndian.Uint16(data[n:])
 	n += 2
@@ -796,7 +961,9 @@ type PortStatus struct {
 func NewPortStatus() *PortStatus {
 	p := new(PortStatus)
 	p.Header = ofpxx.NewOfp10Header()
+	p.Header.Type = Type_PortStatus
 	p.pad = make([]byte, 7)
+	p.Desc = *NewPhyPort()
 	return p
 }
 
@@ -824,13 +991,16 @@ func (s *PortStatus) MarshalBinary() (data []byte, err error) {
 }
 
 func (s *PortStatus) UnmarshalBinary(data []byte) error {
+	if len(data) < 16 {
+		return util.NewTruncatedError("PortStatus message", 16, len(data))
+	}
 	err := s.Header.UnmarshalBinary(data)
 	n := int(s.Header.Len())
 	
 	s.Reason = data[n]
 	n += 1
 	copy(s.pad, data[n:])
-	n += len(s.pad)
+	n += 7
 
 	err = s.Desc.UnmarshalBinary(data[n:])
 	return err
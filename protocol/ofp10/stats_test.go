@@ -0,0 +1,122 @@
+package ofp10
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestPortStatsReplyParse(t *testing.T) {
+	stats := strings.Repeat("00 ", 88)
+	b := "   01 11 00 dc 00 00 00 01 " + // Header
+		"00 04 00 01 " + // Type, Flags
+		"00 01 00 00 00 00 00 00 " + // PortNo, pad
+		"00 00 00 00 00 00 00 07 " + stats + // RxPackets, ...
+		"00 02 00 00 00 00 00 00 " + // PortNo, pad
+		"00 00 00 00 00 00 00 09 " + stats // RxPackets, ...
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	msg, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := msg.(*StatsReply)
+	if !ok {
+		t.Fatalf("Got %T, expected *StatsReply.", msg)
+	}
+	if s.Flags&SF_REPLY_MORE == 0 {
+		t.Error("Expected SF_REPLY_MORE to be set.")
+	}
+	ports := s.Ports()
+	if len(ports) != 2 {
+		t.Fatalf("Got %d ports, expected 2.", len(ports))
+	}
+	if ports[0].PortNo != 1 || ports[0].RxPackets != 7 ||
+		ports[1].PortNo != 2 || ports[1].RxPackets != 9 {
+		t.Errorf("Got ports %v, expected 1 and 2.", ports)
+	}
+
+	d, _ := s.MarshalBinary()
+	if hex.EncodeToString(d) != b {
+		t.Log("Exp:", b)
+		t.Log("Rec:", hex.EncodeToString(d))
+		t.Error("StatsReply did not marshal back to its original bytes.")
+	}
+}
+
+func TestStatsReplyAppend(t *testing.T) {
+	first := NewStatsReply(StatsType_Flow)
+	first.Flags = SF_REPLY_MORE
+	f := NewFlowStats()
+	f.Priority = 1
+	f.Actions = append(f.Actions, NewActionOutput(1))
+	first.Body = append(first.Body, f)
+
+	last := NewStatsReply(StatsType_Flow)
+	last.Xid = first.Xid
+	f = NewFlowStats()
+	f.Priority = 2
+	last.Body = append(last.Body, f)
+
+	// Round trip both fragments through the wire format.
+	for _, r := range []*StatsReply{first, last} {
+		data, _ := r.MarshalBinary()
+		if err := r.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := first.Append(last); err != nil {
+		t.Fatal(err)
+	}
+	if first.Flags&SF_REPLY_MORE != 0 {
+		t.Error("Expected the merged reply to be final.")
+	}
+	flows := first.Flows()
+	if len(flows) != 2 || flows[0].Priority != 1 || flows[1].Priority != 2 {
+		t.Fatalf("Got flows %v, expected priorities 1 and 2.", flows)
+	}
+	if len(flows[0].Actions) != 1 {
+		t.Errorf("Got %d actions, expected 1.", len(flows[0].Actions))
+	}
+
+	if err := first.Append(NewStatsReply(StatsType_Port)); err == nil {
+		t.Error("Expected an error appending a port stats reply.")
+	}
+}
+
+func TestStatsReplyTooLong(t *testing.T) {
+	s := NewStatsReply(StatsType_Flow)
+	// 745 flows of 88 bytes each fill more than a single message.
+	for i := 0; i < 745; i++ {
+		s.Body = append(s.Body, NewFlowStats())
+	}
+	if _, err := s.MarshalBinary(); err == nil {
+		t.Error("Expected an error marshaling a reply longer than a message.")
+	}
+	if err := s.Validate(); err == nil {
+		t.Error("Expected an error validating a reply longer than a message.")
+	}
+}
+
+func TestStatsReplyAppendTooLong(t *testing.T) {
+	s := NewStatsReply(StatsType_Flow)
+	s.Flags = SF_REPLY_MORE
+	// 744 flows of 88 bytes each just fit in a single message.
+	for i := 0; i < 744; i++ {
+		s.Body = append(s.Body, NewFlowStats())
+	}
+	r := NewStatsReply(StatsType_Flow)
+	r.Body = append(r.Body, NewFlowStats())
+
+	if err := s.Append(r); err == nil {
+		t.Error("Expected an error appending past the length of a message.")
+	}
+	if len(s.Body) != 744 || s.Flags != SF_REPLY_MORE {
+		t.Errorf("Got %d flows and flags %d, expected the reply unchanged.", len(s.Body), s.Flags)
+	}
+	if _, err := s.MarshalBinary(); err != nil {
+		t.Error(err)
+	}
+}
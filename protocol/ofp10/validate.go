@@ -0,0 +1,314 @@
+package ofp10
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/jonstout/ogo/protocol/ofpxx"
+)
+
+// Implemented by messages that can check themselves for the
+// errors a switch would reject them for: lengths that disagree
+// with the marshaled size, non-zero padding, misaligned actions
+// and fields out of range.
+//
+// MarshalBinary fills in Header.Length, ActionsLen and the other
+// length fields, so messages built in code are checked after they
+// have been marshaled, and received messages after they have been
+// unmarshaled.
+type Validator interface {
+	Validate() error
+}
+
+// Checks the version, type and length of h, the header of a
+// message of size n.
+func validateHeader(h *ofpxx.Header, n uint16, types ...uint8) error {
+	if h.Version != VERSION {
+		return errors.New("Header version " + strconv.Itoa(int(h.Version)) +
+			" is not OpenFlow 1.0.")
+	}
+	ok := false
+	for _, t := range types {
+		ok = ok || h.Type == t
+	}
+	if !ok {
+		return errors.New("Header type " + strconv.Itoa(int(h.Type)) +
+			" does not match the message.")
+	}
+	if h.Length != n {
+		return errors.New("Header length " + strconv.Itoa(int(h.Length)) +
+			" does not match the message length " + strconv.Itoa(int(n)) + ".")
+	}
+	return nil
+}
+
+func validatePad(pad []uint8) error {
+	for _, b := range pad {
+		if b != 0 {
+			return errors.New("Padding is not zero.")
+		}
+	}
+	return nil
+}
+
+// Returns true if port names a physical port or the local port.
+func isSwitchPort(port uint16) bool {
+	return (port > 0 && port <= P_MAX) || port == P_LOCAL
+}
+
+// Returns the marshaled length of actions.
+func actionsLen(actions []Action) (n uint16) {
+	for _, a := range actions {
+		n += a.Len()
+	}
+	return
+}
+
+// Checks that each action's length matches its body and is a
+// multiple of 8, and that its fields are in range. Actions that
+// implement Validator, such as vendor actions, also validate
+// themselves.
+func ValidateActions(actions []Action) error {
+	for i, a := range actions {
+		if err := validateAction(a); err != nil {
+			return errors.New("Action " + strconv.Itoa(i) + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+func validateAction(a Action) error {
+	if a.Header().Length != a.Len() {
+		return errors.New("Length " + strconv.Itoa(int(a.Header().Length)) +
+			" does not match the action length " + strconv.Itoa(int(a.Len())) + ".")
+	}
+	if a.Len()%8 != 0 {
+		return errors.New("Length is not a multiple of 8.")
+	}
+
+	switch act := a.(type) {
+	case *ActionOutput:
+		if act.Port == P_NONE || (act.Port > P_MAX && act.Port < P_IN_PORT) || act.Port == 0 {
+			return errors.New("Output port " + strconv.Itoa(int(act.Port)) + " is not valid.")
+		}
+	case *ActionEnqueue:
+		if !isSwitchPort(act.Port) && act.Port != P_IN_PORT {
+			return errors.New("Enqueue port " + strconv.Itoa(int(act.Port)) + " is not valid.")
+		}
+		return validatePad(act.pad)
+	case *ActionVLANVID:
+		if act.VLANVID > 0x0fff {
+			return errors.New("VLAN id is larger than 12 bits.")
+		}
+		return validatePad(act.pad)
+	case *ActionVLANPCP:
+		if act.VLANPCP > 7 {
+			return errors.New("VLAN priority is larger than 3 bits.")
+		}
+		return validatePad(act.pad)
+	case *ActionStripVLAN:
+		return validatePad(act.pad)
+	case *ActionDLAddr:
+		if len(act.DLAddr) != ETH_ALEN {
+			return errors.New("Hardware address is not 6 bytes.")
+		}
+		return validatePad(act.pad)
+	case *ActionNWAddr:
+		if act.NWAddr.To4() == nil {
+			return errors.New("Network address is not IPv4.")
+		}
+	case *ActionNWTOS:
+		if act.NWTOS&0x03 != 0 {
+			return errors.New("Type of service sets the two low ECN bits.")
+		}
+		return validatePad(act.pad)
+	case *ActionTPPort:
+		return validatePad(act.pad)
+	case Validator:
+		return act.Validate()
+	}
+	return nil
+}
+
+// Checks that the fields of m are in range and its addresses have
+// the lengths they are marshaled with.
+func (m *Match) Validate() error {
+	if m.DLVLAN > 0x0fff && m.DLVLAN != VLAN_NONE {
+		return errors.New("Match VLAN id is larger than 12 bits.")
+	}
+	if m.DLVLANPcp > 7 {
+		return errors.New("Match VLAN priority is larger than 3 bits.")
+	}
+	if m.NWTos&0x03 != 0 {
+		return errors.New("Match type of service sets the two low ECN bits.")
+	}
+	if len(m.DLSrc) != ETH_ALEN || len(m.DLDst) != ETH_ALEN {
+		return errors.New("Match hardware addresses are not 6 bytes.")
+	}
+	if len(m.NWSrc) != 4 || len(m.NWDst) != 4 {
+		return errors.New("Match network addresses are not 4 bytes.")
+	}
+	if err := validatePad(m.pad); err != nil {
+		return err
+	}
+	return validatePad(m.pad2)
+}
+
+func (p *PacketOut) Validate() error {
+	if err := validateHeader(&p.Header, p.Len(), Type_PacketOut); err != nil {
+		return err
+	}
+	if p.ActionsLen != actionsLen(p.Actions) {
+		return errors.New("PacketOut ActionsLen does not match its actions.")
+	}
+	if p.BufferId == 0xffffffff && p.Data == nil {
+		return errors.New("Unbuffered PacketOut carries no data.")
+	}
+	if !isSwitchPort(p.InPort) && p.InPort != P_NONE && p.InPort != P_CONTROLLER {
+		return errors.New("PacketOut in port " + strconv.Itoa(int(p.InPort)) + " is not valid.")
+	}
+	return ValidateActions(p.Actions)
+}
+
+func (p *PacketIn) Validate() error {
+	if err := validateHeader(&p.Header, p.Len(), Type_PacketIn); err != nil {
+		return err
+	}
+	if p.Reason > R_ACTION {
+		return errors.New("PacketIn reason " + strconv.Itoa(int(p.Reason)) + " is not valid.")
+	}
+	if p.pad != 0 {
+		return errors.New("Padding is not zero.")
+	}
+	return nil
+}
+
+func (f *FlowMod) Validate() error {
+	if err := validateHeader(&f.Header, f.Len(), Type_FlowMod); err != nil {
+		return err
+	}
+	if f.Command > FC_DELETE_STRICT {
+		return errors.New("FlowMod command " + strconv.Itoa(int(f.Command)) + " is not valid.")
+	}
+	if f.Flags&^(FF_SEND_FLOW_REM|FF_CHECK_OVERLAP|FF_EMERG) != 0 {
+		return errors.New("FlowMod sets unknown flags.")
+	}
+	if err := f.Match.Validate(); err != nil {
+		return err
+	}
+	if f.Command == FC_DELETE || f.Command == FC_DELETE_STRICT {
+		return nil
+	}
+	return ValidateActions(f.Actions)
+}
+
+func (f *FlowRemoved) Validate() error {
+	if err := validateHeader(&f.Header, f.Len(), Type_FlowRemoved); err != nil {
+		return err
+	}
+	if f.Reason > RR_DELETE {
+		return errors.New("FlowRemoved reason " + strconv.Itoa(int(f.Reason)) + " is not valid.")
+	}
+	if err := f.Match.Validate(); err != nil {
+		return err
+	}
+	if err := validatePad(f.pad); err != nil {
+		return err
+	}
+	return validatePad(f.pad2)
+}
+
+func (p *PortMod) Validate() error {
+	if err := validateHeader(&p.Header, p.Len(), Type_PortMod); err != nil {
+		return err
+	}
+	if !isSwitchPort(p.PortNo) {
+		return errors.New("PortMod port " + strconv.Itoa(int(p.PortNo)) + " is not valid.")
+	}
+	if len(p.HWAddr) != ETH_ALEN {
+		return errors.New("PortMod hardware address is not 6 bytes.")
+	}
+	return validatePad(p.pad)
+}
+
+func (p *PortStatus) Validate() error {
+	if err := validateHeader(&p.Header, p.Len(), Type_PortStatus); err != nil {
+		return err
+	}
+	if p.Reason > PR_MODIFY {
+		return errors.New("PortStatus reason " + strconv.Itoa(int(p.Reason)) + " is not valid.")
+	}
+	return validatePad(p.pad)
+}
+
+func (s *SwitchFeatures) Validate() error {
+	if err := validateHeader(&s.Header, s.Len(), Type_FeaturesReply); err != nil {
+		return err
+	}
+	if len(s.DPID) != 8 {
+		return errors.New("SwitchFeatures DPID is not 8 bytes.")
+	}
+	return validatePad(s.pad)
+}
+
+func (c *SwitchConfig) Validate() error {
+	if err := validateHeader(&c.Header, c.Len(), Type_SetConfig, Type_GetConfigReply); err != nil {
+		return err
+	}
+	if c.Flags&^C_FRAG_MASK != 0 || c.Flags&C_FRAG_MASK == C_FRAG_MASK {
+		return errors.New("SwitchConfig flags are not valid.")
+	}
+	return nil
+}
+
+func (e *ErrorMsg) Validate() error {
+	return validateHeader(&e.Header, e.Len(), Type_Error)
+}
+
+func (s *StatsRequest) Validate() error {
+	if err := validateHeader(&s.Header, s.Len(), Type_StatsRequest); err != nil {
+		return err
+	}
+	if v, ok := s.Body.(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+func (s *StatsReply) Validate() error {
+	if err := s.checkSize(); err != nil {
+		return err
+	}
+	if err := validateHeader(&s.Header, s.Len(), Type_StatsReply); err != nil {
+		return err
+	}
+	for _, b := range s.Body {
+		if v, ok := b.(Validator); ok {
+			if err := v.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (q *QueueGetConfigRequest) Validate() error {
+	if err := validateHeader(&q.Header, q.Len(), Type_QueueGetConfigRequest); err != nil {
+		return err
+	}
+	if q.Port == 0 || q.Port >= P_MAX {
+		return errors.New("QueueGetConfigRequest port " + strconv.Itoa(int(q.Port)) + " is not valid.")
+	}
+	return validatePad(q.pad)
+}
+
+func (q *QueueGetConfigReply) Validate() error {
+	if err := validateHeader(&q.Header, q.Len(), Type_QueueGetConfigReply); err != nil {
+		return err
+	}
+	return validatePad(q.pad)
+}
+
+func (v *VendorHeader) Validate() error {
+	return validateHeader(&v.Header, v.Len(), Type_Vendor)
+}
@@ -0,0 +1,179 @@
+package ofp10
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Vendor messages, actions and stats carry a vendor id followed by
+// a vendor defined subtype. Packages implementing a vendor's
+// extensions register a constructor for each subtype they
+// understand, usually from an init function. Parse, DecodeAction
+// and the stats messages then return the registered type instead
+// of an opaque VendorHeader, ActionVendor or VendorStats.
+//
+// Subtypes are read from the bytes that follow the vendor id, the
+// layout used by Nicira and most other vendors: a 32 bit subtype
+// for messages and stats and a 16 bit subtype for actions.
+// Registering VENDOR_SUBTYPE_ANY claims every subtype of a vendor
+// that has no registration of its own.
+const VENDOR_SUBTYPE_ANY = 0xffffffff
+
+// Implemented by every message decoded from an OFPT_VENDOR
+// message, whether by a registered vendor or as a VendorHeader.
+type VendorMessage interface {
+	util.Message
+	VendorId() uint32
+}
+
+type vendorKey struct {
+	vendor  uint32
+	subtype uint32
+}
+
+var vendors = struct {
+	sync.RWMutex
+	messages map[vendorKey]func() util.Message
+	actions  map[vendorKey]func() Action
+	stats    map[vendorKey]func() util.Message
+}{
+	messages: make(map[vendorKey]func() util.Message),
+	actions:  make(map[vendorKey]func() Action),
+	stats:    make(map[vendorKey]func() util.Message),
+}
+
+// Registers fn as the constructor of vendor messages with the
+// given vendor id and subtype. The returned message is unmarshaled
+// from the whole OpenFlow message, starting at its header.
+func RegisterVendorMessage(vendor, subtype uint32, fn func() util.Message) {
+	vendors.Lock()
+	defer vendors.Unlock()
+	vendors.messages[vendorKey{vendor, subtype}] = fn
+}
+
+// Registers fn as the constructor of vendor actions with the given
+// vendor id and 16 bit subtype, or VENDOR_SUBTYPE_ANY. The returned
+// action is unmarshaled from its ofp_action_header onwards.
+func RegisterVendorAction(vendor, subtype uint32, fn func() Action) {
+	vendors.Lock()
+	defer vendors.Unlock()
+	vendors.actions[vendorKey{vendor, subtype}] = fn
+}
+
+// Registers fn as the constructor of vendor stats bodies with the
+// given vendor id and subtype. The same constructor is used for
+// requests and replies. The returned body is unmarshaled from the
+// vendor id onwards.
+func RegisterVendorStats(vendor, subtype uint32, fn func() util.Message) {
+	vendors.Lock()
+	defer vendors.Unlock()
+	vendors.stats[vendorKey{vendor, subtype}] = fn
+}
+
+func lookupVendor(m map[vendorKey]func() util.Message, vendor, subtype uint32) func() util.Message {
+	vendors.RLock()
+	defer vendors.RUnlock()
+	if fn, ok := m[vendorKey{vendor, subtype}]; ok {
+		return fn
+	}
+	return m[vendorKey{vendor, VENDOR_SUBTYPE_ANY}]
+}
+
+// Decodes a vendor message with its registered constructor.
+// Messages nobody registered are returned as a VendorHeader.
+func decodeVendor(data []byte) (util.Message, error) {
+	v := new(VendorHeader)
+	if err := v.UnmarshalBinary(data); err != nil {
+		return v, err
+	}
+	if len(data) >= 16 {
+		subtype := binary.BigEndian.Uint32(data[12:16])
+		if fn := lookupVendor(vendors.messages, v.Vendor, subtype); fn != nil {
+			m := fn()
+			err := m.UnmarshalBinary(data)
+			return m, err
+		}
+	}
+	return v, nil
+}
+
+// Decodes a vendor action with its registered constructor. Actions
+// nobody registered are returned as an ActionVendor.
+func decodeVendorAction(data []byte) (Action, error) {
+	if len(data) >= 10 {
+		vendor := binary.BigEndian.Uint32(data[4:8])
+		subtype := uint32(binary.BigEndian.Uint16(data[8:10]))
+
+		vendors.RLock()
+		fn, ok := vendors.actions[vendorKey{vendor, subtype}]
+		if !ok {
+			fn = vendors.actions[vendorKey{vendor, VENDOR_SUBTYPE_ANY}]
+		}
+		vendors.RUnlock()
+
+		if fn != nil {
+			a := fn()
+			err := a.UnmarshalBinary(data)
+			return a, err
+		}
+	}
+	a := new(ActionVendor)
+	err := a.UnmarshalBinary(data)
+	return a, err
+}
+
+// Decodes the body of a vendor stats request or reply with its
+// registered constructor. Bodies nobody registered are returned
+// as VendorStats.
+func decodeVendorStats(data []byte) (util.Message, error) {
+	v := new(VendorStats)
+	if err := v.UnmarshalBinary(data); err != nil {
+		return v, err
+	}
+	if len(data) >= 8 {
+		subtype := binary.BigEndian.Uint32(data[4:8])
+		if fn := lookupVendor(vendors.stats, v.Vendor, subtype); fn != nil {
+			m := fn()
+			err := m.UnmarshalBinary(data)
+			return m, err
+		}
+	}
+	return v, nil
+}
+
+// The body of an OFPST_VENDOR stats request or reply from a vendor
+// with no registered stats.
+type VendorStats struct {
+	Vendor uint32
+	Data   []byte
+}
+
+func NewVendorStats(vendor uint32) *VendorStats {
+	v := new(VendorStats)
+	v.Vendor = vendor
+	v.Data = make([]byte, 0)
+	return v
+}
+
+func (v *VendorStats) Len() (n uint16) {
+	return uint16(4 + len(v.Data))
+}
+
+func (v *VendorStats) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(v.Len()))
+	binary.BigEndian.PutUint32(data[:4], v.Vendor)
+	copy(data[4:], v.Data)
+	return
+}
+
+func (v *VendorStats) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return util.NewTruncatedError("VendorStats message", 4, len(data))
+	}
+	v.Vendor = binary.BigEndian.Uint32(data[:4])
+	v.Data = make([]byte, len(data)-4)
+	copy(v.Data, data[4:])
+	return nil
+}
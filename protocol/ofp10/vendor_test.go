@@ -0,0 +1,87 @@
+package ofp10
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Removes key from registry m once t is done, so the
+// package-global registry is left as the test found it.
+func unregisterVendor(t *testing.T, m map[vendorKey]func() util.Message, key vendorKey) {
+	t.Cleanup(func() {
+		vendors.Lock()
+		defer vendors.Unlock()
+		delete(m, key)
+	})
+}
+
+type testVendorMessage struct {
+	VendorHeader
+}
+
+type testVendorStats struct {
+	VendorStats
+}
+
+func TestVendorParse(t *testing.T) {
+	b := "   01 04 00 14 00 00 00 01 " + // Header
+		"00 00 00 bb 00 00 00 07 " + // Vendor, Subtype
+		"01 02 03 04 " // Data
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	msg, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, ok := msg.(*VendorHeader)
+	if !ok {
+		t.Fatalf("Got %T, expected *VendorHeader.", msg)
+	}
+	if v.VendorId() != 0xbb || len(v.Data) != 8 {
+		t.Errorf("Got vendor %x data %x.", v.Vendor, v.Data)
+	}
+	d, _ := v.MarshalBinary()
+	if hex.EncodeToString(d) != b {
+		t.Errorf("Got %x, expected %s.", d, b)
+	}
+
+	RegisterVendorMessage(0xbb, 7, func() util.Message {
+		return new(testVendorMessage)
+	})
+	unregisterVendor(t, vendors.messages, vendorKey{0xbb, 7})
+	msg, _ = Parse(data)
+	if _, ok := msg.(*testVendorMessage); !ok {
+		t.Errorf("Got %T, expected the registered *testVendorMessage.", msg)
+	}
+}
+
+func TestVendorStatsParse(t *testing.T) {
+	b := "   01 11 00 18 00 00 00 01 " + // Header
+		"ff ff 00 00 " + // Type, Flags
+		"00 00 00 cc 00 00 00 02 " + // Vendor, Subtype
+		"00 00 00 00 " // Body
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	msg, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := msg.(*StatsReply)
+	if v, ok := s.Body[0].(*VendorStats); !ok || v.Vendor != 0xcc {
+		t.Fatalf("Got body %T, expected *VendorStats for vendor cc.", s.Body[0])
+	}
+
+	RegisterVendorStats(0xcc, 2, func() util.Message {
+		return new(testVendorStats)
+	})
+	unregisterVendor(t, vendors.stats, vendorKey{0xcc, 2})
+	msg, _ = Parse(data)
+	if _, ok := msg.(*StatsReply).Body[0].(*testVendorStats); !ok {
+		t.Errorf("Got body %T, expected *testVendorStats.", msg.(*StatsReply).Body[0])
+	}
+}
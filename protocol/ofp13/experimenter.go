@@ -0,0 +1,109 @@
+package ofp13
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/jonstout/ogo/protocol/ofpxx"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+const Type_Experimenter = 4
+
+// Matches every exp_type of an experimenter that has no
+// registration of its own.
+const EXP_TYPE_ANY = 0xffffffff
+
+type experimenterKey struct {
+	experimenter uint32
+	expType      uint32
+}
+
+var experimenters = struct {
+	sync.RWMutex
+	m map[experimenterKey]func() util.Message
+}{m: make(map[experimenterKey]func() util.Message)}
+
+// Registers fn as the constructor of experimenter messages with
+// the given experimenter id and exp_type. The returned message is
+// unmarshaled from the whole OpenFlow message, starting at its
+// header.
+func RegisterExperimenter(experimenter, expType uint32, fn func() util.Message) {
+	experimenters.Lock()
+	defer experimenters.Unlock()
+	experimenters.m[experimenterKey{experimenter, expType}] = fn
+}
+
+// Decodes an experimenter message with its registered constructor.
+// Messages nobody registered are returned as an ExperimenterHeader.
+func decodeExperimenter(data []byte) (util.Message, error) {
+	e := new(ExperimenterHeader)
+	if err := e.UnmarshalBinary(data); err != nil {
+		return e, err
+	}
+
+	experimenters.RLock()
+	fn, ok := experimenters.m[experimenterKey{e.Experimenter, e.ExpType}]
+	if !ok {
+		fn = experimenters.m[experimenterKey{e.Experimenter, EXP_TYPE_ANY}]
+	}
+	experimenters.RUnlock()
+
+	if fn != nil {
+		m := fn()
+		err := m.UnmarshalBinary(data)
+		return m, err
+	}
+	return e, nil
+}
+
+// ofp_experimenter_header 1.3
+type ExperimenterHeader struct {
+	ofpxx.Header
+	Experimenter uint32
+	ExpType      uint32
+	Data         []byte
+}
+
+func NewExperimenterHeader(experimenter, expType uint32) *ExperimenterHeader {
+	e := new(ExperimenterHeader)
+	e.Header = ofpxx.NewOfp13Header()
+	e.Header.Type = Type_Experimenter
+	e.Experimenter = experimenter
+	e.ExpType = expType
+	e.Data = make([]byte, 0)
+	return e
+}
+
+func (e *ExperimenterHeader) Len() (n uint16) {
+	return e.Header.Len() + 8 + uint16(len(e.Data))
+}
+
+func (e *ExperimenterHeader) MarshalBinary() (data []byte, err error) {
+	e.Header.Length = e.Len()
+	data, err = e.Header.MarshalBinary()
+
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b[:4], e.Experimenter)
+	binary.BigEndian.PutUint32(b[4:], e.ExpType)
+	data = append(data, b...)
+	data = append(data, e.Data...)
+	return
+}
+
+func (e *ExperimenterHeader) UnmarshalBinary(data []byte) error {
+	if len(data) < 16 {
+		return util.NewTruncatedError("ExperimenterHeader message", 16, len(data))
+	}
+	err := e.Header.UnmarshalBinary(data)
+	e.Experimenter = binary.BigEndian.Uint32(data[8:12])
+	e.ExpType = binary.BigEndian.Uint32(data[12:16])
+
+	end := int(e.Header.Length)
+	if end < 16 || end > len(data) {
+		end = len(data)
+	}
+	e.Data = make([]byte, end-16)
+	copy(e.Data, data[16:end])
+	return err
+}
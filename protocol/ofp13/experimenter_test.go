@@ -0,0 +1,52 @@
+package ofp13
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+type testExperimenter struct {
+	ExperimenterHeader
+}
+
+func TestExperimenterParse(t *testing.T) {
+	b := "   04 04 00 14 00 00 00 01 " + // Header
+		"00 00 00 aa 00 00 00 01 " + // Experimenter, ExpType
+		"01 02 03 04 " // Data
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	msg, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok := msg.(*ExperimenterHeader)
+	if !ok {
+		t.Fatalf("Got %T, expected *ExperimenterHeader.", msg)
+	}
+	if e.Experimenter != 0xaa || e.ExpType != 1 || len(e.Data) != 4 {
+		t.Errorf("Got experimenter %x type %d data %x.", e.Experimenter, e.ExpType, e.Data)
+	}
+	d, _ := e.MarshalBinary()
+	if hex.EncodeToString(d) != b {
+		t.Errorf("Got %x, expected %s.", d, b)
+	}
+
+	RegisterExperimenter(0xaa, EXP_TYPE_ANY, func() util.Message {
+		return new(testExperimenter)
+	})
+	t.Cleanup(func() {
+		experimenters.Lock()
+		defer experimenters.Unlock()
+		delete(experimenters.m, experimenterKey{0xaa, EXP_TYPE_ANY})
+	})
+	if msg, _ = Parse(data); msg == nil {
+		t.Fatal("Parse returned no message.")
+	}
+	if _, ok := msg.(*testExperimenter); !ok {
+		t.Errorf("Got %T, expected the registered *testExperimenter.", msg)
+	}
+}
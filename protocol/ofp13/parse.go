@@ -1,15 +1,25 @@
 package ofp13
 
 import (
-	"errors"
+	"strconv"
 
+	"github.com/jonstout/ogo/protocol/ofpxx"
 	"github.com/jonstout/ogo/protocol/util"
 )
 
+// Returns the message at the start of b, which must hold all of it.
+// Bytes past the length in its header are ignored.
 func Parse(b []byte) (message util.Message, err error) {
-	switch b[1] {
+	h, err := ofpxx.DecodeHeader(b)
+	if err != nil {
+		return
+	}
+	b = b[:h.Length]
+	switch h.Type {
+	case Type_Experimenter:
+		message, err = decodeExperimenter(b)
 	default:
-		err = errors.New("An unknown v1.3 packet type was received. Parse function will discard data.")
+		err = util.NewMalformedError("v1.3 message", "unknown type "+strconv.Itoa(int(h.Type)))
 	}
 	return
 }
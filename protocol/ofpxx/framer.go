@@ -0,0 +1,40 @@
+package ofpxx
+
+import (
+	"encoding/binary"
+	"io"
+	"strconv"
+
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Reads the next OpenFlow message from r into buf and returns it.
+// The message reuses the capacity of buf, which grows only when a
+// message does not fit, so passing the result back in as buf reads
+// a stream of messages without allocating.
+func ReadMessage(r io.Reader, buf []byte) ([]byte, error) {
+	if cap(buf) < 8 {
+		buf = make([]byte, 0, 2048)
+	}
+	buf = buf[:8]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return buf[:0], err
+	}
+	length := int(binary.BigEndian.Uint16(buf[2:4]))
+	if length < 8 {
+		return buf[:0], util.NewMalformedError("OpenFlow message", "length "+strconv.Itoa(length)+" is shorter than the header")
+	}
+	if cap(buf) < length {
+		b := make([]byte, 8, length)
+		copy(b, buf)
+		buf = b
+	}
+	buf = buf[:length]
+	if _, err := io.ReadFull(r, buf[8:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return buf[:0], err
+	}
+	return buf, nil
+}
@@ -0,0 +1,63 @@
+package ofpxx
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadMessage(t *testing.T) {
+	s := "   01 02 00 08 00 00 00 01 " + // Echo request
+		"01 03 00 0c 00 00 00 01 " + // Echo reply
+		"de ad be ef " + // Data
+		"01 02 00 10 00 00 00 02 " // Echo request cut short
+	s = strings.Replace(s, " ", "", -1)
+	data, _ := hex.DecodeString(s)
+	r := bytes.NewReader(data)
+
+	buf := make([]byte, 0, 8)
+	buf, err := ReadMessage(r, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf) != 8 || buf[1] != 2 {
+		t.Errorf("Got message %x, expected an echo request.", buf)
+	}
+
+	buf, err = ReadMessage(r, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := hex.EncodeToString(buf); d != s[16:40] {
+		t.Log("Exp:", s[16:40])
+		t.Log("Rec:", d)
+		t.Errorf("Received length of %d, expected %d", len(d), 24)
+	}
+
+	if _, err = ReadMessage(r, buf); err != io.ErrUnexpectedEOF {
+		t.Errorf("Got error %v, expected %v.", err, io.ErrUnexpectedEOF)
+	}
+	if _, err = ReadMessage(r, buf); err != io.EOF {
+		t.Errorf("Got error %v, expected %v.", err, io.EOF)
+	}
+}
+
+func BenchmarkReadMessage(b *testing.B) {
+	h := NewOfp10Header()
+	h.Length = 128
+	msg, _ := h.MarshalBinary()
+	msg = append(msg, make([]byte, 120)...)
+	stream := bytes.Repeat(msg, 64)
+	r := bytes.NewReader(stream)
+
+	buf := make([]byte, 0, 2048)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if r.Len() == 0 {
+			r.Reset(stream)
+		}
+		buf, _ = ReadMessage(r, buf)
+	}
+}
@@ -4,25 +4,51 @@ package ofpxx
 import (
 	"encoding/binary"
 	"errors"
+	"strconv"
+	"sync/atomic"
 
 	"github.com/jonstout/ogo/protocol/util"
 )
 
 // Returns a new OpenFlow header with version field set to v1.0.
+// The Xid is left zero, to be allocated by the connection the
+// message is sent on.
 var NewOfp10Header func() Header = newHeaderGenerator(1)
 // Returns a new OpenFlow header with version field set to v1.3.
 var NewOfp13Header func() Header = newHeaderGenerator(4)
 
-var messageXid uint32 = 1
-
 func newHeaderGenerator(ver int) func() Header {
 	return func() Header {
-		messageXid += 1
-		p := Header{uint8(ver), 0, 8, messageXid}
+		p := Header{uint8(ver), 0, 8, 0}
 		return p
 	}
 }
 
+// Allocates transaction ids. Each connection should have its own
+// XidSpace so that replies can be matched to the requests sent on
+// it. The zero value is ready to use and is safe for concurrent use.
+type XidSpace struct {
+	xid uint32
+}
+
+// Returns the next Xid. Zero is skipped, since it marks a message
+// whose Xid has not been set.
+func (x *XidSpace) Next() uint32 {
+	for {
+		if xid := atomic.AddUint32(&x.xid, 1); xid != 0 {
+			return xid
+		}
+	}
+}
+
+// Implemented by all OpenFlow messages, which either embed a Header
+// or provide these methods for the one they hold.
+type XidMessage interface {
+	util.Message
+	GetXid() uint32
+	SetXid(xid uint32)
+}
+
 // The version specifies the OpenFlow protocol version being
 // used. During the current draft phase of the OpenFlow
 // Protocol, the most significant bit will be set to indicate an
@@ -42,22 +68,41 @@ func (h *Header) Header() *Header {
 	return h
 }
 
+// Returns the Xid of h. Messages embed Header under its own name,
+// which hides the Header method, so this is how their Xid is read
+// without knowing their type.
+func (h *Header) GetXid() uint32 {
+	return h.Xid
+}
+
+func (h *Header) SetXid(xid uint32) {
+	h.Xid = xid
+}
+
 func (h *Header) Len() (n uint16) {
 	return 8
 }
 
 func (h *Header) MarshalBinary() (data []byte, err error) {
-	data = make([]byte, 8)
-	data[0] = h.Version
-	data[1] = h.Type
-	binary.BigEndian.PutUint16(data[2:4], h.Length)
-	binary.BigEndian.PutUint32(data[4:8], h.Xid)
+	return AppendHeader(make([]byte, 0, 8), h), nil
+}
+
+// Appends the encoding of h to b. Messages embed Header, so an
+// AppendBinary method here would be promoted to those without one
+// of their own and append only their header.
+func AppendHeader(b []byte, h *Header) (data []byte) {
+	n := len(b)
+	data = util.Extend(b, 8)
+	data[n] = h.Version
+	data[n+1] = h.Type
+	binary.BigEndian.PutUint16(data[n+2:n+4], h.Length)
+	binary.BigEndian.PutUint32(data[n+4:n+8], h.Xid)
 	return
 }
 
 func (h *Header) UnmarshalBinary(data []byte) error {
-	if len(data) < 4 {
-		return errors.New("The []byte is too short to unmarshel a full HelloElemHeader.")
+	if len(data) < 8 {
+		return util.NewTruncatedError("Header", 8, len(data))
 	}
 	h.Version = data[0]
 	h.Type = data[1]
@@ -66,6 +111,20 @@ func (h *Header) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
+// Returns the header at the start of data, making sure that its
+// length covers the header and fits within data.
+func DecodeHeader(data []byte) (h Header, err error) {
+	if err = h.UnmarshalBinary(data); err != nil {
+		return
+	}
+	if h.Length < h.Len() {
+		err = util.NewMalformedError("Header", "length "+strconv.Itoa(int(h.Length))+" is shorter than the header")
+	} else if int(h.Length) > len(data) {
+		err = util.NewTruncatedError("OpenFlow message", int(h.Length), len(data))
+	}
+	return
+}
+
 const (
 	reserved = iota
 	HelloElemType_VersionBitmap
@@ -105,7 +164,7 @@ func (h *HelloElemHeader) MarshalBinary() (data []byte, err error) {
 
 func (h *HelloElemHeader) UnmarshalBinary(data []byte) error {
 	if len(data) < 4 {
-		return errors.New("The []byte is too short to unmarshal a full HelloElemHeader.")
+		return util.NewTruncatedError("HelloElemHeader", 4, len(data))
 	}
 	h.Type = binary.BigEndian.Uint16(data[:2])
 	h.Length = binary.BigEndian.Uint16(data[2:4])
@@ -155,12 +214,16 @@ func (h *HelloElemVersionBitmap) MarshalBinary() (data []byte, err error) {
 }
 
 func (h *HelloElemVersionBitmap) UnmarshalBinary(data []byte) error {
-	length := len(data)
 	read := 0
-	if err := h.HelloElemHeader.UnmarshalBinary(data[:4]); err != nil {
+	if err := h.HelloElemHeader.UnmarshalBinary(data); err != nil {
 		return err
 	}
 	read += int(h.HelloElemHeader.Len())
+	length := int(h.Length)
+	if length < read || length > len(data) {
+		return util.NewMalformedError("HelloElemVersionBitmap", "length "+strconv.Itoa(length)+" does not fit "+strconv.Itoa(len(data))+" bytes")
+	}
+	length -= (length - read) % 4
 
 	h.Bitmaps = make([]uint32, 0)
 	for read < length {
@@ -231,21 +294,32 @@ func (h *Hello) MarshalBinary() (data []byte, err error) {
 
 func (h *Hello) UnmarshalBinary(data []byte) error {
 	next := 0
-	err := h.Header.UnmarshalBinary(data[next:])
+	if err := h.Header.UnmarshalBinary(data[next:]); err != nil {
+		return err
+	}
 	next += int(h.Header.Len())
-	
+
 	h.Elements = make([]HelloElem, 0)
 	for next < len(data) {
 		e := NewHelloElemHeader()
-		e.UnmarshalBinary(data[next:])
+		if err := e.UnmarshalBinary(data[next:]); err != nil {
+			return err
+		}
+		if e.Length < e.Len() {
+			return util.NewMalformedError("Hello message", "element length "+strconv.Itoa(int(e.Length))+" is shorter than its header")
+		}
 
 		switch e.Type {
 		case HelloElemType_VersionBitmap:
 			v := NewHelloElemVersionBitmap()
-			err = v.UnmarshalBinary(data[next:])
-			next += int(v.Len())
+			if err := v.UnmarshalBinary(data[next:]); err != nil {
+				return err
+			}
 			h.Elements = append(h.Elements, v)
 		}
+		// Elements are padded to a multiple of 8 bytes. Unknown
+		// elements are skipped.
+		next += (int(e.Length) + 7) / 8 * 8
 	}
-	return err
+	return nil
 }
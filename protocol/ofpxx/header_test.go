@@ -3,11 +3,12 @@ package ofpxx
 import (
 	"encoding/hex"
 	"strings"
+	"sync"
 	"testing"
 )
 
 func TestHelloMarshalBinary(t *testing.T) {
-	b := "   01 00 00 10 00 00 00 03 " + // Header
+	b := "   01 00 00 10 00 00 00 00 " + // Header
 		"00 01 00 08 " + // Element Header
 		"00 00 00 01 " // Bitmap = 1001 for v1.3
 	b = strings.Replace(b, " ", "", -1)
@@ -51,3 +52,40 @@ func TestHelloUnmarshalBinary(t *testing.T) {
 		t.Errorf("Got %d bitmap, expected %d.", v.Bitmaps[0], uint32(8))
 	}
 }
+
+func TestXidSpaceNext(t *testing.T) {
+	var x XidSpace
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	seen := make(map[uint32]bool)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			xids := make([]uint32, 1000)
+			for j := range xids {
+				xids[j] = x.Next()
+			}
+			mu.Lock()
+			for _, xid := range xids {
+				if seen[xid] {
+					t.Errorf("Xid %d was allocated twice.", xid)
+				}
+				seen[xid] = true
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 8000 {
+		t.Errorf("Got %d Xids, expected %d.", len(seen), 8000)
+	}
+
+	x = XidSpace{^uint32(0) - 1}
+	if xid := x.Next(); xid != ^uint32(0) {
+		t.Errorf("Got Xid %d, expected %d.", xid, ^uint32(0))
+	}
+	if xid := x.Next(); xid != 1 {
+		t.Errorf("Got Xid %d, expected %d after wrapping past zero.", xid, 1)
+	}
+}
@@ -0,0 +1,103 @@
+// Package packet decodes a frame into the ordered list of
+// protocol layers it carries.
+//
+// Each protocol package picks the decoder of the layer it carries
+// from its own registry: eth.RegisterEthertype, ipv4.RegisterProtocol,
+// ipv6.RegisterProtocol and udp.RegisterPort. Registering a new
+// protocol there makes it show up in every decoded Packet.
+package packet
+
+import (
+	"reflect"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Implemented by layers that carry another layer, such as
+// eth.Ethernet, ipv4.IPv4 and udp.UDP. Payload returns nil when
+// there is nothing to carry.
+type Carrier interface {
+	Payload() util.Message
+}
+
+// A decoded frame. Layers holds every layer that decoded, outermost
+// first. When decoding stopped early, ErrorLayer is the layer that
+// failed and Err is its error.
+type Packet struct {
+	Layers     []util.Message
+	ErrorLayer util.Message
+	Err        error
+}
+
+// Decodes an Ethernet frame.
+func Decode(data []byte) *Packet {
+	e := eth.New()
+	err := e.UnmarshalBinary(data)
+	return newPacket(e, err)
+}
+
+// Returns the layers of an already decoded frame, such as the
+// Data of an ofp10.PacketIn.
+func FromEthernet(e *eth.Ethernet) *Packet {
+	return newPacket(e, nil)
+}
+
+func newPacket(m util.Message, err error) *Packet {
+	p := new(Packet)
+	p.Layers = make([]util.Message, 0)
+	for m != nil && !isNil(m) {
+		p.Layers = append(p.Layers, m)
+		c, ok := m.(Carrier)
+		if !ok {
+			break
+		}
+		m = c.Payload()
+	}
+	// Decoders stop at the first error, so the innermost layer
+	// is the one that failed.
+	if err != nil && len(p.Layers) > 0 {
+		p.ErrorLayer = p.Layers[len(p.Layers)-1]
+		p.Layers = p.Layers[:len(p.Layers)-1]
+		p.Err = err
+	}
+	return p
+}
+
+func isNil(m util.Message) bool {
+	v := reflect.ValueOf(m)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// Finds the first layer whose type matches the type target points
+// to and sets target to it, like errors.As:
+//
+//	var ip *ipv4.IPv4
+//	if p.Layer(&ip) {
+//		...
+//	}
+//
+// Layer panics if target is not a non-nil pointer.
+func (p *Packet) Layer(target interface{}) bool {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		panic("packet: Layer target must be a non-nil pointer")
+	}
+	t := v.Type().Elem()
+	for _, l := range p.Layers {
+		if reflect.TypeOf(l).AssignableTo(t) {
+			v.Elem().Set(reflect.ValueOf(l))
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the innermost decoded layer, usually the application
+// payload.
+func (p *Packet) Last() util.Message {
+	if len(p.Layers) == 0 {
+		return nil
+	}
+	return p.Layers[len(p.Layers)-1]
+}
@@ -0,0 +1,78 @@
+package packet
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/ipv4"
+	"github.com/jonstout/ogo/protocol/tcp"
+	"github.com/jonstout/ogo/protocol/udp"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+type testPayload struct {
+	util.Buffer
+}
+
+func TestDecodeUDP(t *testing.T) {
+	b := "   00 00 00 00 00 02 00 00 00 00 00 01 08 00 " + // Ethernet
+		"45 00 00 20 00 00 00 00 40 11 00 00 " + // IPv4
+		"0a 00 00 01 0a 00 00 02 " +
+		"04 d2 27 0f 00 0c 00 00 " + // UDP to port 9999
+		"01 02 03 04 " // Data
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	udp.RegisterPort(9999, func() util.Message { return new(testPayload) })
+
+	p := Decode(data)
+	if p.Err != nil {
+		t.Fatal(p.Err)
+	}
+	if len(p.Layers) != 4 {
+		t.Fatalf("Got %d layers, expected 4.", len(p.Layers))
+	}
+
+	var ip *ipv4.IPv4
+	if !p.Layer(&ip) || ip.Protocol != ipv4.Type_UDP {
+		t.Errorf("Got ip layer %v, expected a UDP packet.", ip)
+	}
+	var payload *testPayload
+	if !p.Layer(&payload) || payload.Len() != 4 {
+		t.Errorf("Got payload %v, expected the registered type.", payload)
+	}
+	if p.Last() != payload {
+		t.Errorf("Got last layer %T, expected *testPayload.", p.Last())
+	}
+	var s *tcp.TCP
+	if p.Layer(&s) {
+		t.Error("Found a TCP layer in a UDP packet.")
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	b := "   00 00 00 00 00 02 00 00 00 00 00 01 08 00 " + // Ethernet
+		"45 00 00 1e 00 00 00 00 40 06 00 00 " + // IPv4
+		"0a 00 00 01 0a 00 00 02 " +
+		"04 d2 00 50 00 00 " // Truncated TCP
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	p := Decode(data)
+	if p.Err == nil {
+		t.Fatal("Expected an error decoding a truncated TCP header.")
+	}
+	if _, ok := p.ErrorLayer.(*tcp.TCP); !ok {
+		t.Errorf("Got error layer %T, expected *tcp.TCP.", p.ErrorLayer)
+	}
+	if len(p.Layers) != 2 {
+		t.Errorf("Got %d layers, expected 2.", len(p.Layers))
+	}
+
+	var e *eth.Ethernet
+	if !p.Layer(&e) || e.Ethertype != eth.IPv4_MSG {
+		t.Errorf("Got ethernet layer %v, expected an IPv4 frame.", e)
+	}
+}
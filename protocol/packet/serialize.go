@@ -0,0 +1,118 @@
+package packet
+
+import (
+	"net"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/ipv4"
+	"github.com/jonstout/ogo/protocol/ipv6"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// Selects the fields Serialize computes instead of taking them
+// from the caller.
+type SerializeOptions struct {
+	// Sets the IPv4 IHL and total length, the IPv6 payload
+	// length, the UDP length and the length of 802.3 frames.
+	FixLengths bool
+	// Sets the IPv4 header checksum and the ICMP, ICMPv6, IGMP,
+	// TCP and UDP checksums.
+	ComputeChecksums bool
+}
+
+// Layers that can compute their own length fields.
+type lengthFixer interface {
+	FixLengths() error
+}
+
+// Layers whose checksum covers only themselves.
+type checksummer interface {
+	SetChecksum() error
+}
+
+// Layers whose checksum covers the pseudo-header of the enclosing
+// IP packet.
+type pseudoChecksummer interface {
+	SetChecksum(src, dst net.IP) error
+}
+
+// Marshals m and every layer it carries, first fixing up the
+// fields opts selects. Fields are set on the layers themselves.
+func Serialize(m util.Message, opts SerializeOptions) ([]byte, error) {
+	layers := newPacket(m, nil).Layers
+
+	if opts.FixLengths {
+		for _, l := range layers {
+			if f, ok := l.(lengthFixer); ok {
+				if err := f.FixLengths(); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if opts.ComputeChecksums {
+		// Innermost first, since outer checksums may cover the
+		// inner ones.
+		for i := len(layers) - 1; i >= 0; i-- {
+			switch c := layers[i].(type) {
+			case checksummer:
+				if err := c.SetChecksum(); err != nil {
+					return nil, err
+				}
+			case pseudoChecksummer:
+				src, dst, ok := addresses(layers[:i])
+				if !ok {
+					continue
+				}
+				if err := c.SetChecksum(src, dst); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return m.MarshalBinary()
+}
+
+// Returns the addresses of the innermost IP layer.
+func addresses(layers []util.Message) (src, dst net.IP, ok bool) {
+	for i := len(layers) - 1; i >= 0; i-- {
+		switch ip := layers[i].(type) {
+		case *ipv4.IPv4:
+			return ip.NWSrc, ip.NWDst, true
+		case *ipv6.IPv6:
+			return ip.NWSrc, ip.NWDst, true
+		}
+	}
+	return nil, nil, false
+}
+
+// Returns a frame from hwSrc to hwDst carrying payload in an IP
+// packet with the given protocol number. The packet is IPv6 if
+// either address is, IPv4 otherwise. Lengths and checksums are left
+// for Serialize.
+func NewIPFrame(hwSrc, hwDst net.HardwareAddr, src, dst net.IP, protocol uint8, payload util.Message) *eth.Ethernet {
+	e := eth.New()
+	e.HWSrc = hwSrc
+	e.HWDst = hwDst
+	if src.To4() != nil && dst.To4() != nil {
+		ip := ipv4.New()
+		ip.Version = 4
+		ip.TTL = 64
+		ip.Protocol = protocol
+		ip.NWSrc = src
+		ip.NWDst = dst
+		ip.Data = payload
+		e.Ethertype = eth.IPv4_MSG
+		e.Data = ip
+	} else {
+		ip := ipv6.New()
+		ip.NextHeader = protocol
+		ip.NWSrc = src
+		ip.NWDst = dst
+		ip.Data = payload
+		e.Ethertype = eth.IPv6_MSG
+		e.Data = ip
+	}
+	return e
+}
@@ -0,0 +1,51 @@
+package packet
+
+import (
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/ipv4"
+	"github.com/jonstout/ogo/protocol/udp"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+func TestSerialize(t *testing.T) {
+	b := "   00 00 00 00 00 02 00 00 00 00 00 01 08 00 " + // Ethernet
+		"45 00 00 21 00 00 00 00 40 11 66 ca " + // IPv4
+		"0a 00 00 01 0a 00 00 02 " +
+		"04 d2 27 0f 00 0d 7c 1e " + // UDP
+		"68 65 6c 6c 6f " // Data
+	b = strings.Replace(b, " ", "", -1)
+
+	u := udp.New()
+	u.PortSrc = 1234
+	u.PortDst = 9999
+	u.Data = util.NewBuffer([]byte("hello"))
+
+	ip := ipv4.New()
+	ip.Version = 4
+	ip.TTL = 64
+	ip.Protocol = ipv4.Type_UDP
+	ip.NWSrc = net.ParseIP("10.0.0.1")
+	ip.NWDst = net.ParseIP("10.0.0.2")
+	ip.Data = u
+
+	e := eth.New()
+	e.HWSrc[5] = 1
+	e.HWDst[5] = 2
+	e.Data = ip
+
+	data, err := Serialize(e, SerializeOptions{FixLengths: true, ComputeChecksums: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := hex.EncodeToString(data)
+	if (len(b) != len(d)) || (b != d) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", d)
+		t.Errorf("Received length of %d, expected %d", len(d), len(b))
+	}
+}
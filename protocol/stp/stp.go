@@ -0,0 +1,283 @@
+// Package stp implements the bridge protocol data units of the
+// Spanning Tree Protocol and Rapid Spanning Tree Protocol, 802.1D.
+//
+// Importing stp registers it as the payload of LLC frames sent to
+// SAP 0x42.
+package stp
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+func init() {
+	eth.RegisterSAP(eth.SAP_STP, func() util.Message { return new(BPDU) })
+}
+
+// Destination of BPDUs, the nearest customer bridge.
+var BridgeGroup = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x00}
+
+// Protocol versions
+const (
+	VERSION_STP  = 0
+	VERSION_RSTP = 2
+	VERSION_MSTP = 3
+)
+
+// BPDU types
+const (
+	Type_Config = 0x00
+	Type_RST    = 0x02
+	Type_TCN    = 0x80
+)
+
+// Flags
+const (
+	FLAG_TC         = 1 << 0 // Topology change
+	FLAG_PROPOSAL   = 1 << 1
+	FLAG_LEARNING   = 1 << 4
+	FLAG_FORWARDING = 1 << 5
+	FLAG_AGREEMENT  = 1 << 6
+	FLAG_TC_ACK     = 1 << 7 // Topology change acknowledgement
+	ROLE_MASK       = 0x0c
+	ROLE_SHIFT      = 2
+)
+
+// Port roles of RST BPDUs
+const (
+	ROLE_UNKNOWN    = 0
+	ROLE_ALTERNATE  = 1 // Alternate or backup
+	ROLE_ROOT       = 2
+	ROLE_DESIGNATED = 3
+)
+
+// Priority, with the system ID extension in the low 12 bits, and
+// MAC address of a bridge.
+type BridgeId struct {
+	Priority uint16
+	MAC      net.HardwareAddr
+}
+
+func NewBridgeId(priority uint16, mac net.HardwareAddr) BridgeId {
+	return BridgeId{priority, mac}
+}
+
+func (b *BridgeId) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 8)
+	binary.BigEndian.PutUint16(data, b.Priority)
+	copy(data[2:], b.MAC)
+	return
+}
+
+func (b *BridgeId) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return util.NewTruncatedError("BridgeId", 8, len(data))
+	}
+	b.Priority = binary.BigEndian.Uint16(data)
+	b.MAC = make(net.HardwareAddr, 6)
+	copy(b.MAC, data[2:])
+	return nil
+}
+
+// Returns the BPDU timer value of d, in 1/256ths of a second.
+func Timer(d time.Duration) uint16 {
+	return uint16(d * 256 / time.Second)
+}
+
+// Returns the duration of the BPDU timer value t.
+func Duration(t uint16) time.Duration {
+	return time.Duration(t) * time.Second / 256
+}
+
+// A configuration, RST or topology change notification BPDU.
+// Topology change notifications carry only ProtocolId, Version and
+// Type. Timers are in 1/256ths of a second.
+type BPDU struct {
+	ProtocolId   uint16
+	Version      uint8
+	Type         uint8
+	Flags        uint8
+	RootId       BridgeId
+	RootPathCost uint32
+	BridgeId     BridgeId
+	PortId       uint16
+	MessageAge   uint16
+	MaxAge       uint16
+	HelloTime    uint16
+	ForwardDelay uint16
+	// MSTP data following the RST fields, left undecoded.
+	Extra []byte
+}
+
+// Returns a configuration BPDU with the 802.1D default timers.
+func NewConfig(root BridgeId, cost uint32, bridge BridgeId, port uint16) *BPDU {
+	b := new(BPDU)
+	b.Version = VERSION_STP
+	b.Type = Type_Config
+	b.RootId = root
+	b.RootPathCost = cost
+	b.BridgeId = bridge
+	b.PortId = port
+	b.MaxAge = Timer(20 * time.Second)
+	b.HelloTime = Timer(2 * time.Second)
+	b.ForwardDelay = Timer(15 * time.Second)
+	return b
+}
+
+// Returns an RST BPDU with the 802.1D default timers.
+func NewRST(root BridgeId, cost uint32, bridge BridgeId, port uint16) *BPDU {
+	b := NewConfig(root, cost, bridge, port)
+	b.Version = VERSION_RSTP
+	b.Type = Type_RST
+	return b
+}
+
+// Returns a topology change notification.
+func NewTCN() *BPDU {
+	b := new(BPDU)
+	b.Version = VERSION_STP
+	b.Type = Type_TCN
+	return b
+}
+
+// Returns the port role of an RST BPDU.
+func (b *BPDU) Role() uint8 {
+	return b.Flags & ROLE_MASK >> ROLE_SHIFT
+}
+
+func (b *BPDU) SetRole(role uint8) {
+	b.Flags = b.Flags&^ROLE_MASK | role<<ROLE_SHIFT&ROLE_MASK
+}
+
+func (b *BPDU) Len() (n uint16) {
+	switch b.Type {
+	case Type_TCN:
+		return 4
+	case Type_RST:
+		return 36 + uint16(len(b.Extra))
+	}
+	return 35
+}
+
+func (b *BPDU) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(b.Len()))
+	n := 0
+	binary.BigEndian.PutUint16(data[n:], b.ProtocolId)
+	n += 2
+	data[n] = b.Version
+	n += 1
+	data[n] = b.Type
+	n += 1
+	if b.Type == Type_TCN {
+		return
+	}
+
+	data[n] = b.Flags
+	n += 1
+	var id []byte
+	if id, err = b.RootId.MarshalBinary(); err != nil {
+		return
+	}
+	copy(data[n:], id)
+	n += 8
+	binary.BigEndian.PutUint32(data[n:], b.RootPathCost)
+	n += 4
+	if id, err = b.BridgeId.MarshalBinary(); err != nil {
+		return
+	}
+	copy(data[n:], id)
+	n += 8
+	binary.BigEndian.PutUint16(data[n:], b.PortId)
+	n += 2
+	binary.BigEndian.PutUint16(data[n:], b.MessageAge)
+	n += 2
+	binary.BigEndian.PutUint16(data[n:], b.MaxAge)
+	n += 2
+	binary.BigEndian.PutUint16(data[n:], b.HelloTime)
+	n += 2
+	binary.BigEndian.PutUint16(data[n:], b.ForwardDelay)
+	n += 2
+	if b.Type == Type_RST {
+		// Version 1 length, always zero.
+		data[n] = 0
+		n += 1
+		copy(data[n:], b.Extra)
+	}
+	return
+}
+
+func (b *BPDU) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return util.NewTruncatedError("BPDU message", 4, len(data))
+	}
+	n := 0
+	b.ProtocolId = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	if b.ProtocolId != 0 {
+		return util.NewMalformedError("BPDU", "protocol identifier is not zero")
+	}
+	b.Version = data[n]
+	n += 1
+	b.Type = data[n]
+	n += 1
+	switch b.Type {
+	case Type_TCN:
+		return nil
+	case Type_Config, Type_RST:
+	default:
+		return util.NewMalformedError("BPDU", "unknown type "+strconv.Itoa(int(b.Type)))
+	}
+	b.Extra = nil
+	if len(data) < int(b.Len()) {
+		return util.NewTruncatedError("BPDU message", int(b.Len()), len(data))
+	}
+
+	b.Flags = data[n]
+	n += 1
+	if err := b.RootId.UnmarshalBinary(data[n:]); err != nil {
+		return err
+	}
+	n += 8
+	b.RootPathCost = binary.BigEndian.Uint32(data[n:])
+	n += 4
+	if err := b.BridgeId.UnmarshalBinary(data[n:]); err != nil {
+		return err
+	}
+	n += 8
+	b.PortId = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	b.MessageAge = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	b.MaxAge = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	b.HelloTime = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	b.ForwardDelay = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	if b.Type == Type_RST {
+		n += 1
+		if n < len(data) {
+			b.Extra = make([]byte, len(data)-n)
+			copy(b.Extra, data[n:])
+		}
+	}
+	return nil
+}
+
+// Returns an 802.3 frame from hwSrc carrying b to the bridge group
+// address.
+func NewFrame(hwSrc net.HardwareAddr, b *BPDU) *eth.Ethernet {
+	l := eth.NewLLC(eth.SAP_STP)
+	l.Data = b
+	e := eth.New()
+	e.HWSrc = hwSrc
+	e.HWDst = BridgeGroup
+	e.Data = l
+	e.FixLengths()
+	return e
+}
@@ -0,0 +1,97 @@
+package stp
+
+import (
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonstout/ogo/protocol/eth"
+)
+
+var rst = "   00 00 " + // ProtocolId
+	"02 " + // Version
+	"02 " + // Type
+	"3c " + // Flags
+	"80 00 00 11 22 33 44 55 " + // RootId
+	"00 00 00 04 " + // RootPathCost
+	"80 01 00 aa bb cc dd ee " + // BridgeId
+	"80 05 " + // PortId
+	"01 00 " + // MessageAge
+	"14 00 " + // MaxAge
+	"02 00 " + // HelloTime
+	"0f 00 " + // ForwardDelay
+	"00 " // Version1Length
+
+func TestBPDUMarshalBinary(t *testing.T) {
+	b := strings.Replace(rst, " ", "", -1)
+
+	root, _ := net.ParseMAC("00:11:22:33:44:55")
+	bridge, _ := net.ParseMAC("00:aa:bb:cc:dd:ee")
+	d := NewRST(NewBridgeId(0x8000, root), 4, NewBridgeId(0x8001, bridge), 0x8005)
+	d.MessageAge = Timer(time.Second)
+	d.Flags = FLAG_LEARNING | FLAG_FORWARDING
+	d.SetRole(ROLE_DESIGNATED)
+
+	data, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := hex.EncodeToString(data)
+	if (len(b) != len(s)) || (b != s) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", s)
+		t.Errorf("Received length of %d, expected %d", len(s), len(b))
+	}
+}
+
+func TestBPDUUnmarshalBinary(t *testing.T) {
+	data, _ := hex.DecodeString(strings.Replace(rst, " ", "", -1))
+
+	d := new(BPDU)
+	if err := d.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if int(d.Len()) != len(data) {
+		t.Errorf("Got length of %d, expected %d.", d.Len(), len(data))
+	}
+	if d.Role() != ROLE_DESIGNATED {
+		t.Errorf("Got role %d, expected %d.", d.Role(), ROLE_DESIGNATED)
+	}
+	if d.RootId.Priority != 0x8000 || d.RootId.MAC.String() != "00:11:22:33:44:55" {
+		t.Errorf("Got root %04x %s.", d.RootId.Priority, d.RootId.MAC)
+	}
+	if Duration(d.ForwardDelay) != 15*time.Second {
+		t.Errorf("Got forward delay %s, expected 15s.", Duration(d.ForwardDelay))
+	}
+
+	tcn, _ := hex.DecodeString("00000080")
+	if err := d.UnmarshalBinary(tcn); err != nil || d.Type != Type_TCN || d.Len() != 4 {
+		t.Errorf("Got TCN type %02x, length %d, error %v.", d.Type, d.Len(), err)
+	}
+}
+
+func TestBPDUFrame(t *testing.T) {
+	src, _ := net.ParseMAC("00:aa:bb:cc:dd:ee")
+	e := NewFrame(src, NewTCN())
+	if e.Ethertype != 7 {
+		t.Errorf("Got length %d, expected 7.", e.Ethertype)
+	}
+	data, err := e.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := eth.New()
+	if err := r.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	l, ok := r.Data.(*eth.LLC)
+	if !ok {
+		t.Fatalf("Got payload %T, expected *eth.LLC.", r.Data)
+	}
+	if d, ok := l.Data.(*BPDU); !ok || d.Type != Type_TCN {
+		t.Errorf("Got LLC payload %T, expected a TCN *BPDU.", l.Data)
+	}
+}
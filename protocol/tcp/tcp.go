@@ -1,88 +1,324 @@
 package tcp
 
 import (
-//	"bytes"
-//	"encoding/binary"
-//"io"
+	"encoding/binary"
+	"net"
+	"strconv"
+
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// IP protocol number of TCP.
+const Type_TCP = 0x06
+
+// TCP control flags.
+const (
+	FIN = 1 << 0
+	SYN = 1 << 1
+	RST = 1 << 2
+	PSH = 1 << 3
+	ACK = 1 << 4
+	URG = 1 << 5
+	ECE = 1 << 6
+	CWR = 1 << 7
+	NS  = 1 << 8
 )
 
 type TCP struct {
-	PortSrc uint16
-	PortDst uint16
-	SeqNum  uint32
-	AckNum  uint32
+	PortSrc    uint16
+	PortDst    uint16
+	SeqNum     uint32
+	AckNum     uint32
+	DataOffset uint8  //4-bits, header length in 32-bit words
+	Flags      uint16 //9-bits
+	WinSize    uint16
+	Checksum   uint16
+	UrgPtr     uint16
+	Options    []Option
+	Data       []byte
+}
 
-	WinSize  uint16
-	Checksum uint16
-	UrgFlag  uint16
+func New() *TCP {
+	t := new(TCP)
+	t.DataOffset = 5
+	t.Options = make([]Option, 0)
+	t.Data = make([]byte, 0)
+	return t
+}
 
-	Data []byte
+// Returns true if all of flags are set.
+func (t *TCP) HasFlags(flags uint16) bool {
+	return t.Flags&flags == flags
 }
 
-/*
-func (u *UDP) Len() (n uint16) {
-	if u.Data != nil {
-		return uint16(8 + len(u.Data))
+// Returns the length of the header including options and their
+// padding.
+func (t *TCP) HeaderLen() (n uint16) {
+	n = 20
+	for _, o := range t.Options {
+		n += o.Len()
 	}
-	return uint16(8)
+	return (n + 3) &^ 3
 }
 
-func (u *UDP) Read(b []byte) (n int, err error) {
-	buf := new(bytes.Buffer)
-	binary.Write(buf, binary.BigEndian, u.PortSrc)
-	binary.Write(buf, binary.BigEndian, u.PortDst)
-	binary.Write(buf, binary.BigEndian, u.Length)
-	binary.Write(buf, binary.BigEndian, u.Checksum)
-	binary.Write(buf, binary.BigEndian, u.Data)
-	if n, err = buf.Read(b); n == 0 {
-		return
+func (t *TCP) Len() (n uint16) {
+	return t.HeaderLen() + uint16(len(t.Data))
+}
+
+// Sets DataOffset from the current options.
+func (t *TCP) MarshalBinary() (data []byte, err error) {
+	t.DataOffset = uint8(t.HeaderLen() / 4)
+	data = make([]byte, int(t.Len()))
+	n := 0
+	binary.BigEndian.PutUint16(data[n:], t.PortSrc)
+	n += 2
+	binary.BigEndian.PutUint16(data[n:], t.PortDst)
+	n += 2
+	binary.BigEndian.PutUint32(data[n:], t.SeqNum)
+	n += 4
+	binary.BigEndian.PutUint32(data[n:], t.AckNum)
+	n += 4
+	data[n] = t.DataOffset<<4 | uint8(t.Flags>>8&0x01)
+	n += 1
+	data[n] = uint8(t.Flags)
+	n += 1
+	binary.BigEndian.PutUint16(data[n:], t.WinSize)
+	n += 2
+	binary.BigEndian.PutUint16(data[n:], t.Checksum)
+	n += 2
+	binary.BigEndian.PutUint16(data[n:], t.UrgPtr)
+	n += 2
+
+	for _, o := range t.Options {
+		b, err := o.MarshalBinary()
+		if err != nil {
+			return data, err
+		}
+		copy(data[n:], b)
+		n += len(b)
 	}
-	return n, io.EOF
+	// Options are padded with zeros, which is OPT_EOL.
+	n = int(t.HeaderLen())
+	copy(data[n:], t.Data)
+	return
 }
 
-func (u *UDP) ReadFrom(r io.Reader) (n int64, err error) {
-	if err = binary.Read(r, binary.BigEndian, &u.PortSrc); err != nil {
-		return
+func (t *TCP) UnmarshalBinary(data []byte) error {
+	if len(data) < 20 {
+		return util.NewTruncatedError("TCP message", 20, len(data))
 	}
+	n := 0
+	t.PortSrc = binary.BigEndian.Uint16(data[n:])
 	n += 2
-	if err = binary.Read(r, binary.BigEndian, &u.PortDst); err != nil {
-		return
-	}
+	t.PortDst = binary.BigEndian.Uint16(data[n:])
 	n += 2
-	if err = binary.Read(r, binary.BigEndian, &u.Length); err != nil {
-		return
-	}
+	t.SeqNum = binary.BigEndian.Uint32(data[n:])
+	n += 4
+	t.AckNum = binary.BigEndian.Uint32(data[n:])
+	n += 4
+	t.DataOffset = data[n] >> 4
+	t.Flags = uint16(data[n]&0x01)<<8 | uint16(data[n+1])
 	n += 2
-	if err = binary.Read(r, binary.BigEndian, &u.Checksum); err != nil {
-		return
-	}
+	t.WinSize = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	t.Checksum = binary.BigEndian.Uint16(data[n:])
+	n += 2
+	t.UrgPtr = binary.BigEndian.Uint16(data[n:])
 	n += 2
+
+	hdr := int(t.DataOffset) * 4
+	if hdr < 20 || hdr > len(data) {
+		return util.NewMalformedError("TCP message", "data offset "+strconv.Itoa(hdr)+" does not fit "+strconv.Itoa(len(data))+" bytes")
+	}
+
+	t.Options = make([]Option, 0)
+	for n < hdr {
+		o := Option{}
+		if err := o.UnmarshalBinary(data[n:hdr]); err != nil {
+			return err
+		}
+		if o.Type == OPT_EOL {
+			break
+		}
+		t.Options = append(t.Options, o)
+		n += int(o.Len())
+	}
+
+	t.Data = make([]byte, len(data)-hdr)
+	copy(t.Data, data[hdr:])
+	return nil
+}
+
+// Returns the checksum of the segment sent from src to dst. The
+// Checksum field is ignored.
+func (t *TCP) ComputeChecksum(src, dst net.IP) (uint16, error) {
+	data, err := t.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	data[16], data[17] = 0, 0
+	return util.PseudoHeaderChecksum(src, dst, Type_TCP, data), nil
+}
+
+// Computes and sets the checksum of the segment sent from src to
+// dst.
+func (t *TCP) SetChecksum(src, dst net.IP) error {
+	c, err := t.ComputeChecksum(src, dst)
+	t.Checksum = c
+	return err
+}
+
+// TCP option kinds.
+const (
+	OPT_EOL            = 0
+	OPT_NOP            = 1
+	OPT_MSS            = 2
+	OPT_WINDOW_SCALE   = 3
+	OPT_SACK_PERMITTED = 4
+	OPT_SACK           = 5
+	OPT_TIMESTAMPS     = 8
+)
+
+// A TCP option. OPT_EOL and OPT_NOP are a single byte; every other
+// kind is followed by its length and Data.
+type Option struct {
+	Type uint8
+	Data []byte
+}
+
+func NewOptionNOP() Option {
+	return Option{OPT_NOP, nil}
+}
+
+func NewOptionMSS(mss uint16) Option {
+	o := Option{OPT_MSS, make([]byte, 2)}
+	binary.BigEndian.PutUint16(o.Data, mss)
+	return o
+}
+
+func NewOptionWindowScale(shift uint8) Option {
+	return Option{OPT_WINDOW_SCALE, []byte{shift}}
+}
+
+func NewOptionSACKPermitted() Option {
+	return Option{OPT_SACK_PERMITTED, []byte{}}
+}
+
+// A block of data received out of order, from Left up to but not
+// including Right.
+type SACKBlock struct {
+	Left  uint32
+	Right uint32
+}
+
+func NewOptionSACK(blocks ...SACKBlock) Option {
+	o := Option{OPT_SACK, make([]byte, 8*len(blocks))}
+	for i, b := range blocks {
+		binary.BigEndian.PutUint32(o.Data[i*8:], b.Left)
+		binary.BigEndian.PutUint32(o.Data[i*8+4:], b.Right)
+	}
+	return o
+}
+
+func NewOptionTimestamps(val, echo uint32) Option {
+	o := Option{OPT_TIMESTAMPS, make([]byte, 8)}
+	binary.BigEndian.PutUint32(o.Data[:4], val)
+	binary.BigEndian.PutUint32(o.Data[4:], echo)
+	return o
+}
+
+func (o *Option) Len() (n uint16) {
+	if o.Type == OPT_EOL || o.Type == OPT_NOP {
+		return 1
+	}
+	return uint16(2 + len(o.Data))
+}
+
+func (o *Option) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, int(o.Len()))
+	data[0] = o.Type
+	if len(data) > 1 {
+		data[1] = uint8(len(data))
+		copy(data[2:], o.Data)
+	}
 	return
 }
 
-func (u *UDP) Write(b []byte) (n int, err error) {
-	buf := bytes.NewBuffer(b)
-	if err = binary.Read(buf, binary.BigEndian, &u.PortSrc); err != nil {
-		return
+func (o *Option) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return util.NewTruncatedError("TCP option", 1, len(data))
 	}
-	n += 2
-	if err = binary.Read(buf, binary.BigEndian, &u.PortDst); err != nil {
-		return
+	o.Type = data[0]
+	o.Data = nil
+	if o.Type == OPT_EOL || o.Type == OPT_NOP {
+		return nil
 	}
-	n += 2
-	if err = binary.Read(buf, binary.BigEndian, &u.Length); err != nil {
-		return
+	if len(data) < 2 {
+		return util.NewTruncatedError("TCP option", 2, len(data))
 	}
-	n += 2
-	if err = binary.Read(buf, binary.BigEndian, &u.Checksum); err != nil {
-		return
+	if data[1] < 2 {
+		return util.NewMalformedError("TCP option", "length is less than 2")
 	}
-	n += 2
-	u.Data = make([]byte, len(b)-n)
-	if err = binary.Read(buf, binary.BigEndian, &u.Data); err != nil {
+	if int(data[1]) > len(data) {
+		return util.NewTruncatedError("TCP option", int(data[1]), len(data))
+	}
+	o.Data = make([]byte, int(data[1])-2)
+	copy(o.Data, data[2:])
+	return nil
+}
+
+// Returns the first option of kind typ.
+func (t *TCP) Option(typ uint8) (o Option, ok bool) {
+	for _, o = range t.Options {
+		if o.Type == typ {
+			return o, true
+		}
+	}
+	return
+}
+
+// Returns the maximum segment size option.
+func (t *TCP) MSS() (mss uint16, ok bool) {
+	if o, ok := t.Option(OPT_MSS); ok && len(o.Data) == 2 {
+		return binary.BigEndian.Uint16(o.Data), true
+	}
+	return
+}
+
+// Returns the window scale option.
+func (t *TCP) WindowScale() (shift uint8, ok bool) {
+	if o, ok := t.Option(OPT_WINDOW_SCALE); ok && len(o.Data) == 1 {
+		return o.Data[0], true
+	}
+	return
+}
+
+// Returns true if the segment permits selective acknowledgements.
+func (t *TCP) SACKPermitted() bool {
+	_, ok := t.Option(OPT_SACK_PERMITTED)
+	return ok
+}
+
+// Returns the blocks of the selective acknowledgement option.
+func (t *TCP) SACK() (blocks []SACKBlock) {
+	o, ok := t.Option(OPT_SACK)
+	if !ok {
 		return
 	}
-	n += len(u.Data)
+	for i := 0; i+8 <= len(o.Data); i += 8 {
+		blocks = append(blocks, SACKBlock{
+			binary.BigEndian.Uint32(o.Data[i:]),
+			binary.BigEndian.Uint32(o.Data[i+4:]),
+		})
+	}
+	return
+}
+
+// Returns the timestamp value and echo reply of the timestamps
+// option.
+func (t *TCP) Timestamps() (val, echo uint32, ok bool) {
+	if o, ok := t.Option(OPT_TIMESTAMPS); ok && len(o.Data) == 8 {
+		return binary.BigEndian.Uint32(o.Data[:4]), binary.BigEndian.Uint32(o.Data[4:]), true
+	}
 	return
 }
-*/
@@ -0,0 +1,84 @@
+package tcp
+
+import (
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestTCPMarshalBinary(t *testing.T) {
+	b := "   c0 01 00 50 " + // PortSrc, PortDst
+		"00 00 03 e8 " + // SeqNum
+		"00 00 00 00 " + // AckNum
+		"a0 02 72 10 " + // DataOffset, Flags, WinSize
+		"fd b2 00 00 " + // Checksum, UrgPtr
+		"02 04 05 b4 " + // MSS
+		"04 02 " + // SACK permitted
+		"08 0a 00 00 00 01 00 00 00 00 " + // Timestamps
+		"01 " + // NOP
+		"03 03 07 " // Window scale
+	b = strings.Replace(b, " ", "", -1)
+
+	s := New()
+	s.PortSrc = 49153
+	s.PortDst = 80
+	s.SeqNum = 1000
+	s.Flags = SYN
+	s.WinSize = 0x7210
+	s.Options = append(s.Options,
+		NewOptionMSS(1460),
+		NewOptionSACKPermitted(),
+		NewOptionTimestamps(1, 0),
+		NewOptionNOP(),
+		NewOptionWindowScale(7))
+	s.SetChecksum(net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"))
+
+	data, _ := s.MarshalBinary()
+	d := hex.EncodeToString(data)
+	if (len(b) != len(d)) || (b != d) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", d)
+		t.Errorf("Received length of %d, expected %d", len(d), len(b))
+	}
+}
+
+func TestTCPUnmarshalBinary(t *testing.T) {
+	b := "   00 50 c0 01 " + // PortSrc, PortDst
+		"00 00 07 d0 " + // SeqNum
+		"00 00 03 e9 " + // AckNum
+		"80 18 01 f5 " + // DataOffset, Flags, WinSize
+		"00 00 00 00 " + // Checksum, UrgPtr
+		"01 01 05 0a 00 00 03 e9 00 00 03 f9 " + // NOP, NOP, SACK
+		"68 69 " // Data
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	s := New()
+	if err := s.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if s.DataOffset != 8 || !s.HasFlags(ACK|PSH) || s.HasFlags(SYN) {
+		t.Errorf("Got offset %d flags %x, expected 8 and ACK|PSH.", s.DataOffset, s.Flags)
+	}
+	if len(s.Options) != 3 {
+		t.Fatalf("Got %d options, expected 3.", len(s.Options))
+	}
+	sack := s.SACK()
+	if len(sack) != 1 || sack[0].Left != 1001 || sack[0].Right != 1017 {
+		t.Errorf("Got sack %v, expected [{1001 1017}].", sack)
+	}
+	if _, ok := s.MSS(); ok {
+		t.Error("Got an MSS option, expected none.")
+	}
+	if string(s.Data) != "hi" {
+		t.Errorf("Got data %q, expected %q.", s.Data, "hi")
+	}
+
+	d, _ := s.MarshalBinary()
+	if hex.EncodeToString(d) != b {
+		t.Log("Exp:", b)
+		t.Log("Rec:", hex.EncodeToString(d))
+		t.Error("TCP did not marshal back to its original bytes.")
+	}
+}
@@ -2,51 +2,138 @@ package udp
 
 import (
 	"encoding/binary"
-	"errors"
+	"hash/fnv"
+	"net"
+	"sync"
+
+	"github.com/jonstout/ogo/protocol/util"
 )
 
+// IP protocol number of UDP.
+const Type_UDP = 0x11
+
+var ports = struct {
+	sync.RWMutex
+	m map[uint16]func() util.Message
+}{m: make(map[uint16]func() util.Message)}
+
+// Registers fn as the constructor of the payload of datagrams sent
+// to or from port, replacing any earlier registration. The
+// destination port is tried first. Payloads with no registration
+// decode as a util.Buffer.
+func RegisterPort(port uint16, fn func() util.Message) {
+	ports.Lock()
+	defer ports.Unlock()
+	ports.m[port] = fn
+}
+
+func newPayload(dst, src uint16) util.Message {
+	ports.RLock()
+	defer ports.RUnlock()
+	if fn, ok := ports.m[dst]; ok {
+		return fn()
+	}
+	if fn, ok := ports.m[src]; ok {
+		return fn()
+	}
+	return new(util.Buffer)
+}
+
 type UDP struct {
 	PortSrc  uint16
 	PortDst  uint16
 	Length   uint16
 	Checksum uint16
-	Data     []byte
+	Data     util.Message
 }
 
 func New() *UDP {
 	u := new(UDP)
-	u.Data = make([]byte, 0)
+	u.Data = new(util.Buffer)
 	return u
 }
 
 func (u *UDP) Len() (n uint16) {
 	if u.Data != nil {
-		return uint16(8 + len(u.Data))
+		return 8 + u.Data.Len()
 	}
 	return uint16(8)
 }
 
 func (u *UDP) MarshalBinary() (data []byte, err error) {
-	data = make([]byte, int(u.Len()))
+	data = make([]byte, 8, int(u.Len()))
 	binary.BigEndian.PutUint16(data[:2], u.PortSrc)
 	binary.BigEndian.PutUint16(data[2:4], u.PortDst)
 	binary.BigEndian.PutUint16(data[4:6], u.Length)
 	binary.BigEndian.PutUint16(data[6:8], u.Checksum)
-	copy(data[8:], u.Data)
+	if u.Data != nil {
+		var b []byte
+		if b, err = u.Data.MarshalBinary(); err != nil {
+			return
+		}
+		data = append(data, b...)
+	}
 	return
 }
 
 func (u *UDP) UnmarshalBinary(data []byte) error {
 	if len(data) < 8 {
-		return errors.New("The []byte is too short to unmarshal a full ARP message.")
+		u.Data = nil
+		return util.NewTruncatedError("UDP message", 8, len(data))
 	}
 	u.PortSrc = binary.BigEndian.Uint16(data[:2])
 	u.PortDst = binary.BigEndian.Uint16(data[2:4])
 	u.Length = binary.BigEndian.Uint16(data[4:6])
 	u.Checksum = binary.BigEndian.Uint16(data[6:8])
 
-	for n, _ := range data[8:] {
-		u.Data = append(u.Data, data[n])
+	// Frames may be padded past the end of the datagram.
+	end := int(u.Length)
+	if end < 8 || end > len(data) {
+		end = len(data)
 	}
+	u.Data = newPayload(u.PortDst, u.PortSrc)
+	return u.Data.UnmarshalBinary(data[8:end])
+}
+
+// Sets Length from the payload.
+func (u *UDP) FixLengths() error {
+	u.Length = u.Len()
 	return nil
 }
+
+// Returns the checksum of the datagram sent from src to dst. The
+// Checksum field is ignored.
+func (u *UDP) ComputeChecksum(src, dst net.IP) (uint16, error) {
+	data, err := u.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	data[6], data[7] = 0, 0
+	c := util.PseudoHeaderChecksum(src, dst, Type_UDP, data)
+	// A zero checksum means none was computed.
+	if c == 0 {
+		c = 0xffff
+	}
+	return c, nil
+}
+
+// Computes and sets the checksum of the datagram sent from src to
+// dst.
+func (u *UDP) SetChecksum(src, dst net.IP) error {
+	c, err := u.ComputeChecksum(src, dst)
+	u.Checksum = c
+	return err
+}
+
+func (u *UDP) Payload() util.Message {
+	return u.Data
+}
+
+// Returns a port in the dynamic range, 49152 to 65535, derived from
+// key. Tunnels such as VXLAN and Geneve put a hash of the inner flow
+// in the source port so that routers spread flows across paths.
+func EntropyPort(key []byte) uint16 {
+	h := fnv.New32a()
+	h.Write(key)
+	return uint16(49152 + h.Sum32()%16384)
+}
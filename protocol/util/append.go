@@ -0,0 +1,36 @@
+package util
+
+// Implemented by messages that can marshal themselves onto the end
+// of an existing slice, reusing its capacity instead of allocating.
+type Appender interface {
+	AppendBinary(b []byte) ([]byte, error)
+}
+
+// Appends the encoding of m to b. Messages that are not Appenders
+// are marshaled and copied.
+func AppendBinary(b []byte, m Message) ([]byte, error) {
+	if a, ok := m.(Appender); ok {
+		return a.AppendBinary(b)
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		return b, err
+	}
+	return append(b, data...), nil
+}
+
+// Marshals m into the start of data and returns the number of bytes
+// written. Data must hold at least m.Len() bytes.
+func MarshalTo(data []byte, m Message) (n int, err error) {
+	if len(data) < int(m.Len()) {
+		return 0, NewTruncatedError("message buffer", int(m.Len()), len(data))
+	}
+	b, err := AppendBinary(data[:0], m)
+	return len(b), err
+}
+
+// Returns b extended by n zero bytes, which reallocates only when b
+// lacks the capacity.
+func Extend(b []byte, n int) []byte {
+	return append(b, make([]byte, n)...)
+}
@@ -20,6 +20,10 @@ func (b *Buffer) MarshalBinary() (data []byte, err error) {
 	return b.Buffer.Bytes(), nil
 }
 
+func (b *Buffer) AppendBinary(data []byte) ([]byte, error) {
+	return append(data, b.Buffer.Bytes()...), nil
+}
+
 func (b *Buffer) UnmarshalBinary(data []byte) error {
 	b.Buffer.Reset()
 	_, err := b.Buffer.Write(data)
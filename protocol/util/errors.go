@@ -0,0 +1,43 @@
+package util
+
+import "strconv"
+
+// Returned by UnmarshalBinary when data ends before the message it
+// holds does.
+type TruncatedError struct {
+	Message string // What was being unmarshaled, such as "Ethernet message".
+	Need    int    // Bytes needed, at least.
+	Have    int
+}
+
+func NewTruncatedError(message string, need, have int) *TruncatedError {
+	return &TruncatedError{message, need, have}
+}
+
+func (e *TruncatedError) Error() string {
+	return "The []byte is too short to unmarshal a full " + e.Message +
+		" (" + strconv.Itoa(e.Have) + " of " + strconv.Itoa(e.Need) + " bytes)."
+}
+
+// Returns a TruncatedError unless data holds at least need bytes.
+func CheckLen(message string, data []byte, need int) error {
+	if len(data) < need {
+		return NewTruncatedError(message, need, len(data))
+	}
+	return nil
+}
+
+// Returned by UnmarshalBinary when a length, count or type field
+// disagrees with the data it describes.
+type MalformedError struct {
+	Message string // What was being unmarshaled, such as "LLDP TLV".
+	Reason  string
+}
+
+func NewMalformedError(message, reason string) *MalformedError {
+	return &MalformedError{message, reason}
+}
+
+func (e *MalformedError) Error() string {
+	return "Malformed " + e.Message + ": " + e.Reason + "."
+}
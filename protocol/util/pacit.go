@@ -1,5 +1,10 @@
 package util
 
+import (
+	"encoding/binary"
+	"net"
+)
+
 type Message interface {
 	//encoding.BinaryMarshaler
 	//encoding.BinaryUnmarshaler
@@ -23,3 +28,26 @@ func Checksum(b []byte) uint16 {
 	s = ^s & 0xffff
 	return uint16(s<<8 | s>>(16-8))
 }
+
+// Returns the checksum of a TCP or UDP segment carried between src
+// and dst with IP protocol number proto. The checksum covers the
+// IPv4 pseudo-header, or the IPv6 one if either address is not an
+// IPv4 address, followed by segment. The segment's own checksum
+// field must be zero.
+func PseudoHeaderChecksum(src, dst net.IP, proto uint8, segment []byte) uint16 {
+	var b []byte
+	if src.To4() != nil && dst.To4() != nil {
+		b = make([]byte, 12, 12+len(segment))
+		copy(b[0:4], src.To4())
+		copy(b[4:8], dst.To4())
+		b[9] = proto
+		binary.BigEndian.PutUint16(b[10:12], uint16(len(segment)))
+	} else {
+		b = make([]byte, 40, 40+len(segment))
+		copy(b[0:16], src.To16())
+		copy(b[16:32], dst.To16())
+		binary.BigEndian.PutUint32(b[32:36], uint32(len(segment)))
+		b[39] = proto
+	}
+	return Checksum(append(b, segment...))
+}
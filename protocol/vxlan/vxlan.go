@@ -0,0 +1,100 @@
+// Package vxlan implements Virtual eXtensible LANs, RFC 7348.
+//
+// Importing vxlan registers it as the payload of UDP port 4789.
+package vxlan
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/packet"
+	"github.com/jonstout/ogo/protocol/udp"
+	"github.com/jonstout/ogo/protocol/util"
+)
+
+// UDP port of VXLAN.
+const Port = 4789
+
+func init() {
+	udp.RegisterPort(Port, func() util.Message { return New() })
+}
+
+// Set when VNI is valid.
+const FLAG_VNI = 0x08
+
+type VXLAN struct {
+	Flags uint8
+	VNI   uint32 //24-bits
+	Data  util.Message
+}
+
+func New() *VXLAN {
+	v := new(VXLAN)
+	v.Flags = FLAG_VNI
+	v.Data = eth.New()
+	return v
+}
+
+// Returns a VXLAN packet carrying inner on network vni.
+func NewVXLAN(vni uint32, inner *eth.Ethernet) *VXLAN {
+	v := new(VXLAN)
+	v.Flags = FLAG_VNI
+	v.VNI = vni & 0xffffff
+	v.Data = inner
+	return v
+}
+
+func (v *VXLAN) Len() (n uint16) {
+	n = 8
+	if v.Data != nil {
+		n += v.Data.Len()
+	}
+	return
+}
+
+func (v *VXLAN) MarshalBinary() (data []byte, err error) {
+	data = make([]byte, 8, int(v.Len()))
+	data[0] = v.Flags
+	binary.BigEndian.PutUint32(data[4:], v.VNI<<8)
+	if v.Data != nil {
+		var b []byte
+		if b, err = v.Data.MarshalBinary(); err != nil {
+			return
+		}
+		data = append(data, b...)
+	}
+	return
+}
+
+func (v *VXLAN) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		v.Data = nil
+		return util.NewTruncatedError("VXLAN message", 8, len(data))
+	}
+	v.Flags = data[0]
+	v.VNI = binary.BigEndian.Uint32(data[4:]) >> 8
+	v.Data = eth.New()
+	return v.Data.UnmarshalBinary(data[8:])
+}
+
+func (v *VXLAN) Payload() util.Message {
+	return v.Data
+}
+
+// Returns a frame from hwSrc to hwDst carrying v in a UDP datagram
+// from src to dst. The source port is derived from the inner frame
+// so that routers keep its flows on one path. Lengths and checksums
+// are left for packet.Serialize.
+func NewFrame(hwSrc, hwDst net.HardwareAddr, src, dst net.IP, v *VXLAN) *eth.Ethernet {
+	var key []byte
+	if e, ok := v.Data.(*eth.Ethernet); ok {
+		key = append(key, e.HWSrc...)
+		key = append(key, e.HWDst...)
+	}
+	u := udp.New()
+	u.PortSrc = udp.EntropyPort(key)
+	u.PortDst = Port
+	u.Data = v
+	return packet.NewIPFrame(hwSrc, hwDst, src, dst, udp.Type_UDP, u)
+}
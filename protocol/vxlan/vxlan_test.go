@@ -0,0 +1,59 @@
+package vxlan
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/jonstout/ogo/protocol/eth"
+	"github.com/jonstout/ogo/protocol/packet"
+)
+
+func TestVXLANUnmarshalBinary(t *testing.T) {
+	b := "   00 00 00 00 00 02 00 00 00 00 00 01 08 00 " + // Ethernet
+		"45 00 00 3c 00 00 00 00 40 11 00 00 " + // IPv4
+		"0a 00 00 01 0a 00 00 02 " +
+		"c0 00 12 b5 00 28 00 00 " + // UDP to port 4789
+		"08 00 00 00 00 13 88 00 " + // VXLAN, VNI 5000
+		"00 00 00 00 00 bb 00 00 00 00 00 aa 88 00 " + // Inner Ethernet
+		"01 02 03 04 05 06 " // Data
+	b = strings.Replace(b, " ", "", -1)
+	data, _ := hex.DecodeString(b)
+
+	p := packet.Decode(data)
+	if p.Err != nil {
+		t.Fatal(p.Err)
+	}
+	var v *VXLAN
+	if !p.Layer(&v) || v.VNI != 5000 || v.Flags != FLAG_VNI {
+		t.Fatalf("Got VXLAN layer %v, expected VNI 5000.", v)
+	}
+	inner, ok := v.Data.(*eth.Ethernet)
+	if !ok || inner.HWDst.String() != "00:00:00:00:00:bb" || inner.Ethertype != 0x8800 {
+		t.Errorf("Got inner frame %v.", v.Data)
+	}
+	if len(p.Layers) != 6 {
+		t.Errorf("Got %d layers, expected 6.", len(p.Layers))
+	}
+}
+
+func TestVXLANMarshalBinary(t *testing.T) {
+	b := "   08 00 00 00 00 13 88 00 " + // VXLAN, VNI 5000
+		"00 00 00 00 00 bb 00 00 00 00 00 aa 88 00 " // Inner Ethernet
+	b = strings.Replace(b, " ", "", -1)
+
+	inner := eth.New()
+	inner.HWSrc[5] = 0xaa
+	inner.HWDst[5] = 0xbb
+	inner.Ethertype = 0x8800
+	data, err := NewVXLAN(5000, inner).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := hex.EncodeToString(data)
+	if (len(b) != len(d)) || (b != d) {
+		t.Log("Exp:", b)
+		t.Log("Rec:", d)
+		t.Errorf("Received length of %d, expected %d", len(d), len(b))
+	}
+}
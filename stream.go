@@ -1,33 +1,34 @@
 package ogo
 
 import (
-	"encoding/binary"
 	"github.com/jonstout/ogo/protocol/ofp"
+	"github.com/jonstout/ogo/protocol/ofp10"
+	"github.com/jonstout/ogo/protocol/ofpxx"
 	"github.com/jonstout/ogo/protocol/util"
 	"log"
 	"net"
-	"bytes"
+	"time"
 )
 
-type BufferPool struct {
-	Empty chan *bytes.Buffer
-	Full chan *bytes.Buffer
-}
-
-func NewBufferPool() *BufferPool {
-	m := new(BufferPool)
-	m.Empty = make(chan *bytes.Buffer, 50)
-	m.Full = make(chan *bytes.Buffer, 50)
+const (
+	// How long to wait for the rest of a multipart stats reply.
+	multipartTimeout = 30 * time.Second
+	// Most multipart stats replies merged at once per stream.
+	multipartMaxReplies = 16
+)
 
-	for i := 0; i < 50; i++ {
-		m.Empty <- bytes.NewBuffer(make([]byte, 0, 2048))
-	}
-	return m
+// A multipart stats reply waiting for its final fragment.
+type multipartReply struct {
+	reply *ofp10.StatsReply
+	deadline time.Time
 }
 
 type MessageStream struct {
-	conn *net.TCPConn
-	pool *BufferPool
+	conn net.Conn
+	dec *ofp.Decoder
+	enc *ofp.Encoder
+	// Xids allocated for messages sent on this connection
+	xids ofpxx.XidSpace
 	// OpenFlow Version
 	Version uint8
 	// Channel on which to publish connection errors
@@ -38,27 +39,29 @@ type MessageStream struct {
 	Outbound chan util.Message
 	// Channel on which to receive a shutdown command
 	Shutdown chan bool
+	// Unfinished multipart stats replies, keyed by Xid
+	fragments map[uint32]*multipartReply
 }
 
 // Returns a pointer to a new MessageStream. Used to parse
-// OpenFlow messages from conn.
-func NewMessageStream(conn *net.TCPConn) *MessageStream {
+// OpenFlow messages from conn, which may be any stream
+// connection such as TCP, TLS or a unix socket.
+func NewMessageStream(conn net.Conn) *MessageStream {
 	m := &MessageStream{
 		conn,
-		NewBufferPool(),
+		ofp.NewDecoder(conn),
+		ofp.NewEncoder(conn),
+		ofpxx.XidSpace{},
 		0,
 		make(chan error, 1),        // Error
 		make(chan util.Message, 1), // Inbound
 		make(chan util.Message, 1), // Outbound
 		make(chan bool, 1),         // Shutdown
+		make(map[uint32]*multipartReply),
 	}
 
 	go m.outbound()
 	go m.inbound()
-
-	for i := 0; i < 25; i++ {
-		go m.parse()
-	}
 	return m
 }
 
@@ -66,6 +69,12 @@ func (m *MessageStream) GetAddr() net.Addr {
 	return m.conn.RemoteAddr()
 }
 
+// Returns the next unused Xid on this connection. Safe to call
+// from any goroutine.
+func (m *MessageStream) NextXid() uint32 {
+	return m.xids.Next()
+}
+
 // Listen for a Shutdown signal or Outbound messages.
 func (m *MessageStream) outbound() {
 	for {
@@ -76,8 +85,7 @@ func (m *MessageStream) outbound() {
 			return
 		case msg := <-m.Outbound:
 			// Forward outbound messages to conn
-			data, _ := msg.MarshalBinary()
-			if _, err := m.conn.Write(data); err != nil {
+			if err := m.enc.Encode(msg); err != nil {
 				log.Println("OutboundError:", err)
 				m.Error <- err
 				m.Shutdown <- true
@@ -86,57 +94,78 @@ func (m *MessageStream) outbound() {
 	}
 }
 
+// Decodes messages from conn and publishes them in the order
+// they arrive.
 func (m *MessageStream) inbound() {
-	msg := 0
-	hdr := 0
-	hdrBuf := make([]byte, 4)
-
-	tmp := make([]byte, 2048)
-	buf := <- m.pool.Empty
 	for {
-		n, err := m.conn.Read(tmp)
-		if err != nil {
+		msg, err := m.dec.Decode()
+		if _, ok := err.(*ofp.ParseError); ok {
+			// Log all message parsing errors, but still
+			// publish what was parsed, such as a PacketIn
+			// whose packet was cut short by the switch.
+			log.Print(err)
+			if msg == nil {
+				continue
+			}
+		} else if err != nil {
 			log.Println("InboundError", err)
 			m.Error <- err
 			m.Shutdown <- true
 			return
-		}		
-		
-		for i := 0; i < n; i++ {
-			if hdr < 4 {
-				hdrBuf[hdr] = tmp[i]
-				buf.WriteByte(tmp[i])
-				hdr += 1
-				if hdr >= 4 {
-					msg = int(binary.BigEndian.Uint16(hdrBuf[2:])) - 4
-				}
-				continue
-			}
-			if msg > 0 {
-				buf.WriteByte(tmp[i])
-				msg = msg - 1
-				if msg == 0 {
-					hdr = 0
-					m.pool.Full <- buf
-					buf = <- m.pool.Empty
-				}
-				continue
-			}
 		}
+
+		if m.multipart(msg) {
+			continue
+		}
+		m.Inbound <- msg
 	}
 }
 
-func (m *MessageStream) parse() {
-	for {
-		b := <- m.pool.Full
-		msg, err := ofp.Parse(b.Bytes())
-		// Log all message parsing errors.
-		if err != nil {
-			log.Print(err)
+// Holds back stats replies that have SF_REPLY_MORE set until the
+// final reply with the same Xid arrives, then publishes them
+// merged into a single StatsReply. Returns true if msg was
+// consumed.
+//
+// Replies still waiting after multipartTimeout are dropped. A
+// reply that would grow too long for a single message is
+// published as it is, with SF_REPLY_MORE still set, and merging
+// starts over. When multipartMaxReplies are already waiting, new
+// replies are published without being merged.
+func (m *MessageStream) multipart(msg util.Message) bool {
+	now := time.Now()
+	for xid, p := range m.fragments {
+		if now.After(p.deadline) {
+			log.Println("Dropping multipart stats reply", xid, "which timed out.")
+			delete(m.fragments, xid)
 		}
-		
-		m.Inbound <- msg
-		b.Reset()
-		m.pool.Empty <- b
 	}
+	r, ok := msg.(*ofp10.StatsReply)
+	if !ok {
+		return false
+	}
+
+	more := r.Flags&ofp10.SF_REPLY_MORE != 0
+	p, ok := m.fragments[r.Xid]
+	if !ok {
+		if !more || len(m.fragments) >= multipartMaxReplies {
+			return false
+		}
+		m.fragments[r.Xid] = &multipartReply{r, now.Add(multipartTimeout)}
+		return true
+	}
+
+	if err := p.reply.Append(r); err != nil {
+		// Publish what was merged so far, then start over
+		// from r rather than lose it.
+		log.Print(err)
+		delete(m.fragments, r.Xid)
+		m.Inbound <- p.reply
+		return m.multipart(r)
+	}
+	if more {
+		return true
+	}
+	delete(m.fragments, r.Xid)
+	m.Inbound <- p.reply
+	return true
 }
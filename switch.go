@@ -1,9 +1,12 @@
 package ogo
 
 import (
+	"errors"
 	"log"
 	"net"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/jonstout/ogo/protocol/ofp10"
 	"github.com/jonstout/ogo/protocol/ofpxx"
@@ -168,9 +171,86 @@ func (sw *OFSwitch) Port(portNo uint16) (port ofp10.PhyPort, ok bool) {
 	return
 }
 
-// Sends an OpenFlow message to this Switch.
-func (s *OFSwitch) Send(req util.Message) {
+// Sends an OpenFlow message to this Switch and returns its Xid.
+// A message without an Xid is given the next one from this
+// Switch's connection.
+func (s *OFSwitch) Send(req util.Message) uint32 {
+	var xid uint32
+	if m, ok := req.(ofpxx.XidMessage); ok {
+		if xid = m.GetXid(); xid == 0 {
+			xid = s.stream.NextXid()
+			m.SetXid(xid)
+		}
+	}
 	s.stream.Outbound <- req
+	return xid
+}
+
+// Sends req to this Switch and waits for the message that
+// answers it. Req is given a fresh Xid so that the reply
+// cannot be mistaken for one to another pending request.
+func (s *OFSwitch) request(req ofpxx.XidMessage) (util.Message, error) {
+	xid := s.stream.NextXid()
+	req.SetXid(xid)
+	ch := make(chan util.Message, 1)
+	s.reqsMu.Lock()
+	s.reqs[xid] = ch
+	s.reqsMu.Unlock()
+
+	defer func() {
+		s.reqsMu.Lock()
+		delete(s.reqs, xid)
+		s.reqsMu.Unlock()
+	}()
+
+	s.Send(req)
+	select {
+	case msg := <-ch:
+		return msg, nil
+	case <-time.After(time.Second * 3):
+		return nil, errors.New("Timed out waiting for a reply from " + s.DPID().String())
+	}
+}
+
+// Hands msg to a pending request with a matching Xid.
+func (s *OFSwitch) reply(msg util.Message) {
+	s.reqsMu.RLock()
+	pending := len(s.reqs)
+	s.reqsMu.RUnlock()
+	if pending == 0 {
+		return
+	}
+
+	m, ok := msg.(ofpxx.XidMessage)
+	if !ok {
+		return
+	}
+	s.reqsMu.RLock()
+	ch, ok := s.reqs[m.GetXid()]
+	s.reqsMu.RUnlock()
+	if ok {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// Returns the queues configured on port of Switch s.
+func (s *OFSwitch) QueueConfig(port uint16) (*ofp10.QueueGetConfigReply, error) {
+	req := ofp10.NewQueueGetConfigRequest(port)
+	msg, err := s.request(req)
+	if err != nil {
+		return nil, err
+	}
+	switch m := msg.(type) {
+	case *ofp10.QueueGetConfigReply:
+		return m, nil
+	case *ofp10.ErrorMsg:
+		return nil, errors.New("Queue config request failed with error code " +
+			strconv.Itoa(int(m.Code)))
+	}
+	return nil, errors.New("Unexpected reply to queue config request.")
 }
 
 // Receive loop for each Switch.
@@ -180,6 +260,7 @@ func (s *OFSwitch) receive() {
 		case msg := <-s.stream.Inbound:
 			// New message has been received from message
 			// stream.
+			s.reply(msg)
 			go s.distributeMessages(s.dpid, msg)
 		case err := <-s.stream.Error:
 			// Message stream has been disconnected.
@@ -231,9 +312,9 @@ func (s *OFSwitch) distributeMessages(dpid net.HardwareAddr, msg util.Message) {
 			if actor, ok := app.(ofp10.ErrorReactor); ok {
 				actor.Error(s.DPID(), t)
 			}
-		case *ofp10.VendorHeader:
+		case ofp10.VendorMessage:
 			if actor, ok := app.(ofp10.VendorReactor); ok {
-				actor.VendorHeader(s.DPID(), t)
+				actor.Vendor(s.DPID(), t)
 			}
 		case *ofp10.SwitchFeatures:
 			if actor, ok := app.(ofp10.FeaturesReplyReactor); ok {
@@ -282,6 +363,14 @@ func (s *OFSwitch) distributeMessages(dpid net.HardwareAddr, msg util.Message) {
 			if actor, ok := app.(ofp10.StatsReplyReactor); ok {
 				actor.StatsReply(s.DPID(), t)
 			}
+		case *ofp10.QueueGetConfigRequest:
+			if actor, ok := app.(ofp10.QueueGetConfigRequestReactor); ok {
+				actor.QueueGetConfigRequest(t)
+			}
+		case *ofp10.QueueGetConfigReply:
+			if actor, ok := app.(ofp10.QueueGetConfigReplyReactor); ok {
+				actor.QueueGetConfigReply(s.DPID(), t)
+			}
 		}
 	}
 }